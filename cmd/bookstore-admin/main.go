@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/mg4603/go-bookstore-management-system/pkg/admin"
+	"github.com/mg4603/go-bookstore-management-system/pkg/config"
+	"github.com/mg4603/go-bookstore-management-system/pkg/migrations"
+	"github.com/mg4603/go-bookstore-management-system/pkg/models"
+	"gorm.io/gorm"
+)
+
+// Logs go to stderr so command output on stdout stays scriptable.
+var logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+func openDB(dialector gorm.Dialector, config *gorm.Config) (*gorm.DB, error) {
+	if db, err := gorm.Open(dialector, config); err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	} else {
+		return db, nil
+	}
+}
+
+func main() {
+	cfg, args, err := config.Load("bookstore-admin", os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, admin.Usage)
+		os.Exit(2)
+	}
+
+	if err := config.Connect(openDB, cfg.Database, logger); err != nil {
+		fmt.Fprintln(os.Stderr, "failed to connect to database:", err)
+		os.Exit(1)
+	}
+	db := &models.DBModel{DB: config.GetDB(), QueryTimeout: cfg.Database.QueryTimeout}
+
+	migrator, err := migrations.New(db.DB, logger, migrations.All)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid migration set:", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cli := &admin.CLI{Books: db, Keys: db, Tenants: db, Migrator: migrator, Out: os.Stdout, Tenant: cfg.Tenant.Default}
+	if err := cli.Run(ctx, args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
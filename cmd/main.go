@@ -1,27 +1,51 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
-	"github.com/joho/godotenv"
+	"github.com/mg4603/go-bookstore-management-system/pkg/cache"
+	"github.com/mg4603/go-bookstore-management-system/pkg/changefeed"
 	"github.com/mg4603/go-bookstore-management-system/pkg/config"
 	"github.com/mg4603/go-bookstore-management-system/pkg/controllers"
+	"github.com/mg4603/go-bookstore-management-system/pkg/cors"
+	"github.com/mg4603/go-bookstore-management-system/pkg/graphqlapi"
+	"github.com/mg4603/go-bookstore-management-system/pkg/grpcserver"
+	"github.com/mg4603/go-bookstore-management-system/pkg/metrics"
+	"github.com/mg4603/go-bookstore-management-system/pkg/migrations"
 	"github.com/mg4603/go-bookstore-management-system/pkg/models"
+	"github.com/mg4603/go-bookstore-management-system/pkg/openapi"
+	"github.com/mg4603/go-bookstore-management-system/pkg/ratelimit"
 	"github.com/mg4603/go-bookstore-management-system/pkg/routes"
+	"github.com/mg4603/go-bookstore-management-system/pkg/tenant"
+	"github.com/mg4603/go-bookstore-management-system/pkg/tracing"
+	"github.com/mg4603/go-bookstore-management-system/pkg/utils"
+	"github.com/mg4603/go-bookstore-management-system/pkg/webhooks"
+	"go.opentelemetry.io/otel"
 	"gorm.io/gorm"
 )
 
-var db *models.DBModel
+const (
+	readinessPingTimeout = 2 * time.Second
+	shutdownDrainPeriod  = 5 * time.Second
+	shutdownTimeout      = 30 * time.Second
+)
 
-func loadEnv() error {
-	if err := godotenv.Load(); err != nil {
-		return fmt.Errorf("error loading .env file: %w", err)
-	}
-	return nil
-}
+var (
+	db           *models.DBModel
+	logger       = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	healthStatus = &controllers.HealthStatus{}
+)
 
 func openDB(dialector gorm.Dialector, config *gorm.Config) (*gorm.DB, error) {
 	if db, err := gorm.Open(dialector, config); err != nil {
@@ -32,23 +56,182 @@ func openDB(dialector gorm.Dialector, config *gorm.Config) (*gorm.DB, error) {
 }
 
 func init() {
-	config.Connect(openDB, loadEnv)
-	bookDB := config.GetDB()
-	if err := bookDB.AutoMigrate(&models.Book{}); err != nil {
-		log.Printf("error during automigration: %s", err.Error())
+	slog.SetDefault(logger)
+}
+
+func main() {
+	cfg, args, err := config.Load("bookstore", os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		logger.Error("failed to load configuration", "error", err)
+		os.Exit(2)
+	}
+	if len(args) > 0 && args[0] == "config" {
+		if err := cfg.Print(os.Stdout); err != nil {
+			os.Exit(1)
+		}
 		return
 	}
 
-	db = &models.DBModel{DB: bookDB}
-}
+	if err := config.Connect(openDB, cfg.Database, logger); err != nil {
+		logger.Error("failed to connect to database", "error", err)
+		os.Exit(1)
+	}
+	utils.MaxBodyBytes = cfg.MaxBodyBytes
+	db = &models.DBModel{DB: config.GetDB(), QueryTimeout: cfg.Database.QueryTimeout}
+	if replicas := config.GetReplicas(); len(replicas) > 0 {
+		db.Replicas = models.NewReplicaSet(replicas)
+	}
 
-func main() {
-	bookstoreController := controllers.NewBookStoreController(db)
+	migrator, err := migrations.New(db.DB, logger, migrations.All)
+	if err != nil {
+		logger.Error("invalid migration set", "error", err)
+		os.Exit(1)
+	}
+
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := migrations.RunCommand(context.Background(), migrator, args[1:], os.Stdout); err != nil {
+			logger.Error("migrate failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Instances that start together serialise on the migration lock, so
+	// running pending migrations at startup is safe.
+	_, err = migrator.Up(context.Background())
+	if err != nil {
+		logger.Error("error applying migrations", "error", err)
+	}
+	healthStatus.SetMigrated(err)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		logger.Error("failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+	for _, pool := range append([]*gorm.DB{db.DB}, config.GetReplicas()...) {
+		if err := pool.Use(tracing.NewGormPlugin(otel.GetTracerProvider())); err != nil {
+			logger.Error("failed to instrument database", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	registry := metrics.NewRegistry()
+	registry.NewGaugeFunc("bookstore_books_total", "Number of books in the catalogues of all tenants.", func() (float64, error) {
+		count, err := db.CountBooksAllTenants(context.Background())
+		return float64(count), err
+	})
+	sqlDB, err := db.DB.DB()
+	if err != nil {
+		logger.Error("failed to access database pool", "error", err)
+		os.Exit(1)
+	}
+	registry.Register(metrics.NewDBStatsCollector(sqlDB))
+
+	var books models.BookstoreDB = db
+	if cfg.Cache.TTL > 0 {
+		lru := cache.NewLRU(cfg.Cache.Size)
+		registry.NewGaugeFunc("bookstore_cache_entries", "Number of entries in the book cache.", func() (float64, error) {
+			return float64(lru.Len()), nil
+		})
+		books = cache.NewBookCache(db, lru, cfg.Cache.TTL, registry)
+	}
+	bookstoreController := controllers.NewBookStoreController(books, logger, cfg.Cache.MaxAge)
 
 	r := mux.NewRouter()
-	routes.RegisterBookstoreRoutes(r, bookstoreController)
+	r.Use(tracing.Middleware(otel.GetTracerProvider(), otel.GetTextMapPropagator()))
+	r.Use(metrics.NewHTTPMetrics(registry).Middleware)
+	r.Use(models.ReadYourWrites)
+	r.Use(ratelimit.NewLimiter(ratelimit.NewMemoryStore(), ratelimit.NewClientKeyer(cfg.RateLimit.TrustedProxies, db), cfg.RateLimit.Default, cfg.RateLimit.Routes).Middleware)
+	apiDoc := openapi.Bookstore()
+	r.Use(apiDoc.ValidateRequests)
+	// Only the catalogue routes are per tenant; health checks and metrics
+	// must not depend on resolving one.
+	tenants := tenant.NewResolver(db, cfg.Tenant)
+	catalogue := r.NewRoute().Subrouter()
+	catalogue.Use(tenants.Middleware)
+	feed := changefeed.NewFeed(db, cfg.ChangeFeed, logger)
+	db.OnBookChange = feed.Notify
+	routes.RegisterChangeFeedRoutes(catalogue, feed)
+	routes.RegisterBookstoreRoutes(catalogue, bookstoreController)
+	graphqlHandler, err := graphqlapi.NewHandler(books, logger)
+	if err != nil {
+		logger.Error("failed to build GraphQL handler", "error", err)
+		os.Exit(1)
+	}
+	routes.RegisterGraphQLRoutes(catalogue, graphqlHandler)
+	routes.RegisterWebhookRoutes(catalogue, controllers.NewWebhookController(db, logger))
+	routes.RegisterHealthRoutes(r, controllers.NewHealthController(sqlDB, healthStatus, readinessPingTimeout))
+	routes.RegisterMetricsRoutes(r, registry.Handler())
+	routes.RegisterOpenAPIRoutes(r, apiDoc)
+
+	srv := &http.Server{
+		Addr:    cfg.HTTPAddr,
+		Handler: utils.RequestID(utils.AccessLog(logger)(cors.New(cfg.CORS, r).Middleware(r))),
+	}
+	srv.RegisterOnShutdown(feed.Shutdown)
 
-	http.Handle("/", r)
+	grpcListener, err := net.Listen("tcp", cfg.GRPCAddr)
+	if err != nil {
+		logger.Error("failed to listen for gRPC", "addr", cfg.GRPCAddr, "error", err)
+		os.Exit(1)
+	}
+	grpcSrv := grpcserver.NewServer(books, tenants, logger)
+
+	serverErr := make(chan error, 2)
+	go func() {
+		serverErr <- srv.ListenAndServe()
+	}()
+	go func() {
+		serverErr <- grpcSrv.Serve(grpcListener)
+	}()
+	logger.Info("http server listening", "addr", cfg.HTTPAddr)
+	logger.Info("grpc server listening", "addr", cfg.GRPCAddr)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go config.WatchSecretFiles(ctx, cfg, logger)
+	go webhooks.NewDispatcher(db, cfg.Webhooks, logger, registry).Run(ctx)
+	go feed.Run(ctx)
+	if db.Replicas != nil {
+		go db.Replicas.Watch(ctx, cfg.Database.ReplicaCheckInterval, readinessPingTimeout, logger)
+	}
+
+	select {
+	case err := <-serverErr:
+		logger.Error("server stopped", "error", err)
+		os.Exit(1)
+	case <-ctx.Done():
+	}
+
+	// Fail readiness first and keep serving for a moment so load balancers
+	// stop routing new traffic here before connections are closed.
+	logger.Info("shutting down", "drain_period", shutdownDrainPeriod)
+	healthStatus.SetShuttingDown()
+	time.Sleep(shutdownDrainPeriod)
 
-	log.Fatal(http.ListenAndServe("localhost:9010", r))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	grpcStopped := make(chan struct{})
+	go func() {
+		grpcSrv.GracefulStop()
+		close(grpcStopped)
+	}()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Error("graceful shutdown failed", "error", err)
+		os.Exit(1)
+	}
+	select {
+	case <-grpcStopped:
+	case <-shutdownCtx.Done():
+		logger.Error("graceful gRPC shutdown timed out")
+		grpcSrv.Stop()
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		logger.Error("failed to flush traces", "error", err)
+	}
+	logger.Info("server stopped")
 }
@@ -0,0 +1,322 @@
+package admin
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mg4603/go-bookstore-management-system/pkg/migrations"
+	"github.com/mg4603/go-bookstore-management-system/pkg/models"
+	"github.com/mg4603/go-bookstore-management-system/pkg/utils"
+)
+
+const Usage = `usage: bookstore-admin [configuration flags] <command> [arguments]
+
+Run bookstore-admin -h to list the configuration flags.
+
+commands:
+  books [-tenant SLUG] list
+  books [-tenant SLUG] add -name NAME -author AUTHOR -publication PUBLICATION
+  books [-tenant SLUG] update -id ID [-name NAME] [-author AUTHOR] [-publication PUBLICATION]
+  books [-tenant SLUG] delete -id ID
+  books [-tenant SLUG] import FILE          (.json or .csv)
+  books [-tenant SLUG] export FILE          (.json, .xml or .csv)
+  migrate up | down [steps] | status
+  apikey [-tenant SLUG] create -name NAME
+  tenants list
+  tenants create -slug SLUG -name NAME
+
+Book and API key commands act on the DEFAULT_TENANT tenant unless -tenant
+names another.`
+
+// exportTypes maps file extensions to the media types used to encode them.
+var exportTypes = map[string]string{
+	".json": "application/json",
+	".xml":  "application/xml",
+	".csv":  "text/csv",
+}
+
+// CLI runs admin commands directly against the models layer.
+type CLI struct {
+	Books    models.BookstoreDB
+	Keys     models.APIKeyStore
+	Tenants  models.TenantStore
+	Migrator *migrations.Migrator
+	Out      io.Writer
+	// Tenant is the slug of the tenant used when -tenant is not given.
+	Tenant string
+}
+
+func (c *CLI) Run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New(Usage)
+	}
+
+	switch args[0] {
+	case "books":
+		return c.books(ctx, args[1:])
+	case "migrate":
+		return migrations.RunCommand(ctx, c.Migrator, args[1:], c.Out)
+	case "apikey":
+		return c.apiKey(ctx, args[1:])
+	case "tenants":
+		return c.tenants(ctx, args[1:])
+	}
+	return fmt.Errorf("unknown command %q\n%s", args[0], Usage)
+}
+
+func (c *CLI) books(ctx context.Context, args []string) error {
+	ctx, args, err := c.withTenant(ctx, "books", args)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return errors.New(Usage)
+	}
+
+	switch args[0] {
+	case "list":
+		books, err := c.Books.GetAllBooks(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(c.Out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tAUTHOR\tPUBLICATION")
+		for _, b := range books {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", b.ID, b.Name, b.Author, b.Publication)
+		}
+		return tw.Flush()
+	case "add":
+		var book models.Book
+		fs := newFlagSet("books add")
+		fs.StringVar(&book.Name, "name", "", "book name")
+		fs.StringVar(&book.Author, "author", "", "book author")
+		fs.StringVar(&book.Publication, "publication", "", "book publication")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if err := c.Books.CreateBook(ctx, &book); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.Out, "created book %d\n", book.ID)
+		return nil
+	case "update":
+		fs := newFlagSet("books update")
+		id := fs.Int64("id", 0, "book ID")
+		name := fs.String("name", "", "new book name")
+		author := fs.String("author", "", "new book author")
+		publication := fs.String("publication", "", "new book publication")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		book := &models.Book{ID: uint(*id), Name: *name, Author: *author, Publication: *publication}
+		if err := c.Books.UpdateBook(ctx, book); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.Out, "updated book %d\n", book.ID)
+		return nil
+	case "delete":
+		fs := newFlagSet("books delete")
+		id := fs.Int64("id", 0, "book ID")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		book, err := c.Books.DeleteBook(ctx, *id)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(c.Out, "deleted book %d\n", book.ID)
+		return nil
+	case "import":
+		if len(args) != 2 {
+			return errors.New("usage: books import FILE")
+		}
+		return c.importBooks(ctx, args[1])
+	case "export":
+		if len(args) != 2 {
+			return errors.New("usage: books export FILE")
+		}
+		return c.exportBooks(ctx, args[1])
+	}
+	return fmt.Errorf("unknown books command %q\n%s", args[0], Usage)
+}
+
+func (c *CLI) importBooks(ctx context.Context, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var books []models.Book
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		format, _ := utils.LookupFormat("application/json")
+		if err := format.Decode(f, &books); err != nil {
+			return fmt.Errorf("error parsing %s: %w", path, err)
+		}
+	case ".csv":
+		if books, err = readBooksCSV(f); err != nil {
+			return fmt.Errorf("error parsing %s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("cannot import %s: expected a .json or .csv file", path)
+	}
+
+	// Rows are created one at a time, so a failure leaves the earlier rows
+	// in place; report how far the import got.
+	for i := range books {
+		books[i].ID = 0
+		if err := c.Books.CreateBook(ctx, &books[i]); err != nil {
+			return fmt.Errorf("record %d: %w (imported %d of %d books)", i+1, err, i, len(books))
+		}
+	}
+	fmt.Fprintf(c.Out, "imported %d books\n", len(books))
+	return nil
+}
+
+func readBooksCSV(r io.Reader) ([]models.Book, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[strings.TrimSpace(strings.ToLower(name))] = i
+	}
+	for _, required := range []string{"name", "author", "publication"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing %q column", required)
+		}
+	}
+
+	books := make([]models.Book, 0, len(records)-1)
+	for _, record := range records[1:] {
+		books = append(books, models.Book{
+			Name:        record[columns["name"]],
+			Author:      record[columns["author"]],
+			Publication: record[columns["publication"]],
+		})
+	}
+	return books, nil
+}
+
+func (c *CLI) exportBooks(ctx context.Context, path string) error {
+	mediaType, ok := exportTypes[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return fmt.Errorf("cannot export %s: expected a .json, .xml or .csv file", path)
+	}
+	format, _ := utils.LookupFormat(mediaType)
+
+	books, err := c.Books.GetAllBooks(ctx)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := format.Encode(f, books); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.Out, "exported %d books to %s\n", len(books), path)
+	return nil
+}
+
+func (c *CLI) apiKey(ctx context.Context, args []string) error {
+	ctx, args, err := c.withTenant(ctx, "apikey", args)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 || args[0] != "create" {
+		return errors.New("usage: apikey [-tenant SLUG] create -name NAME")
+	}
+
+	fs := newFlagSet("apikey create")
+	name := fs.String("name", "", "who or what the key is for")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	key, apiKey, err := c.Keys.CreateAPIKey(ctx, *name)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.Out, "created API key %d (%s) for %s\n", apiKey.ID, apiKey.Prefix, apiKey.Name)
+	fmt.Fprintf(c.Out, "key: %s\n", key)
+	fmt.Fprintln(c.Out, "store it now; it cannot be shown again")
+	return nil
+}
+
+func (c *CLI) tenants(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New(Usage)
+	}
+
+	switch args[0] {
+	case "list":
+		tenants, err := c.Tenants.GetAllTenants(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(c.Out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tSLUG\tNAME")
+		for _, t := range tenants {
+			fmt.Fprintf(tw, "%d\t%s\t%s\n", t.ID, t.Slug, t.Name)
+		}
+		return tw.Flush()
+	case "create":
+		fs := newFlagSet("tenants create")
+		slug := fs.String("slug", "", "tenant slug, used in X-Tenant and as a subdomain")
+		name := fs.String("name", "", "tenant name")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		tenant, err := c.Tenants.CreateTenant(ctx, *slug, *name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(c.Out, "created tenant %d (%s)\n", tenant.ID, tenant.Slug)
+		return nil
+	}
+	return fmt.Errorf("unknown tenants command %q\n%s", args[0], Usage)
+}
+
+// withTenant parses the -tenant flag that books and apikey take before their
+// subcommand and scopes ctx to that tenant, or to c.Tenant.
+func (c *CLI) withTenant(ctx context.Context, command string, args []string) (context.Context, []string, error) {
+	fs := newFlagSet(command)
+	slug := fs.String("tenant", c.Tenant, "tenant slug")
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+	if *slug == "" {
+		return nil, nil, errors.New("no tenant: pass -tenant or set DEFAULT_TENANT")
+	}
+	tenant, err := c.Tenants.GetTenantBySlug(ctx, *slug)
+	if err != nil {
+		return nil, nil, err
+	}
+	return models.WithTenant(ctx, tenant.ID), fs.Args(), nil
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
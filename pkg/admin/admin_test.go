@@ -0,0 +1,212 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mg4603/go-bookstore-management-system/pkg/migrations"
+	"github.com/mg4603/go-bookstore-management-system/pkg/models"
+	"github.com/mg4603/go-bookstore-management-system/pkg/tests"
+	"github.com/stretchr/testify/assert"
+)
+
+func setup(t *testing.T) (*CLI, *models.DBModel, *bytes.Buffer) {
+	mockDB, err := tests.Setup()
+	assert.NoError(t, err, "failed to setup test database")
+	t.Cleanup(func() {
+		sqlDB, _ := mockDB.DB()
+		if sqlDB != nil {
+			sqlDB.Close()
+		}
+	})
+
+	migrator, err := migrations.New(mockDB, slog.New(slog.NewTextHandler(io.Discard, nil)), migrations.All)
+	assert.NoError(t, err)
+
+	db := &models.DBModel{DB: mockDB}
+	out := &bytes.Buffer{}
+	return &CLI{Books: db, Keys: db, Tenants: db, Migrator: migrator, Out: out, Tenant: "default"}, db, out
+}
+
+func TestBooksCommands(t *testing.T) {
+	testCases := []struct {
+		name           string
+		seed           []models.Book
+		args           []string
+		expectedError  string
+		expectedOutput string
+		expectedBooks  []models.Book
+	}{
+		{
+			name:           "Add",
+			args:           []string{"books", "add", "-name", "Book1", "-author", "Author1", "-publication", "Publication1"},
+			expectedOutput: "created book 1\n",
+			expectedBooks:  []models.Book{{ID: 1, Name: "Book1", Author: "Author1", Publication: "Publication1"}},
+		},
+		{
+			name:          "Add missing field",
+			args:          []string{"books", "add", "-name", "Book1"},
+			expectedError: "missing required fields",
+		},
+		{
+			name:           "List",
+			seed:           []models.Book{{Name: "Book1", Author: "Author1", Publication: "Publication1"}},
+			args:           []string{"books", "list"},
+			expectedOutput: "ID  NAME   AUTHOR   PUBLICATION\n1   Book1  Author1  Publication1\n",
+		},
+		{
+			name:           "Update keeps unset fields",
+			seed:           []models.Book{{Name: "Book1", Author: "Author1", Publication: "Publication1"}},
+			args:           []string{"books", "update", "-id", "1", "-author", "Author2"},
+			expectedOutput: "updated book 1\n",
+			expectedBooks:  []models.Book{{ID: 1, Name: "Book1", Author: "Author2", Publication: "Publication1"}},
+		},
+		{
+			name:          "Update missing book",
+			args:          []string{"books", "update", "-id", "7", "-name", "Book7"},
+			expectedError: "book with ID 7 not found",
+		},
+		{
+			name:           "Delete",
+			seed:           []models.Book{{Name: "Book1", Author: "Author1", Publication: "Publication1"}},
+			args:           []string{"books", "delete", "-id", "1"},
+			expectedOutput: "deleted book 1\n",
+			expectedBooks:  []models.Book{},
+		},
+		{
+			name:          "Invalid flag",
+			args:          []string{"books", "delete", "-id", "one"},
+			expectedError: `invalid value "one" for flag -id: parse error`,
+		},
+		{
+			name:          "Unknown command",
+			args:          []string{"shelves"},
+			expectedError: "unknown command \"shelves\"\n" + Usage,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			cli, db, out := setup(t)
+			for _, book := range tt.seed {
+				assert.NoError(t, db.CreateBook(tests.Context(), &book))
+			}
+
+			err := cli.Run(context.Background(), tt.args)
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedOutput, out.String())
+
+			if tt.expectedBooks != nil {
+				books, err := db.GetAllBooks(tests.Context())
+				assert.NoError(t, err)
+				assert.Len(t, books, len(tt.expectedBooks))
+				for i, book := range tt.expectedBooks {
+					assert.Equal(t, book.ID, books[i].ID)
+					assert.Equal(t, book.Name, books[i].Name)
+					assert.Equal(t, book.Author, books[i].Author)
+					assert.Equal(t, book.Publication, books[i].Publication)
+				}
+			}
+		})
+	}
+}
+
+func TestImportExport(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "books.csv")
+	assert.NoError(t, os.WriteFile(csvPath, []byte("publication,name,author\nPublication1,Book1,Author1\nPublication2,Book2,Author2\n"), 0o644))
+
+	cli, db, out := setup(t)
+	assert.NoError(t, cli.Run(context.Background(), []string{"books", "import", csvPath}))
+	assert.Equal(t, "imported 2 books\n", out.String())
+
+	count, err := db.CountBooks(tests.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	jsonPath := filepath.Join(dir, "books.json")
+	assert.NoError(t, cli.Run(context.Background(), []string{"books", "export", jsonPath}))
+	contents, err := os.ReadFile(jsonPath)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[{"ID":1,"name":"Book1","author":"Author1","publication":"Publication1"},{"ID":2,"name":"Book2","author":"Author2","publication":"Publication2"}]`, string(contents))
+
+	xmlPath := filepath.Join(dir, "books.xml")
+	assert.NoError(t, cli.Run(context.Background(), []string{"books", "export", xmlPath}))
+	contents, err = os.ReadFile(xmlPath)
+	assert.NoError(t, err)
+	assert.Contains(t, string(contents), "<book><id>2</id><name>Book2</name>")
+
+	// Re-importing an export copies the rows under new IDs.
+	assert.NoError(t, cli.Run(context.Background(), []string{"books", "import", jsonPath}))
+	count, err = db.CountBooks(tests.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), count)
+
+	assert.EqualError(t, cli.Run(context.Background(), []string{"books", "export", filepath.Join(dir, "books.txt")}),
+		"cannot export "+filepath.Join(dir, "books.txt")+": expected a .json, .xml or .csv file")
+
+	badCSV := filepath.Join(dir, "bad.csv")
+	assert.NoError(t, os.WriteFile(badCSV, []byte("name,author\nBook1,Author1\n"), 0o644))
+	assert.ErrorContains(t, cli.Run(context.Background(), []string{"books", "import", badCSV}), `missing "publication" column`)
+}
+
+func TestMigrateAndAPIKeyCommands(t *testing.T) {
+	cli, db, out := setup(t)
+
+	assert.NoError(t, cli.Run(context.Background(), []string{"migrate", "up"}))
+	assert.Equal(t, "no pending migrations\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, cli.Run(context.Background(), []string{"apikey", "create", "-name", "ops"}))
+	assert.Contains(t, out.String(), "for ops\nkey: bks_")
+
+	var key string
+	for _, line := range bytes.Split(out.Bytes(), []byte("\n")) {
+		if k, ok := bytes.CutPrefix(line, []byte("key: ")); ok {
+			key = string(k)
+		}
+	}
+	apiKey, err := db.AuthenticateAPIKey(context.Background(), key)
+	assert.NoError(t, err)
+	assert.Equal(t, "ops", apiKey.Name)
+
+	assert.EqualError(t, cli.Run(context.Background(), []string{"apikey", "create"}), "missing required fields")
+}
+
+func TestTenantCommands(t *testing.T) {
+	cli, db, out := setup(t)
+	ctx := context.Background()
+
+	assert.NoError(t, cli.Run(ctx, []string{"tenants", "create", "-slug", "acme", "-name", "Acme"}))
+	assert.Equal(t, "created tenant 2 (acme)\n", out.String())
+	assert.ErrorIs(t, cli.Run(ctx, []string{"tenants", "create", "-slug", "Acme Inc", "-name", "Acme"}), models.ErrInvalidSlug)
+
+	out.Reset()
+	assert.NoError(t, cli.Run(ctx, []string{"tenants", "list"}))
+	assert.Equal(t, "ID  SLUG     NAME\n1   default  Default\n2   acme     Acme\n", out.String())
+
+	assert.NoError(t, cli.Run(ctx, []string{"books", "-tenant", "acme", "add", "-name", "Book1", "-author", "Author1", "-publication", "Publication1"}))
+	books, err := db.GetAllBooks(tests.Context())
+	assert.NoError(t, err)
+	assert.Empty(t, books, "-tenant must keep the book out of the default tenant")
+	books, err = db.GetAllBooks(models.WithTenant(ctx, 2))
+	assert.NoError(t, err)
+	assert.Len(t, books, 1)
+
+	out.Reset()
+	assert.NoError(t, cli.Run(ctx, []string{"apikey", "-tenant", "acme", "create", "-name", "ops"}))
+	assert.Contains(t, out.String(), "for ops\n")
+
+	assert.ErrorIs(t, cli.Run(ctx, []string{"books", "-tenant", "initech", "list"}), models.ErrNotFound)
+	cli.Tenant = ""
+	assert.EqualError(t, cli.Run(ctx, []string{"books", "list"}), "no tenant: pass -tenant or set DEFAULT_TENANT")
+}
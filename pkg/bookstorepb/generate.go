@@ -0,0 +1,5 @@
+// Package bookstorepb holds the protobuf definition of the gRPC BookService
+// and the code generated from it.
+package bookstorepb
+
+//go:generate buf generate
@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/mg4603/go-bookstore-management-system/pkg/metrics"
+	"github.com/mg4603/go-bookstore-management-system/pkg/models"
+	"github.com/mg4603/go-bookstore-management-system/pkg/utils"
+)
+
+// Keys include the tenant, so tenants never see each other's entries.
+func bookKey(tenantID uint, id int64) string {
+	return "books:t:" + strconv.FormatUint(uint64(tenantID), 10) + ":id:" + strconv.FormatInt(id, 10)
+}
+
+// BookCache is a read-through cache in front of a BookstoreDB. It caches
+// GetBookById and drops a book's entry whenever the book is updated or
+// deleted through it. Writes that bypass it, e.g. from another instance with
+// its own LRU, show up once entries expire. The embedded BookstoreDB serves
+// every other method uncached, as it does calls whose context names no
+// tenant. Lists are not cached: GET /books/ streams them in batches through
+// EachBook rather than holding the whole catalogue.
+type BookCache struct {
+	models.BookstoreDB
+	store    Store
+	ttl      time.Duration
+	requests *metrics.CounterVec
+	// writes counts invalidations, so a read that raced a write does not
+	// store what it read before the write.
+	writes atomic.Uint64
+}
+
+func NewBookCache(next models.BookstoreDB, store Store, ttl time.Duration, reg *metrics.Registry) *BookCache {
+	return &BookCache{
+		BookstoreDB: next,
+		store:       store,
+		ttl:         ttl,
+		requests:    reg.NewCounterVec("bookstore_cache_requests_total", "Book cache lookups and invalidations by operation and result.", "operation", "result"),
+	}
+}
+
+func (c *BookCache) GetBookById(ctx context.Context, id int64) (*models.Book, error) {
+	tenantID, ok := models.TenantFromContext(ctx)
+	if !ok {
+		return c.BookstoreDB.GetBookById(ctx, id)
+	}
+	var book models.Book
+	err := c.readThrough(ctx, "get_book", bookKey(tenantID, id), &book, func() (interface{}, error) {
+		return c.BookstoreDB.GetBookById(ctx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &book, nil
+}
+
+func (c *BookCache) UpdateBook(ctx context.Context, b *models.Book) error {
+	defer c.invalidate(ctx, func(tenantID uint) []string {
+		return []string{bookKey(tenantID, int64(b.ID))}
+	})
+	return c.BookstoreDB.UpdateBook(ctx, b)
+}
+
+func (c *BookCache) DeleteBook(ctx context.Context, id int64) (*models.Book, error) {
+	defer c.invalidate(ctx, func(tenantID uint) []string {
+		return []string{bookKey(tenantID, id)}
+	})
+	return c.BookstoreDB.DeleteBook(ctx, id)
+}
+
+// readThrough decodes the cached value for key into out, or loads it and
+// caches it. Cache failures fall back to the database.
+func (c *BookCache) readThrough(ctx context.Context, operation, key string, out interface{}, load func() (interface{}, error)) error {
+	data, ok, err := c.store.Get(ctx, key)
+	if err != nil {
+		c.requests.Inc(operation, "error")
+		utils.LoggerFromContext(ctx).Warn("cache read failed", "key", key, "error", err)
+	} else if ok {
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(out); err == nil {
+			c.requests.Inc(operation, "hit")
+			return nil
+		}
+		c.requests.Inc(operation, "error")
+	} else {
+		c.requests.Inc(operation, "miss")
+	}
+
+	writes := c.writes.Load()
+	value, err := load()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		return err
+	}
+	if c.writes.Load() == writes {
+		if err := c.store.Set(ctx, key, buf.Bytes(), c.ttl); err != nil {
+			utils.LoggerFromContext(ctx).Warn("cache write failed", "key", key, "error", err)
+		}
+	}
+	return gob.NewDecoder(&buf).Decode(out)
+}
+
+// invalidate drops the keys of ctx's tenant; without one the write failed
+// and there is nothing to drop.
+func (c *BookCache) invalidate(ctx context.Context, tenantKeys func(tenantID uint) []string) {
+	tenantID, ok := models.TenantFromContext(ctx)
+	if !ok {
+		return
+	}
+	keys := tenantKeys(tenantID)
+	c.writes.Add(1)
+	// The write may have committed even if the client has since gone away.
+	if err := c.store.Delete(context.WithoutCancel(ctx), keys...); err != nil {
+		c.requests.Inc("invalidate", "error")
+		utils.LoggerFromContext(ctx).Error("cache invalidation failed", "keys", keys, "error", err)
+	}
+}
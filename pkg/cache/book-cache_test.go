@@ -0,0 +1,200 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mg4603/go-bookstore-management-system/pkg/metrics"
+	"github.com/mg4603/go-bookstore-management-system/pkg/models"
+	"github.com/mg4603/go-bookstore-management-system/pkg/tests"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingBookstore counts the reads that reach the database.
+type countingBookstore struct {
+	models.BookstoreDB
+	reads int
+}
+
+func (db *countingBookstore) GetAllBooks(ctx context.Context) ([]models.Book, error) {
+	db.reads++
+	return db.BookstoreDB.GetAllBooks(ctx)
+}
+
+func (db *countingBookstore) EachBook(ctx context.Context, batchSize int, fn func(batch []models.Book) error) error {
+	db.reads++
+	return db.BookstoreDB.EachBook(ctx, batchSize, fn)
+}
+
+func (db *countingBookstore) GetBookById(ctx context.Context, id int64) (*models.Book, error) {
+	db.reads++
+	return db.BookstoreDB.GetBookById(ctx, id)
+}
+
+type failingStore struct{}
+
+func (failingStore) Get(context.Context, string) ([]byte, bool, error) {
+	return nil, false, errors.New("store unavailable")
+}
+
+func (failingStore) Set(context.Context, string, []byte, time.Duration) error {
+	return errors.New("store unavailable")
+}
+
+func (failingStore) Delete(context.Context, ...string) error {
+	return errors.New("store unavailable")
+}
+
+func setup(t *testing.T, store Store) (*BookCache, *countingBookstore, *metrics.Registry) {
+	mockDB, err := tests.Setup()
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		sqlDB, _ := mockDB.DB()
+		if sqlDB != nil {
+			sqlDB.Close()
+		}
+	})
+
+	counting := &countingBookstore{BookstoreDB: &models.DBModel{DB: mockDB}}
+	reg := metrics.NewRegistry()
+	return NewBookCache(counting, store, time.Minute, reg), counting, reg
+}
+
+func requests(t *testing.T, reg *metrics.Registry) map[string]float64 {
+	values := map[string]float64{}
+	for _, f := range reg.Gather() {
+		for _, s := range f.Samples {
+			var labels []string
+			for _, l := range s.Labels {
+				labels = append(labels, l.Value)
+			}
+			values[strings.Join(labels, "/")] = s.Value
+		}
+	}
+	return values
+}
+
+func TestBookCacheListsAreNotCached(t *testing.T) {
+	db, counting, reg := setup(t, NewLRU(10))
+	assert.NoError(t, db.CreateBook(tests.Context(), &models.Book{Name: "Book1", Author: "Author1", Publication: "Publication1"}))
+
+	for i := 0; i < 2; i++ {
+		books, err := db.GetAllBooks(tests.Context())
+		assert.NoError(t, err)
+		assert.Len(t, books, 1)
+		assert.NoError(t, db.EachBook(tests.Context(), 10, func(batch []models.Book) error {
+			assert.Len(t, batch, 1)
+			return nil
+		}))
+	}
+	assert.Equal(t, 4, counting.reads, "every list reaches the database")
+	assert.Empty(t, requests(t, reg))
+}
+
+func TestBookCacheGetBookById(t *testing.T) {
+	db, counting, reg := setup(t, NewLRU(10))
+	book := &models.Book{Name: "Book1", Author: "Author1", Publication: "Publication1"}
+	assert.NoError(t, db.CreateBook(tests.Context(), book))
+
+	for i := 0; i < 2; i++ {
+		got, err := db.GetBookById(tests.Context(), int64(book.ID))
+		assert.NoError(t, err)
+		assert.Equal(t, "Book1", got.Name)
+	}
+	assert.Equal(t, 1, counting.reads)
+
+	book.Name = "Book2"
+	assert.NoError(t, db.UpdateBook(tests.Context(), book))
+	got, err := db.GetBookById(tests.Context(), int64(book.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, "Book2", got.Name, "update invalidates the book")
+	assert.Equal(t, 2, counting.reads)
+
+	_, err = db.DeleteBook(tests.Context(), int64(book.ID))
+	assert.NoError(t, err)
+	_, err = db.GetBookById(tests.Context(), int64(book.ID))
+	assert.ErrorIs(t, err, models.ErrNotFound, "delete invalidates the book")
+	_, err = db.GetBookById(tests.Context(), int64(book.ID))
+	assert.ErrorIs(t, err, models.ErrNotFound, "misses are not cached")
+	assert.Equal(t, 4, counting.reads)
+
+	assert.Equal(t, map[string]float64{"get_book/hit": 1, "get_book/miss": 4}, requests(t, reg))
+}
+
+func TestBookCacheUpdateThroughStaleEntry(t *testing.T) {
+	db, counting, _ := setup(t, NewLRU(10))
+	book := &models.Book{Name: "Book1", Author: "Author1", Publication: "Publication1"}
+	assert.NoError(t, db.CreateBook(tests.Context(), book))
+	_, err := db.GetBookById(tests.Context(), int64(book.ID))
+	assert.NoError(t, err)
+
+	// Another instance changes the author, leaving this one's entry stale.
+	assert.NoError(t, counting.BookstoreDB.UpdateBook(tests.Context(), &models.Book{ID: book.ID, Author: "Author2"}))
+	cached, err := db.GetBookById(tests.Context(), int64(book.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, "Author1", cached.Author)
+
+	update := &models.Book{ID: book.ID, Name: "Book2"}
+	assert.NoError(t, db.UpdateBook(tests.Context(), update))
+	assert.Equal(t, models.Book{ID: book.ID, Name: "Book2", Author: "Author2", Publication: "Publication1"},
+		models.Book{ID: update.ID, Name: update.Name, Author: update.Author, Publication: update.Publication})
+	got, err := db.GetBookById(tests.Context(), int64(book.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, "Author2", got.Author, "the update must not write back the stale author")
+}
+
+func TestBookCacheStoreFailures(t *testing.T) {
+	db, counting, reg := setup(t, failingStore{})
+
+	book := &models.Book{Name: "Book1", Author: "Author1", Publication: "Publication1"}
+	assert.NoError(t, db.CreateBook(tests.Context(), book))
+	assert.NoError(t, db.UpdateBook(tests.Context(), &models.Book{ID: book.ID, Name: "Book2"}), "a failed invalidation does not fail the write")
+	got, err := db.GetBookById(tests.Context(), int64(book.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, "Book2", got.Name)
+	assert.Equal(t, 1, counting.reads)
+
+	assert.Equal(t, map[string]float64{"get_book/error": 1, "invalidate/error": 1}, requests(t, reg))
+}
+
+func TestBookCacheSkipsReadsThatRacedAWrite(t *testing.T) {
+	store := NewLRU(10)
+	db, _, _ := setup(t, store)
+	book := &models.Book{Name: "Book1", Author: "Author1", Publication: "Publication1"}
+	assert.NoError(t, db.CreateBook(tests.Context(), book))
+
+	ctx := tests.Context()
+	err := db.readThrough(ctx, "get_book", bookKey(tests.DefaultTenantID, int64(book.ID)), &models.Book{}, func() (interface{}, error) {
+		loaded, err := db.BookstoreDB.GetBookById(ctx, int64(book.ID))
+		// Another request updates the book after this one read it.
+		db.invalidate(ctx, func(tenantID uint) []string {
+			return []string{bookKey(tenantID, int64(book.ID))}
+		})
+		return loaded, err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, store.Len())
+}
+
+func TestBookCacheIsPerTenant(t *testing.T) {
+	store := NewLRU(10)
+	db, counting, _ := setup(t, store)
+	other, err := counting.BookstoreDB.(*models.DBModel).CreateTenant(context.Background(), "other", "Other")
+	assert.NoError(t, err)
+	otherCtx := models.WithTenant(context.Background(), other.ID)
+
+	book := &models.Book{Name: "Book1", Author: "Author1", Publication: "Publication1"}
+	assert.NoError(t, db.CreateBook(tests.Context(), book))
+	_, err = db.GetBookById(tests.Context(), int64(book.ID))
+	assert.NoError(t, err)
+
+	_, err = db.GetBookById(otherCtx, int64(book.ID))
+	assert.ErrorIs(t, err, models.ErrNotFound, "another tenant must not be served the cached book")
+
+	_, err = db.GetBookById(context.Background(), int64(book.ID))
+	assert.ErrorIs(t, err, models.ErrNoTenant)
+	assert.Equal(t, 1, store.Len(), "lookups without a tenant are not cached")
+}
@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultTTL    = time.Minute
+	defaultSize   = 1000
+	defaultMaxAge = 30 * time.Second
+)
+
+type Config struct {
+	// TTL bounds how long the server caches a read; zero disables the cache.
+	TTL  time.Duration
+	Size int
+	// MaxAge is sent to clients in Cache-Control on successful reads.
+	MaxAge time.Duration
+}
+
+// ConfigFrom reads CACHE_TTL, CACHE_SIZE and CACHE_MAX_AGE through lookup.
+// CACHE_TTL=off disables the server-side cache.
+func ConfigFrom(lookup func(key string) (string, bool)) (Config, error) {
+	get := func(key string) string { v, _ := lookup(key); return v }
+	cfg := Config{TTL: defaultTTL, Size: defaultSize, MaxAge: defaultMaxAge}
+	var err error
+
+	if v := get("CACHE_TTL"); v == "off" {
+		cfg.TTL = 0
+	} else if v != "" {
+		if cfg.TTL, err = time.ParseDuration(v); err != nil || cfg.TTL < 0 {
+			return Config{}, fmt.Errorf("CACHE_TTL: invalid duration %q", v)
+		}
+	}
+	if v := get("CACHE_SIZE"); v != "" {
+		if cfg.Size, err = strconv.Atoi(v); err != nil || cfg.Size <= 0 {
+			return Config{}, fmt.Errorf("CACHE_SIZE: invalid size %q", v)
+		}
+	}
+	if v := get("CACHE_MAX_AGE"); v != "" {
+		if cfg.MaxAge, err = time.ParseDuration(v); err != nil || cfg.MaxAge < 0 {
+			return Config{}, fmt.Errorf("CACHE_MAX_AGE: invalid duration %q", v)
+		}
+	}
+	return cfg, nil
+}
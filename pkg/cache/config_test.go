@@ -0,0 +1,39 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigFrom(t *testing.T) {
+	tests := []struct {
+		name          string
+		env           map[string]string
+		expected      Config
+		expectedError string
+	}{
+		{name: "Defaults", expected: Config{TTL: time.Minute, Size: 1000, MaxAge: 30 * time.Second}},
+		{name: "Configured", env: map[string]string{"CACHE_TTL": "5m", "CACHE_SIZE": "10", "CACHE_MAX_AGE": "0s"}, expected: Config{TTL: 5 * time.Minute, Size: 10}},
+		{name: "Disabled", env: map[string]string{"CACHE_TTL": "off"}, expected: Config{Size: 1000, MaxAge: 30 * time.Second}},
+		{name: "Bad TTL", env: map[string]string{"CACHE_TTL": "60"}, expectedError: `CACHE_TTL: invalid duration "60"`},
+		{name: "Bad size", env: map[string]string{"CACHE_SIZE": "0"}, expectedError: `CACHE_SIZE: invalid size "0"`},
+		{name: "Negative max age", env: map[string]string{"CACHE_MAX_AGE": "-1s"}, expectedError: `CACHE_MAX_AGE: invalid duration "-1s"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := ConfigFrom(func(key string) (string, bool) {
+				v, ok := tt.env[key]
+				return v, ok
+			})
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, cfg)
+		})
+	}
+}
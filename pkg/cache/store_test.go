@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1000, 0)
+	lru := NewLRU(2)
+	lru.now = func() time.Time { return now }
+
+	get := func(key string) string {
+		value, ok, err := lru.Get(ctx, key)
+		assert.NoError(t, err)
+		if !ok {
+			return ""
+		}
+		return string(value)
+	}
+
+	assert.NoError(t, lru.Set(ctx, "a", []byte("1"), time.Minute))
+	assert.NoError(t, lru.Set(ctx, "b", []byte("2"), time.Minute))
+	assert.Equal(t, "1", get("a"))
+
+	// b is now the least recently used entry.
+	assert.NoError(t, lru.Set(ctx, "c", []byte("3"), time.Minute))
+	assert.Equal(t, 2, lru.Len())
+	assert.Equal(t, "", get("b"))
+	assert.Equal(t, "1", get("a"))
+	assert.Equal(t, "3", get("c"))
+
+	assert.NoError(t, lru.Set(ctx, "a", []byte("4"), time.Second))
+	assert.Equal(t, "4", get("a"))
+	now = now.Add(time.Second)
+	assert.Equal(t, "", get("a"), "expired")
+	assert.Equal(t, "3", get("c"))
+	assert.Equal(t, 1, lru.Len())
+
+	assert.NoError(t, lru.Delete(ctx, "c", "missing"))
+	assert.Equal(t, "", get("c"))
+	assert.Equal(t, 0, lru.Len())
+}
@@ -0,0 +1,15 @@
+package changefeed
+
+import "time"
+
+type Config struct {
+	// PollInterval is how often an open stream checks for events written by
+	// other instances; changes made by this one are sent at once.
+	PollInterval time.Duration
+	// Heartbeat is how often an idle stream sends a comment, so proxies do
+	// not close it.
+	Heartbeat time.Duration
+	// Retention is how long events are kept for clients to resume from;
+	// zero keeps them.
+	Retention time.Duration
+}
@@ -0,0 +1,218 @@
+// Package changefeed streams a tenant's book changes to clients as
+// Server-Sent Events.
+package changefeed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mg4603/go-bookstore-management-system/pkg/models"
+	"github.com/mg4603/go-bookstore-management-system/pkg/utils"
+)
+
+// batchSize bounds the events read from the database at a time.
+const batchSize = 100
+
+// pruneInterval is how often events older than Retention are deleted.
+const pruneInterval = time.Hour
+
+// Feed serves GET /books/events. Each event is sent with its sequence number
+// as the SSE id, so a client that reconnects with Last-Event-ID, as
+// EventSource does, gets every event it missed that is still retained.
+// Without Last-Event-ID the stream starts with the next change. A
+// Last-Event-ID whose following events have been pruned, or that is ahead of
+// the tenant's latest event, as after a restore, is answered with 410 Gone; a
+// stream that falls behind the retained events is sent a reset event and
+// ended.
+// Either way the client must reload the books and reconnect without
+// Last-Event-ID.
+type Feed struct {
+	store  models.BookEventStore
+	cfg    Config
+	logger *slog.Logger
+
+	mu sync.Mutex
+	// changed holds, per tenant, a channel that is closed on the tenant's
+	// next change.
+	changed  map[uint]chan struct{}
+	done     chan struct{}
+	shutdown sync.Once
+}
+
+func NewFeed(store models.BookEventStore, cfg Config, logger *slog.Logger) *Feed {
+	return &Feed{
+		store:   store,
+		cfg:     cfg,
+		logger:  logger.With("component", "changefeed"),
+		changed: map[uint]chan struct{}{},
+		done:    make(chan struct{}),
+	}
+}
+
+// Notify wakes the streams of event's tenant. Set it as the DBModel's
+// OnBookChange; changes made through other instances are picked up by
+// polling instead.
+func (f *Feed) Notify(event models.BookEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if ch, ok := f.changed[event.TenantID]; ok {
+		close(ch)
+		delete(f.changed, event.TenantID)
+	}
+}
+
+func (f *Feed) wait(tenantID uint) <-chan struct{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ch, ok := f.changed[tenantID]
+	if !ok {
+		ch = make(chan struct{})
+		f.changed[tenantID] = ch
+	}
+	return ch
+}
+
+// Shutdown ends every open stream, which would otherwise hold up a graceful
+// server shutdown; register it with http.Server.RegisterOnShutdown.
+func (f *Feed) Shutdown() {
+	f.shutdown.Do(func() { close(f.done) })
+}
+
+// Run prunes events older than Retention until ctx is done.
+func (f *Feed) Run(ctx context.Context) {
+	if f.cfg.Retention == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := f.store.PruneBookEvents(ctx, time.Now().Add(-f.cfg.Retention)); err != nil && ctx.Err() == nil {
+			f.logger.Error("failed to prune book events", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (f *Feed) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r = r.WithContext(utils.ContextWithLogger(r.Context(), f.logger))
+	ctx := r.Context()
+	tenantID, ok := models.TenantFromContext(ctx)
+	if !ok {
+		utils.HandleError(w, r, http.StatusBadRequest, "error streaming book events: no tenant")
+		return
+	}
+
+	var last uint64
+	var err error
+	if id := strings.TrimSpace(r.Header.Get("Last-Event-ID")); id != "" {
+		if last, err = strconv.ParseUint(id, 10, 64); err != nil {
+			utils.HandleError(w, r, http.StatusBadRequest, fmt.Sprintf("bad input: invalid Last-Event-ID %q", id))
+			return
+		}
+		resumable, err := f.resumable(ctx, last)
+		if err != nil {
+			utils.HandleError(w, r, utils.QueryErrorStatus(err), fmt.Sprintf("error reading book events: %s", err))
+			return
+		}
+		if !resumable {
+			utils.HandleError(w, r, http.StatusGone, fmt.Sprintf("cannot resume after Last-Event-ID %d; reconnect without it", last))
+			return
+		}
+	} else if last, err = f.store.LatestBookEventSeq(ctx); err != nil {
+		utils.HandleError(w, r, utils.QueryErrorStatus(err), fmt.Sprintf("error reading book event sequence: %s", err))
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	// Stop nginx and similar proxies from buffering the stream.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		utils.LoggerFromContext(ctx).Error("cannot stream book events", "error", err)
+		return
+	}
+
+	poll := time.NewTicker(f.cfg.PollInterval)
+	defer poll.Stop()
+	heartbeat := time.NewTicker(f.cfg.Heartbeat)
+	defer heartbeat.Stop()
+	for {
+		// Take the channel before reading, so a change committed while the
+		// read runs still wakes the next wait.
+		changed := f.wait(tenantID)
+		events, err := f.store.GetBookEvents(ctx, last, batchSize)
+		if err != nil {
+			// The client reconnects with Last-Event-ID and loses nothing.
+			if !errors.Is(err, context.Canceled) {
+				utils.LoggerFromContext(ctx).Error("error reading book events", "error", err)
+			}
+			return
+		}
+		// Seq has no gaps, so a jump means the events in between were pruned.
+		if len(events) > 0 && events[0].Seq > last+1 {
+			fmt.Fprint(w, "event: reset\ndata: \n\n")
+			rc.Flush()
+			return
+		}
+		for _, event := range events {
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Type, event.Payload); err != nil {
+				return
+			}
+			last = event.Seq
+		}
+		if len(events) > 0 {
+			if err := rc.Flush(); err != nil {
+				return
+			}
+			heartbeat.Reset(f.cfg.Heartbeat)
+		}
+		if len(events) == batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-f.done:
+			return
+		case <-changed:
+		case <-poll.C:
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// resumable reports whether every event after last is still retained and
+// last is not ahead of the latest event, which would skip the events up to
+// it.
+func (f *Feed) resumable(ctx context.Context, last uint64) (bool, error) {
+	events, err := f.store.GetBookEvents(ctx, last, 1)
+	if err != nil {
+		return false, err
+	}
+	if len(events) > 0 {
+		return events[0].Seq == last+1, nil
+	}
+	latest, err := f.store.LatestBookEventSeq(ctx)
+	return latest == last, err
+}
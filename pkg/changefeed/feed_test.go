@@ -0,0 +1,219 @@
+package changefeed
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mg4603/go-bookstore-management-system/pkg/models"
+	"github.com/mg4603/go-bookstore-management-system/pkg/tests"
+	"github.com/stretchr/testify/assert"
+)
+
+type sseEvent struct {
+	id, event, data string
+}
+
+func setup(t *testing.T) (*models.DBModel, *Feed, *httptest.Server) {
+	mockDB, err := tests.Setup()
+	assert.NoError(t, err)
+	sqlDB, _ := mockDB.DB()
+	// Every connection to :memory: opens a new, empty database.
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	db := &models.DBModel{DB: mockDB}
+	// A long poll interval shows that changes made here are sent at once.
+	feed := NewFeed(db, Config{PollInterval: time.Hour, Heartbeat: time.Hour}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	db.OnBookChange = feed.Notify
+	srv := httptest.NewServer(tests.WithDefaultTenant(feed))
+	t.Cleanup(srv.Close)
+	t.Cleanup(feed.Shutdown)
+	return db, feed, srv
+}
+
+// open connects to the feed and returns its events as they arrive.
+func open(t *testing.T, srv *httptest.Server, lastEventID string) (*http.Response, <-chan sseEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	events := make(chan sseEvent)
+	go func() {
+		defer close(events)
+		var e sseEvent
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			field, value, _ := strings.Cut(scanner.Text(), ": ")
+			switch field {
+			case "id":
+				e.id = value
+			case "event":
+				e.event = value
+			case "data":
+				e.data = value
+			case "":
+				if e != (sseEvent{}) {
+					events <- e
+				}
+				e = sseEvent{}
+			}
+		}
+	}()
+	return resp, events
+}
+
+func next(t *testing.T, events <-chan sseEvent) sseEvent {
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an event")
+		return sseEvent{}
+	}
+}
+
+func TestFeedStreamsChanges(t *testing.T) {
+	db, _, srv := setup(t)
+	assert.NoError(t, db.CreateBook(tests.Context(), &models.Book{Name: "Before", Author: "Author", Publication: "Publication"}))
+
+	resp, events := open(t, srv, "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+
+	book := &models.Book{Name: "Book1", Author: "Author1", Publication: "Publication1"}
+	assert.NoError(t, db.CreateBook(tests.Context(), book))
+	book.Name = "Book2"
+	assert.NoError(t, db.UpdateBook(tests.Context(), book))
+	_, err := db.DeleteBook(tests.Context(), int64(book.ID))
+	assert.NoError(t, err)
+
+	assert.Equal(t, sseEvent{id: "2", event: models.EventBookCreated, data: `{"ID":2,"name":"Book1","author":"Author1","publication":"Publication1"}`}, next(t, events),
+		"a stream without Last-Event-ID starts after the latest event")
+	assert.Equal(t, sseEvent{id: "3", event: models.EventBookUpdated, data: `{"ID":2,"name":"Book2","author":"Author1","publication":"Publication1"}`}, next(t, events))
+	assert.Equal(t, "4", next(t, events).id)
+}
+
+func TestFeedResumesFromLastEventID(t *testing.T) {
+	db, _, srv := setup(t)
+	for _, name := range []string{"Book1", "Book2", "Book3"} {
+		assert.NoError(t, db.CreateBook(tests.Context(), &models.Book{Name: name, Author: "Author", Publication: "Publication"}))
+	}
+
+	_, events := open(t, srv, "1")
+	assert.Equal(t, "2", next(t, events).id)
+	assert.Equal(t, "3", next(t, events).id)
+}
+
+func TestFeedIsPerTenant(t *testing.T) {
+	db, _, srv := setup(t)
+	other, err := db.CreateTenant(context.Background(), "other", "Other")
+	assert.NoError(t, err)
+
+	_, events := open(t, srv, "")
+	assert.NoError(t, db.CreateBook(models.WithTenant(context.Background(), other.ID), &models.Book{Name: "Other", Author: "Author", Publication: "Publication"}))
+	assert.NoError(t, db.CreateBook(tests.Context(), &models.Book{Name: "Mine", Author: "Author", Publication: "Publication"}))
+	assert.Contains(t, next(t, events).data, `"name":"Mine"`)
+}
+
+func TestFeedRejectsBadRequests(t *testing.T) {
+	_, feed, srv := setup(t)
+
+	resp, _ := open(t, srv, "latest")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	rec := httptest.NewRecorder()
+	feed.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/books/events", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "requests without a tenant are rejected")
+}
+
+func TestFeedShutdownEndsStreams(t *testing.T) {
+	_, feed, srv := setup(t)
+	_, events := open(t, srv, "")
+
+	feed.Shutdown()
+	select {
+	case _, ok := <-events:
+		assert.False(t, ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream still open after Shutdown")
+	}
+}
+
+func TestFeedRejectsExpiredLastEventID(t *testing.T) {
+	db, _, srv := setup(t)
+	for _, name := range []string{"Book1", "Book2", "Book3"} {
+		assert.NoError(t, db.CreateBook(tests.Context(), &models.Book{Name: name, Author: "Author", Publication: "Publication"}))
+	}
+	_, err := db.PruneBookEvents(context.Background(), time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+
+	resp, _ := open(t, srv, "1")
+	assert.Equal(t, http.StatusGone, resp.StatusCode)
+
+	resp, _ = open(t, srv, "3")
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "nothing after the latest event was pruned")
+}
+
+func TestFeedRejectsLastEventIDAheadOfLatest(t *testing.T) {
+	db, _, srv := setup(t)
+	assert.NoError(t, db.CreateBook(tests.Context(), &models.Book{Name: "Book1", Author: "Author", Publication: "Publication"}))
+
+	resp, _ := open(t, srv, "5")
+	assert.Equal(t, http.StatusGone, resp.StatusCode, "resuming would skip events up to 5")
+
+	resp, _ = open(t, srv, "1")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+// prunedStore hides the event numbered seq, as if it had been pruned.
+type prunedStore struct {
+	models.BookEventStore
+	seq uint64
+}
+
+func (s prunedStore) GetBookEvents(ctx context.Context, afterSeq uint64, limit int) ([]models.BookEvent, error) {
+	events, err := s.BookEventStore.GetBookEvents(ctx, afterSeq, limit)
+	for i, event := range events {
+		if event.Seq == s.seq {
+			return append(events[:i], events[i+1:]...), err
+		}
+	}
+	return events, err
+}
+
+func TestFeedResetsStreamsThatFallBehind(t *testing.T) {
+	db, _, _ := setup(t)
+	feed := NewFeed(prunedStore{BookEventStore: db, seq: 1}, Config{PollInterval: time.Hour, Heartbeat: time.Hour}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	db.OnBookChange = feed.Notify
+	srv := httptest.NewServer(tests.WithDefaultTenant(feed))
+	t.Cleanup(srv.Close)
+	t.Cleanup(feed.Shutdown)
+
+	_, events := open(t, srv, "")
+	assert.NoError(t, db.CreateBook(tests.Context(), &models.Book{Name: "Book1", Author: "Author", Publication: "Publication"}))
+	assert.NoError(t, db.CreateBook(tests.Context(), &models.Book{Name: "Book2", Author: "Author", Publication: "Publication"}))
+
+	assert.Equal(t, "reset", next(t, events).event)
+	select {
+	case _, ok := <-events:
+		assert.False(t, ok, "the stream ends after a reset")
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream still open after a reset")
+	}
+}
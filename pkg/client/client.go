@@ -0,0 +1,262 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout      = 30 * time.Second
+	defaultRetryBackoff = 100 * time.Millisecond
+	// maxErrorBodyBytes bounds how much of an error response is read.
+	maxErrorBodyBytes = 64 << 10
+)
+
+// Book is the API's representation of a book. On update, empty fields are
+// left unchanged by the server.
+type Book struct {
+	ID          uint   `json:"ID,omitempty"`
+	Name        string `json:"name"`
+	Author      string `json:"author"`
+	Publication string `json:"publication"`
+}
+
+type Client struct {
+	baseURL      *url.URL
+	httpClient   *http.Client
+	headers      http.Header
+	maxRetries   int
+	retryBackoff time.Duration
+}
+
+type Option func(*Client)
+
+// WithHTTPClient replaces the underlying HTTP client, e.g. to add transport
+// middleware. Its Timeout is used unless WithTimeout is also given.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		copied := *hc
+		c.httpClient = &copied
+	}
+}
+
+// WithTimeout bounds each attempt of a request, not the call as a whole; use
+// the context for an overall deadline.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.httpClient.Timeout = d
+	}
+}
+
+// WithRetries retries idempotent requests up to max times on network errors,
+// 429 and 5xx responses, doubling the wait from backoff each time unless the
+// server sends Retry-After.
+func WithRetries(max int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = max
+		c.retryBackoff = backoff
+	}
+}
+
+// WithAPIKey sends key as a bearer token on every request.
+func WithAPIKey(key string) Option {
+	return WithHeader("Authorization", "Bearer "+key)
+}
+
+// WithTenant sends every request to the catalogue of the tenant slug names.
+// Any tenant but the server's default also needs that tenant's WithAPIKey.
+func WithTenant(slug string) Option {
+	return WithHeader("X-Tenant", slug)
+}
+
+// WithHeader sets a header on every request.
+func WithHeader(key, value string) Option {
+	return func(c *Client) {
+		c.headers.Set(key, value)
+	}
+}
+
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base URL %q: scheme must be http or https", baseURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+
+	c := &Client{
+		baseURL:      u,
+		httpClient:   &http.Client{Timeout: defaultTimeout},
+		headers:      http.Header{},
+		retryBackoff: defaultRetryBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+func (c *Client) ListBooks(ctx context.Context) ([]Book, error) {
+	var books []Book
+	if err := c.do(ctx, http.MethodGet, "/books/", nil, &books); err != nil {
+		return nil, err
+	}
+	return books, nil
+}
+
+func (c *Client) GetBook(ctx context.Context, id int64) (*Book, error) {
+	var book Book
+	if err := c.do(ctx, http.MethodGet, bookPath(id), nil, &book); err != nil {
+		return nil, err
+	}
+	return &book, nil
+}
+
+func (c *Client) CreateBook(ctx context.Context, b Book) (*Book, error) {
+	var book Book
+	if err := c.do(ctx, http.MethodPost, "/books/", b, &book); err != nil {
+		return nil, err
+	}
+	return &book, nil
+}
+
+func (c *Client) UpdateBook(ctx context.Context, id int64, b Book) (*Book, error) {
+	b.ID = 0
+	var book Book
+	if err := c.do(ctx, http.MethodPut, bookPath(id), b, &book); err != nil {
+		return nil, err
+	}
+	return &book, nil
+}
+
+func (c *Client) DeleteBook(ctx context.Context, id int64) (*Book, error) {
+	var book Book
+	if err := c.do(ctx, http.MethodDelete, bookPath(id), nil, &book); err != nil {
+		return nil, err
+	}
+	return &book, nil
+}
+
+func bookPath(id int64) string {
+	return "/books/" + strconv.FormatInt(id, 10)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("error encoding request: %w", err)
+		}
+	}
+
+	// POST is not idempotent, so a retry could create the book twice.
+	retries := c.maxRetries
+	if method == http.MethodPost {
+		retries = 0
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, method, path, body)
+		if err == nil && resp.StatusCode < 300 {
+			defer resp.Body.Close()
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				return fmt.Errorf("error decoding response: %w", err)
+			}
+			return nil
+		}
+
+		var wait time.Duration
+		if err == nil {
+			apiErr := readAPIError(resp)
+			if attempt >= retries || !retryable(resp.StatusCode) {
+				return apiErr
+			}
+			wait = retryAfter(resp)
+			err = apiErr
+		} else if attempt >= retries || ctx.Err() != nil {
+			return err
+		}
+
+		if wait == 0 {
+			wait = c.retryBackoff << attempt
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w (last error: %v)", ctx.Err(), err)
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	u := *c.baseURL
+	u.Path += path
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range c.headers {
+		req.Header[key] = values
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.httpClient.Do(req)
+}
+
+func readAPIError(resp *http.Response) *APIError {
+	defer resp.Body.Close()
+
+	apiErr := &APIError{StatusCode: resp.StatusCode, RequestID: resp.Header.Get("X-Request-ID")}
+	var body struct {
+		Message   string `json:"message"`
+		RequestID string `json:"requestId"`
+	}
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+	if err := json.Unmarshal(raw, &body); err == nil {
+		apiErr.Message = body.Message
+		if body.RequestID != "" {
+			apiErr.RequestID = body.RequestID
+		}
+	} else {
+		apiErr.Message = strings.TrimSpace(string(raw))
+	}
+	return apiErr
+}
+
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+func retryAfter(resp *http.Response) time.Duration {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
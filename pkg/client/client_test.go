@@ -0,0 +1,275 @@
+package client
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mg4603/go-bookstore-management-system/pkg/controllers"
+	"github.com/mg4603/go-bookstore-management-system/pkg/models"
+	"github.com/mg4603/go-bookstore-management-system/pkg/routes"
+	"github.com/mg4603/go-bookstore-management-system/pkg/tenant"
+	"github.com/mg4603/go-bookstore-management-system/pkg/tests"
+	"github.com/mg4603/go-bookstore-management-system/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// newServer runs the real bookstore routes against an in-memory database.
+func newServer(t *testing.T, wrap func(http.Handler) http.Handler) *httptest.Server {
+	mockDB, err := tests.Setup()
+	assert.NoError(t, err, "failed to setup test database")
+
+	r := mux.NewRouter()
+	db := &models.DBModel{DB: mockDB}
+	catalogue := r.NewRoute().Subrouter()
+	catalogue.Use(tenant.NewResolver(db, tenant.Config{Default: "default"}).Middleware)
+	routes.RegisterBookstoreRoutes(catalogue, controllers.NewBookStoreController(db, slog.New(slog.NewTextHandler(io.Discard, nil)), 0))
+
+	var handler http.Handler = utils.RequestID(r)
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+	srv := httptest.NewServer(handler)
+	t.Cleanup(func() {
+		srv.Close()
+		sqlDB, _ := mockDB.DB()
+		if sqlDB != nil {
+			sqlDB.Close()
+		}
+	})
+	return srv
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name          string
+		baseURL       string
+		expectedError string
+	}{
+		{name: "Valid URL", baseURL: "http://localhost:9010/"},
+		{name: "Valid URL with path", baseURL: "https://api.example.com/bookstore"},
+		{name: "Missing scheme", baseURL: "localhost:9010", expectedError: `invalid base URL "localhost:9010": scheme must be http or https`},
+		{name: "Malformed URL", baseURL: "http://%zz", expectedError: "invalid base URL"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.baseURL)
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestBookRoutes(t *testing.T) {
+	srv := newServer(t, nil)
+	c, err := New(srv.URL)
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	books, err := c.ListBooks(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, books)
+
+	created, err := c.CreateBook(ctx, Book{Name: "Book1", Author: "Author1", Publication: "Publication1"})
+	assert.NoError(t, err)
+	assert.Equal(t, &Book{ID: 1, Name: "Book1", Author: "Author1", Publication: "Publication1"}, created)
+
+	fetched, err := c.GetBook(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, created, fetched)
+
+	updated, err := c.UpdateBook(ctx, 1, Book{Author: "Author2"})
+	assert.NoError(t, err)
+	assert.Equal(t, &Book{ID: 1, Name: "Book1", Author: "Author2", Publication: "Publication1"}, updated)
+
+	books, err = c.ListBooks(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []Book{*updated}, books)
+
+	deleted, err := c.DeleteBook(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, updated, deleted)
+
+	_, err = c.GetBook(ctx, 1)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestErrorResponses(t *testing.T) {
+	srv := newServer(t, nil)
+	c, err := New(srv.URL)
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	_, err = c.GetBook(ctx, 42)
+	var apiErr *APIError
+	assert.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Equal(t, "An error occurred. Please try again later.", apiErr.Message)
+	assert.NotEmpty(t, apiErr.RequestID, "request ID from the server should be kept")
+
+	_, err = c.CreateBook(ctx, Book{Name: "Book1"})
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	_, err = c.UpdateBook(ctx, 42, Book{Name: "Book1"})
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestHeaders(t *testing.T) {
+	var authorization, custom atomic.Value
+	srv := newServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authorization.Store(r.Header.Get("Authorization"))
+			custom.Store(r.Header.Get("X-Team"))
+			// The key is made up, so the server would reject it.
+			r.Header.Del("Authorization")
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	c, err := New(srv.URL, WithAPIKey("bks_secret"), WithHeader("X-Team", "catalogue"))
+	assert.NoError(t, err)
+	_, err = c.ListBooks(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer bks_secret", authorization.Load())
+	assert.Equal(t, "catalogue", custom.Load())
+}
+
+func TestWithTenant(t *testing.T) {
+	srv := newServer(t, nil)
+	ctx := context.Background()
+
+	c, err := New(srv.URL, WithTenant("default"))
+	assert.NoError(t, err)
+	_, err = c.CreateBook(ctx, Book{Name: "Book1", Author: "Author1", Publication: "Publication1"})
+	assert.NoError(t, err)
+	books, err := c.ListBooks(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, books, 1)
+
+	c, err = New(srv.URL, WithTenant("unknown"))
+	assert.NoError(t, err)
+	_, err = c.ListBooks(ctx)
+	assert.ErrorIs(t, err, ErrUnauthorized, "an unknown tenant must not fall back to the default, nor be told apart from a real one")
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name             string
+		failures         int32
+		failureStatus    int
+		retries          int
+		call             func(c *Client) error
+		expectedAttempts int32
+		expectedIs       error
+	}{
+		{
+			name:             "GET recovers after transient errors",
+			failures:         2,
+			failureStatus:    http.StatusServiceUnavailable,
+			retries:          3,
+			call:             func(c *Client) error { _, err := c.ListBooks(context.Background()); return err },
+			expectedAttempts: 3,
+		},
+		{
+			name:             "GET gives up after max retries",
+			failures:         10,
+			failureStatus:    http.StatusInternalServerError,
+			retries:          2,
+			call:             func(c *Client) error { _, err := c.ListBooks(context.Background()); return err },
+			expectedAttempts: 3,
+			expectedIs:       ErrServer,
+		},
+		{
+			name:             "Client errors are not retried",
+			failures:         10,
+			failureStatus:    http.StatusBadRequest,
+			retries:          3,
+			call:             func(c *Client) error { _, err := c.GetBook(context.Background(), 1); return err },
+			expectedAttempts: 1,
+			expectedIs:       ErrBadRequest,
+		},
+		{
+			name:          "POST is never retried",
+			failures:      10,
+			failureStatus: http.StatusServiceUnavailable,
+			retries:       3,
+			call: func(c *Client) error {
+				_, err := c.CreateBook(context.Background(), Book{Name: "Book1", Author: "Author1", Publication: "Publication1"})
+				return err
+			},
+			expectedAttempts: 1,
+			expectedIs:       ErrServer,
+		},
+		{
+			name:             "Rate limiting honours Retry-After",
+			failures:         1,
+			failureStatus:    http.StatusTooManyRequests,
+			retries:          1,
+			call:             func(c *Client) error { _, err := c.ListBooks(context.Background()); return err },
+			expectedAttempts: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			srv := newServer(t, func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if attempts.Add(1) <= tt.failures {
+						w.Header().Set("Retry-After", "0")
+						http.Error(w, `{"message":"try again"}`, tt.failureStatus)
+						return
+					}
+					next.ServeHTTP(w, r)
+				})
+			})
+
+			c, err := New(srv.URL, WithRetries(tt.retries, time.Millisecond))
+			assert.NoError(t, err)
+
+			err = tt.call(c)
+			if tt.expectedIs != nil {
+				assert.ErrorIs(t, err, tt.expectedIs)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedAttempts, attempts.Load())
+		})
+	}
+}
+
+func TestContextAndTimeout(t *testing.T) {
+	srv := newServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	c, err := New(srv.URL)
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = c.ListBooks(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	c, err = New(srv.URL, WithTimeout(20*time.Millisecond))
+	assert.NoError(t, err)
+	_, err = c.ListBooks(context.Background())
+	assert.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "Client.Timeout"), "unexpected error: %v", err)
+}
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Sentinel errors for the classes of failure the server reports. An *APIError
+// matches the one for its status code with errors.Is.
+var (
+	ErrBadRequest           = errors.New("bad request")
+	ErrUnauthorized         = errors.New("unauthorized")
+	ErrForbidden            = errors.New("forbidden")
+	ErrNotFound             = errors.New("not found")
+	ErrNotAcceptable        = errors.New("not acceptable")
+	ErrRequestTooLarge      = errors.New("request too large")
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	ErrRateLimited          = errors.New("rate limited")
+	ErrServer               = errors.New("server error")
+)
+
+// APIError is a non-2xx response, carrying the server's error body.
+type APIError struct {
+	StatusCode int
+	Message    string
+	RequestID  string
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("bookstore API: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.RequestID != "" {
+		msg += " (request " + e.RequestID + ")"
+	}
+	return msg
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrNotAcceptable:
+		return e.StatusCode == http.StatusNotAcceptable
+	case ErrRequestTooLarge:
+		return e.StatusCode == http.StatusRequestEntityTooLarge
+	case ErrUnsupportedMediaType:
+		return e.StatusCode == http.StatusUnsupportedMediaType
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServer:
+		return e.StatusCode >= 500
+	}
+	return false
+}
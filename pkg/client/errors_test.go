@@ -0,0 +1,64 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIError(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           *APIError
+		expectedIs    error
+		expectedError string
+	}{
+		{
+			name:          "Not found",
+			err:           &APIError{StatusCode: http.StatusNotFound, Message: "book with id 7 does not exist in database", RequestID: "abc"},
+			expectedIs:    ErrNotFound,
+			expectedError: "bookstore API: 404 Not Found: book with id 7 does not exist in database (request abc)",
+		},
+		{
+			name:          "Bad request",
+			err:           &APIError{StatusCode: http.StatusBadRequest},
+			expectedIs:    ErrBadRequest,
+			expectedError: "bookstore API: 400 Bad Request",
+		},
+		{
+			name:          "Unauthorized",
+			err:           &APIError{StatusCode: http.StatusUnauthorized},
+			expectedIs:    ErrUnauthorized,
+			expectedError: "bookstore API: 401 Unauthorized",
+		},
+		{
+			name:          "Forbidden",
+			err:           &APIError{StatusCode: http.StatusForbidden},
+			expectedIs:    ErrForbidden,
+			expectedError: "bookstore API: 403 Forbidden",
+		},
+		{
+			name:          "Server error",
+			err:           &APIError{StatusCode: http.StatusBadGateway, Message: "upstream"},
+			expectedIs:    ErrServer,
+			expectedError: "bookstore API: 502 Bad Gateway: upstream",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wrapped := fmt.Errorf("wrapped: %w", tt.err)
+			assert.ErrorIs(t, wrapped, tt.expectedIs)
+			assert.EqualError(t, tt.err, tt.expectedError)
+
+			for _, other := range []error{ErrBadRequest, ErrUnauthorized, ErrForbidden, ErrNotFound, ErrServer} {
+				if other != tt.expectedIs {
+					assert.False(t, errors.Is(wrapped, other), "should not match %v", other)
+				}
+			}
+		})
+	}
+}
@@ -1,46 +1,108 @@
 package config
 
 import (
+	"database/sql"
 	"fmt"
-	"os"
+	"log/slog"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
 
 var (
-	db *gorm.DB
+	db       *gorm.DB
+	replicas []*gorm.DB
+	// connectors holds the primary's connector first, then the replicas'.
+	connectors []*dbConnector
 )
 
 type DBOpener func(dialector gorm.Dialector, config *gorm.Config) (*gorm.DB, error)
 
-type EnvLoader func() error
+// Connect opens the primary and, lazily, any replicas in
+// DB_REPLICA_ADDRS, which share the primary's credentials and database name.
+func Connect(opener DBOpener, cfg Database, logger *slog.Logger) error {
+	connectors = nil
+	primary := &mysql.Config{}
+	var err error
+	if db, err = open(opener, cfg, "", primary, logger); err != nil {
+		return fmt.Errorf("error connecting to database: %w", err)
+	}
 
-func Connect(opener DBOpener, loader EnvLoader) error {
-	if err := loader(); err != nil {
-		return fmt.Errorf("error while loading .env file: %w", err)
+	// Replicas reuse the server version the primary reported, so opening one
+	// does not query it and a replica that is down cannot hold up startup.
+	replicas = nil
+	for _, addr := range cfg.ReplicaAddrs {
+		replicaConfig := *primary
+		replicaConfig.SkipInitializeWithVersion = true
+		replica, err := open(opener, cfg, addr, &replicaConfig, logger)
+		if err != nil {
+			return fmt.Errorf("error connecting to replica %s: %w", addr, err)
+		}
+		replicas = append(replicas, replica)
 	}
 
-	dbUserName := os.Getenv("DB_USER_NAME")
-	dbPassword := os.Getenv("DB_PASSWORD")
-	dbName := os.Getenv("DB_NAME")
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("error configuring connection pool: %w", err)
+	}
+	pool := cfg.Pool
+	logger.Info("database connection established",
+		"db_name", cfg.Name,
+		"db_user", cfg.UserName,
+		"replicas", len(replicas),
+		"max_open_conns", sqlDB.Stats().MaxOpenConnections,
+		"max_idle_conns", pool.MaxIdleConns,
+		"conn_max_lifetime", pool.ConnMaxLifetime.String(),
+		"conn_max_idle_time", pool.ConnMaxIdleTime.String(),
+		"prepare_stmt", pool.PrepareStmt,
+		"log_level", pool.LogLevel,
+	)
+	return nil
+}
 
-	if dbUserName == "" || dbPassword == "" || dbName == "" {
-		return fmt.Errorf("missing one or more environment variables: DB_USER_NAME, DB_PASSWORD, DB_NAME")
+// open opens a pool at addr, or the driver's default address if it is empty.
+// dialectorConfig is updated with what the dialector learnt on opening, such
+// as the server version.
+func open(opener DBOpener, cfg Database, addr string, dialectorConfig *mysql.Config, logger *slog.Logger) (*gorm.DB, error) {
+	dialectorConfig.DSNConfig = dsnConfig(cfg, addr)
+	current, err := newConnector(cfg, addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database settings: %w", err)
 	}
-	dsn := fmt.Sprintf("%s:%s@/%s?charset=utf8&parseTime=True&loc=Local",
-		dbUserName, dbPassword, dbName)
+	connector := &dbConnector{addr: addr}
+	connector.current.Store(current)
 
-	var err error
-	db, err = opener(mysql.Open(dsn), &gorm.Config{})
+	conn := sql.OpenDB(connector)
+	dialectorConfig.Conn = conn
+	dialector := mysql.New(*dialectorConfig).(*mysql.Dialector)
+	gormDB, err := opener(dialector, &gorm.Config{
+		PrepareStmt: cfg.Pool.PrepareStmt,
+		Logger:      gormLogger(logger, cfg.Pool.LogLevel),
+	})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	*dialectorConfig = *dialector.Config
 
+	sqlDB, err := gormDB.DB()
 	if err != nil {
-		return fmt.Errorf("error connecting to database: %w", err)
+		return nil, fmt.Errorf("error configuring connection pool: %w", err)
 	}
-	fmt.Println("Database connection established!")
-	return nil
+	sqlDB.SetMaxOpenConns(cfg.Pool.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.Pool.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.Pool.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.Pool.ConnMaxIdleTime)
+
+	connectors = append(connectors, connector)
+	return gormDB, nil
 }
 
 func GetDB() *gorm.DB {
 	return db
 }
+
+// GetReplicas returns the replica pools, in DB_REPLICA_ADDRS order.
+func GetReplicas() []*gorm.DB {
+	return replicas
+}
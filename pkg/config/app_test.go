@@ -1,89 +1,51 @@
 package config
 
 import (
+	"bytes"
 	"errors"
-	"os"
+	"io"
+	"log/slog"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
-	_ "gorm.io/driver/mysql"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 func mockOpen(dialector gorm.Dialector, config *gorm.Config) (*gorm.DB, error) {
-	mockDB := &gorm.DB{}
-	return mockDB, nil
+	return gorm.Open(sqlite.Open(":memory:"), config)
 }
 
 func mockOpenWithError(dialector gorm.Dialector, config *gorm.Config) (*gorm.DB, error) {
 	return nil, errors.New("Mocked connection error")
 }
 
-func mockLoadEnvSuccess() error {
-	return nil
-}
-
-func mockLoadEnvFailure() error {
-	return errors.New("mocked environment loading error")
+func testDatabase() Database {
+	pool, _ := PoolConfigFrom(func(string) (string, bool) { return "", false })
+	return Database{UserName: "testuser", Password: "testpass", Name: "testdb", Pool: pool}
 }
 
 func TestConnect(t *testing.T) {
 	tests := []struct {
 		name         string
-		envVars      map[string]string
 		mockOpenFunc DBOpener
-		mockLoader   EnvLoader
 		expectError  bool
 	}{
 		{
-			name: "Successful Connection",
-			envVars: map[string]string{
-				"DB_USER_NAME": "testuser",
-				"DB_PASSWORD":  "testpass",
-				"DB_NAME":      "testdb",
-			},
+			name:         "Successful Connection",
 			mockOpenFunc: mockOpen,
-			mockLoader:   mockLoadEnvSuccess,
 			expectError:  false,
 		},
 		{
-			name: "Missing environment variables",
-			envVars: map[string]string{
-				"DB_USER_NAME": "testuser",
-				"DB_PASSWORD":  "testpass",
-			},
+			name:         "database connection error",
 			mockOpenFunc: mockOpenWithError,
-			mockLoader:   mockLoadEnvFailure,
-			expectError:  true,
-		},
-		{
-			name: "database connection error",
-			envVars: map[string]string{
-				"DB_USER_NAME": "testuser",
-				"DB_PASSWORD":  "testpass",
-				"DB_NAME":      "testdb",
-			},
-			mockOpenFunc: mockOpenWithError,
-			mockLoader:   mockLoadEnvFailure,
 			expectError:  true,
 		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-
-			for key, value := range tc.envVars {
-				err := os.Setenv(key, value)
-				assert.NoError(t, err, "failed to set environment variables %s: %v", key, err)
-
-			}
-			defer func() {
-				for key := range tc.envVars {
-					os.Unsetenv(key)
-				}
-			}()
-
-			err := Connect(tc.mockOpenFunc, tc.mockLoader)
+			err := Connect(tc.mockOpenFunc, testDatabase(), slog.New(slog.NewTextHandler(io.Discard, nil)))
 
 			if tc.expectError {
 				assert.Error(t, err, "expected an error but got nil")
@@ -94,3 +56,33 @@ func TestConnect(t *testing.T) {
 		})
 	}
 }
+
+func TestConnectConfiguresPool(t *testing.T) {
+	cfg := testDatabase()
+	cfg.Pool.MaxOpenConns = 7
+	cfg.Pool.MaxIdleConns = 3
+	cfg.Pool.PrepareStmt = true
+	cfg.Pool.LogLevel = "info"
+
+	var logs bytes.Buffer
+	var gormConfig *gorm.Config
+	opener := func(dialector gorm.Dialector, config *gorm.Config) (*gorm.DB, error) {
+		gormConfig = config
+		return mockOpen(dialector, config)
+	}
+
+	err := Connect(opener, cfg, slog.New(slog.NewJSONHandler(&logs, nil)))
+	assert.NoError(t, err)
+	assert.True(t, gormConfig.PrepareStmt)
+
+	sqlDB, err := GetDB().DB()
+	assert.NoError(t, err)
+	assert.Equal(t, 7, sqlDB.Stats().MaxOpenConnections)
+	assert.Contains(t, logs.String(), `"max_open_conns":7,"max_idle_conns":3,"conn_max_lifetime":"30m0s","conn_max_idle_time":"5m0s","prepare_stmt":true,"log_level":"info"`)
+	assert.NotContains(t, logs.String(), "testpass")
+
+	// At info level every statement is logged through the application logger.
+	logs.Reset()
+	assert.NoError(t, GetDB().Exec("SELECT 1").Error)
+	assert.Contains(t, logs.String(), "SELECT 1")
+}
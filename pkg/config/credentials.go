@@ -0,0 +1,161 @@
+package config
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+// dbConnector opens each connection with the credentials current at the time,
+// so they can be rotated without replacing the pool.
+type dbConnector struct {
+	addr    string
+	current atomic.Value // driver.Connector
+}
+
+func (c *dbConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.current.Load().(driver.Connector).Connect(ctx)
+}
+
+func (c *dbConnector) Driver() driver.Driver {
+	return c.current.Load().(driver.Connector).Driver()
+}
+
+// newConnector is replaced in tests, which have no MySQL server.
+var newConnector = func(cfg Database, addr string) (driver.Connector, error) {
+	return mysql.NewConnector(dsnConfig(cfg, addr))
+}
+
+// dsnConfig sets the credentials as fields rather than formatting a DSN, so
+// a password containing @, / or ? needs no escaping.
+func dsnConfig(cfg Database, addr string) *mysql.Config {
+	dsnConfig := mysql.NewConfig()
+	dsnConfig.User = cfg.UserName
+	dsnConfig.Passwd = string(cfg.Password)
+	dsnConfig.Net = "tcp"
+	// An empty address is the driver's default.
+	dsnConfig.Addr = addr
+	dsnConfig.DBName = cfg.Name
+	dsnConfig.Params = map[string]string{"charset": "utf8"}
+	dsnConfig.ParseTime = true
+	dsnConfig.Loc = time.Local
+	return dsnConfig
+}
+
+// RotateCredentials switches new connections to the primary and replicas to
+// cfg's credentials once a test connection to the primary with them succeeds;
+// a replica that is down does not hold up the rotation. Idle connections are
+// closed so the pools refill with the new credentials; connections in use
+// finish their work undisturbed and are retired by DB_CONN_MAX_LIFETIME.
+func RotateCredentials(ctx context.Context, cfg Database) error {
+	next := make([]driver.Connector, len(connectors))
+	for i, c := range connectors {
+		var err error
+		if next[i], err = newConnector(cfg, c.addr); err != nil {
+			return fmt.Errorf("invalid credentials: %w", err)
+		}
+	}
+	conn, err := next[0].Connect(ctx)
+	if err != nil {
+		return fmt.Errorf("new credentials rejected: %w", err)
+	}
+	conn.Close()
+
+	for i, c := range connectors {
+		c.current.Store(next[i])
+	}
+	for _, pool := range append([]*gorm.DB{db}, replicas...) {
+		sqlDB, err := pool.DB()
+		if err != nil {
+			return fmt.Errorf("error accessing connection pool: %w", err)
+		}
+		sqlDB.SetMaxIdleConns(0)
+		sqlDB.SetMaxIdleConns(cfg.Pool.MaxIdleConns)
+	}
+	return nil
+}
+
+// WatchSecretFiles polls the files named by DB_USER_NAME_FILE and
+// DB_PASSWORD_FILE every SECRET_FILE_POLL_INTERVAL and rotates the database
+// credentials when their contents change. It returns when ctx is done.
+func WatchSecretFiles(ctx context.Context, cfg *Config, logger *slog.Logger) {
+	w := newSecretWatcher(cfg)
+	if len(w.files) == 0 || cfg.SecretFilePollInterval == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(cfg.SecretFilePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// A failed rotation is retried on the next tick, so a password
+		// written before the server accepts it is picked up eventually.
+		if rotated, err := w.poll(ctx); err != nil {
+			logger.Error("failed to rotate database credentials", "error", err)
+		} else if rotated {
+			logger.Info("database credentials rotated")
+		}
+	}
+}
+
+type secretWatcher struct {
+	db Database
+	// files maps setting keys to the files holding their values.
+	files map[string]string
+}
+
+func newSecretWatcher(cfg *Config) *secretWatcher {
+	w := &secretWatcher{db: cfg.Database, files: map[string]string{}}
+	for _, key := range []string{"DB_USER_NAME", "DB_PASSWORD"} {
+		if v := cfg.values[key]; v.file != "" {
+			w.files[key] = v.file
+		}
+	}
+	return w
+}
+
+func (w *secretWatcher) poll(ctx context.Context) (bool, error) {
+	next := w.db
+	for key, path := range w.files {
+		contents, err := readSecretFile(path)
+		if err != nil {
+			return false, err
+		}
+		switch key {
+		case "DB_USER_NAME":
+			next.UserName = contents
+		case "DB_PASSWORD":
+			next.Password = Secret(contents)
+		}
+	}
+	if next.UserName == w.db.UserName && next.Password == w.db.Password {
+		return false, nil
+	}
+
+	if err := RotateCredentials(ctx, next); err != nil {
+		return false, err
+	}
+	w.db = next
+	return true, nil
+}
+
+// readSecretFile drops the trailing newline most tools leave in secret files.
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading secret file: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	drivermysql "github.com/go-sql-driver/mysql"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type connection struct {
+	addr     string
+	password string
+}
+
+// recordingConnector opens in-memory SQLite connections, recording the
+// address and password each was opened with.
+type recordingConnector struct {
+	connection
+	opened *[]connection
+	mu     *sync.Mutex
+}
+
+func (c recordingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	if c.password == "wrong" {
+		return nil, errors.New("access denied")
+	}
+	c.mu.Lock()
+	*c.opened = append(*c.opened, c.connection)
+	c.mu.Unlock()
+	return c.Driver().Open(":memory:")
+}
+
+func (c recordingConnector) Driver() driver.Driver { return &sqlite3.SQLiteDriver{} }
+
+// fakeMySQL makes Connect open SQLite connections through the rotating
+// connector in place of MySQL ones.
+func fakeMySQL(t *testing.T) (opener DBOpener, opened func() []connection) {
+	var mu sync.Mutex
+	var connections []connection
+	original := newConnector
+	newConnector = func(cfg Database, addr string) (driver.Connector, error) {
+		return recordingConnector{connection: connection{addr, string(cfg.Password)}, opened: &connections, mu: &mu}, nil
+	}
+	t.Cleanup(func() { newConnector = original })
+
+	opener = func(dialector gorm.Dialector, config *gorm.Config) (*gorm.DB, error) {
+		return gorm.Open(&sqlite.Dialector{Conn: dialector.(*mysql.Dialector).Conn}, config)
+	}
+	opened = func() []connection {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]connection(nil), connections...)
+	}
+	return opener, opened
+}
+
+func TestDSNConfig(t *testing.T) {
+	tests := []struct {
+		name         string
+		addr         string
+		expectedAddr string
+	}{
+		{name: "Default address", expectedAddr: "127.0.0.1:3306"},
+		{name: "Replica", addr: "replica:3307", expectedAddr: "replica:3307"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := dsnConfig(Database{UserName: "user", Password: "p@ss/w?rd", Name: "bookstore"}, tc.addr)
+
+			parsed, err := drivermysql.ParseDSN(cfg.FormatDSN())
+			assert.NoError(t, err)
+			assert.Equal(t, "user", parsed.User)
+			assert.Equal(t, "p@ss/w?rd", parsed.Passwd, "the password needs no escaping")
+			assert.Equal(t, tc.expectedAddr, parsed.Addr)
+			assert.Equal(t, "bookstore", parsed.DBName)
+			assert.Equal(t, map[string]string{"charset": "utf8"}, parsed.Params)
+			assert.True(t, parsed.ParseTime)
+			assert.Equal(t, time.Local, parsed.Loc)
+		})
+	}
+}
+
+func TestRotateCredentialsFromSecretFiles(t *testing.T) {
+	opener, opened := fakeMySQL(t)
+	passwordFile := writeFile(t, "db_password", "first\n")
+	t.Setenv("DB_USER_NAME", "user")
+	t.Setenv("DB_NAME", "bookstore")
+	t.Setenv("DB_PASSWORD_FILE", passwordFile)
+
+	cfg, _, err := Load("bookstore", []string{"-env-file", writeFile(t, ".env", "")})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, Secret("first"), cfg.Database.Password)
+	if !assert.NoError(t, Connect(opener, cfg.Database, slog.New(slog.NewTextHandler(io.Discard, nil)))) {
+		return
+	}
+
+	// A transaction in flight during the rotation keeps its connection.
+	tx := GetDB().Begin()
+	assert.NoError(t, tx.Exec("SELECT 1").Error)
+
+	w := newSecretWatcher(cfg)
+	rotated, err := w.poll(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, rotated, "unchanged files do not rotate")
+
+	assert.NoError(t, os.WriteFile(passwordFile, []byte("wrong\n"), 0o600))
+	rotated, err = w.poll(context.Background())
+	assert.ErrorContains(t, err, "new credentials rejected")
+	assert.False(t, rotated)
+
+	assert.NoError(t, os.WriteFile(passwordFile, []byte("second\n"), 0o600))
+	rotated, err = w.poll(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, rotated)
+
+	assert.NoError(t, tx.Exec("SELECT 1").Error)
+	assert.NoError(t, GetDB().Exec("SELECT 1").Error)
+	assert.NoError(t, tx.Commit().Error)
+
+	connections := opened()
+	assert.Equal(t, connection{"", "first"}, connections[0])
+	assert.Equal(t, connection{"", "second"}, connections[len(connections)-1])
+	assert.NotContains(t, connections, connection{"", "wrong"})
+}
+
+func TestConnectReplicas(t *testing.T) {
+	opener, opened := fakeMySQL(t)
+	cfg := testDatabase()
+	cfg.Password = "first"
+	cfg.ReplicaAddrs = []string{"replica-a:3306", "replica-b:3306"}
+
+	if !assert.NoError(t, Connect(opener, cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))) {
+		return
+	}
+	if !assert.Len(t, GetReplicas(), 2) {
+		return
+	}
+	assert.NoError(t, GetReplicas()[1].Exec("SELECT 1").Error)
+	assert.Contains(t, opened(), connection{"replica-b:3306", "first"})
+
+	cfg.Password = "second"
+	assert.NoError(t, RotateCredentials(context.Background(), cfg))
+	assert.NoError(t, GetReplicas()[1].Exec("SELECT 1").Error)
+	connections := opened()
+	assert.Equal(t, connection{"replica-b:3306", "second"}, connections[len(connections)-1])
+}
+
+func TestWatchSecretFilesStopsWithContext(t *testing.T) {
+	cfg := &Config{SecretFilePollInterval: 1, values: map[string]value{
+		"DB_PASSWORD": {raw: "first", source: SourceEnv, file: filepath.Join(t.TempDir(), "missing")},
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		WatchSecretFiles(ctx, cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+		close(done)
+	}()
+	cancel()
+	<-done
+}
@@ -0,0 +1,439 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/mg4603/go-bookstore-management-system/pkg/cache"
+	"github.com/mg4603/go-bookstore-management-system/pkg/changefeed"
+	"github.com/mg4603/go-bookstore-management-system/pkg/cors"
+	"github.com/mg4603/go-bookstore-management-system/pkg/ratelimit"
+	"github.com/mg4603/go-bookstore-management-system/pkg/tenant"
+	"github.com/mg4603/go-bookstore-management-system/pkg/tracing"
+	"github.com/mg4603/go-bookstore-management-system/pkg/utils"
+	"github.com/mg4603/go-bookstore-management-system/pkg/webhooks"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultQueryTimeout = 5 * time.Second
+	defaultPollInterval = 10 * time.Second
+	defaultReplicaCheck = 5 * time.Second
+	defaultHTTPAddr     = "localhost:9010"
+	defaultGRPCAddr     = "localhost:9011"
+	// defaultTenant is the tenant the add_tenants migration creates.
+	defaultTenant  = "default"
+	defaultEnvFile = ".env"
+
+	defaultWebhookPollInterval = time.Second
+	defaultWebhookTimeout      = 10 * time.Second
+	defaultWebhookMaxAttempts  = 8
+	defaultWebhookBackoff      = 10 * time.Second
+	defaultWebhookMaxBackoff   = time.Hour
+	defaultWebhookRetention    = 7 * 24 * time.Hour
+
+	defaultChangeFeedPollInterval = 2 * time.Second
+	defaultChangeFeedHeartbeat    = 15 * time.Second
+	defaultChangeFeedRetention    = 7 * 24 * time.Hour
+)
+
+// Sources, lowest precedence first.
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceDotEnv  = ".env"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// Secret is a string that is redacted when formatted or logged.
+type Secret string
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return "[redacted]"
+}
+
+func (s Secret) GoString() string { return strconv.Quote(s.String()) }
+
+func (s Secret) LogValue() slog.Value { return slog.StringValue(s.String()) }
+
+type Database struct {
+	UserName     string
+	Password     Secret
+	Name         string
+	Pool         PoolConfig
+	QueryTimeout time.Duration
+	// ReplicaAddrs lists read replicas; reads use the primary when empty.
+	ReplicaAddrs         []string
+	ReplicaCheckInterval time.Duration
+}
+
+// Config is the application configuration. Load resolves each setting from,
+// in increasing precedence, its default, the config file, .env, the
+// environment and command-line flags.
+type Config struct {
+	Database     Database
+	MaxBodyBytes int64
+	HTTPAddr     string
+	GRPCAddr     string
+	// SecretFilePollInterval is how often WatchSecretFiles checks for
+	// rotated credentials.
+	SecretFilePollInterval time.Duration
+	Cache                  cache.Config
+	CORS                   cors.Config
+	RateLimit              ratelimit.Config
+	Tenant                 tenant.Config
+	Webhooks               webhooks.Config
+	ChangeFeed             changefeed.Config
+	Tracing                tracing.Config
+
+	values map[string]value
+}
+
+type value struct {
+	raw    string
+	source string
+	// file is set when the value was read from a _FILE setting.
+	file string
+}
+
+// Load parses the flags in args and resolves the configuration. It returns
+// the arguments left after the flags. -config names a YAML file (CONFIG_FILE
+// in the environment also works); -env-file names a .env file, which is
+// optional unless given explicitly.
+func Load(name string, args []string) (*Config, []string, error) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	configFile := flags.String("config", os.Getenv("CONFIG_FILE"), "YAML config file")
+	envFile := flags.String("env-file", "", "env file (default "+defaultEnvFile+", ignored if missing)")
+	keys := map[string]string{}
+	for _, s := range Settings {
+		keys[flagName(s.Key)] = s.Key
+		flags.String(flagName(s.Key), "", s.Usage)
+		if s.File {
+			keys[flagName(s.Key+fileSuffix)] = s.Key + fileSuffix
+			flags.String(flagName(s.Key+fileSuffix), "", "file containing the "+s.Usage)
+		}
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	cfg := &Config{values: map[string]value{}}
+	if *configFile != "" {
+		fileValues, err := readConfigFile(*configFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := cfg.set(fileValues, SourceFile); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	dotEnv, err := readEnvFile(*envFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cfg.set(dotEnv, SourceDotEnv); err != nil {
+		return nil, nil, err
+	}
+
+	env := map[string]string{}
+	for _, s := range Settings {
+		if v, ok := os.LookupEnv(s.Key); ok {
+			env[s.Key] = v
+		}
+		if v, ok := os.LookupEnv(s.Key + fileSuffix); ok && s.File {
+			env[s.Key+fileSuffix] = v
+		}
+	}
+	if err := cfg.set(env, SourceEnv); err != nil {
+		return nil, nil, err
+	}
+
+	set := map[string]string{}
+	flags.Visit(func(f *flag.Flag) {
+		if key, ok := keys[f.Name]; ok {
+			set[key] = f.Value.String()
+		}
+	})
+	if err := cfg.set(set, SourceFlag); err != nil {
+		return nil, nil, err
+	}
+
+	if err := cfg.parse(); err != nil {
+		return nil, nil, err
+	}
+	return cfg, flags.Args(), nil
+}
+
+// Lookup returns the resolved raw value of a setting.
+func (c *Config) Lookup(key string) (string, bool) {
+	v, ok := c.values[key]
+	return v.raw, ok
+}
+
+// Print writes every setting with its value and where the value came from.
+// Secrets are redacted.
+func (c *Config) Print(w io.Writer) error {
+	for _, s := range Settings {
+		v, ok := c.values[s.Key]
+		if !ok {
+			v.source = SourceDefault
+		}
+		raw, source := v.raw, v.source
+		if s.Secret {
+			raw = Secret(raw).String()
+		}
+		if v.file != "" {
+			source += " " + v.file
+		}
+		if _, err := fmt.Fprintf(w, "%s=%s\t# %s\n", s.Key, raw, source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// set records one source's values, reading the files named by _FILE keys.
+func (c *Config) set(values map[string]string, source string) error {
+	for key, raw := range values {
+		s, ok := fileSetting(key)
+		if !ok {
+			c.values[key] = value{raw: raw, source: source}
+			continue
+		}
+		if _, ok := values[s.Key]; ok {
+			return fmt.Errorf("%s and %s are both set in %s", s.Key, key, source)
+		}
+		contents, err := readSecretFile(raw)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		c.values[s.Key] = value{raw: contents, source: source, file: raw}
+	}
+	return nil
+}
+
+// parse validates every setting, reporting all invalid ones together.
+func (c *Config) parse() error {
+	var errs []error
+	get := func(key string) string { v, _ := c.Lookup(key); return v }
+
+	c.Database = Database{
+		UserName:             get("DB_USER_NAME"),
+		Password:             Secret(get("DB_PASSWORD")),
+		Name:                 get("DB_NAME"),
+		QueryTimeout:         defaultQueryTimeout,
+		ReplicaAddrs:         utils.SplitList(get("DB_REPLICA_ADDRS")),
+		ReplicaCheckInterval: defaultReplicaCheck,
+	}
+	for _, key := range []string{"DB_USER_NAME", "DB_PASSWORD", "DB_NAME"} {
+		if get(key) == "" {
+			errs = append(errs, fmt.Errorf("%s: required", key))
+		}
+	}
+	var err error
+	if c.Database.Pool, err = PoolConfigFrom(c.Lookup); err != nil {
+		errs = append(errs, err)
+	}
+	if v := get("DB_QUERY_TIMEOUT"); v != "" {
+		if c.Database.QueryTimeout, err = time.ParseDuration(v); err != nil || c.Database.QueryTimeout < 0 {
+			errs = append(errs, fmt.Errorf("DB_QUERY_TIMEOUT: invalid duration %q", v))
+		}
+	}
+
+	if v := get("DB_REPLICA_CHECK_INTERVAL"); v != "" {
+		if c.Database.ReplicaCheckInterval, err = time.ParseDuration(v); err != nil || c.Database.ReplicaCheckInterval <= 0 {
+			errs = append(errs, fmt.Errorf("DB_REPLICA_CHECK_INTERVAL: invalid duration %q", v))
+		}
+	}
+
+	c.MaxBodyBytes = utils.MaxBodyBytes
+	if v := get("MAX_BODY_BYTES"); v != "" {
+		if c.MaxBodyBytes, err = strconv.ParseInt(v, 10, 64); err != nil || c.MaxBodyBytes <= 0 {
+			errs = append(errs, fmt.Errorf("MAX_BODY_BYTES: invalid size %q", v))
+		}
+	}
+	c.SecretFilePollInterval = defaultPollInterval
+	if v := get("SECRET_FILE_POLL_INTERVAL"); v != "" {
+		if c.SecretFilePollInterval, err = time.ParseDuration(v); err != nil || c.SecretFilePollInterval < 0 {
+			errs = append(errs, fmt.Errorf("SECRET_FILE_POLL_INTERVAL: invalid duration %q", v))
+		}
+	}
+	if c.HTTPAddr = get("HTTP_ADDR"); c.HTTPAddr == "" {
+		c.HTTPAddr = defaultHTTPAddr
+	}
+	if c.GRPCAddr = get("GRPC_ADDR"); c.GRPCAddr == "" {
+		c.GRPCAddr = defaultGRPCAddr
+	}
+
+	if c.Cache, err = cache.ConfigFrom(c.Lookup); err != nil {
+		errs = append(errs, err)
+	}
+	if c.CORS, err = cors.ConfigFrom(c.Lookup); err != nil {
+		errs = append(errs, fmt.Errorf("CORS: %w", err))
+	}
+	if c.RateLimit, err = ratelimit.ConfigFrom(c.Lookup); err != nil {
+		errs = append(errs, err)
+	}
+	c.Webhooks = webhooks.Config{
+		PollInterval: defaultWebhookPollInterval,
+		Timeout:      defaultWebhookTimeout,
+		MaxAttempts:  defaultWebhookMaxAttempts,
+		Backoff:      defaultWebhookBackoff,
+		MaxBackoff:   defaultWebhookMaxBackoff,
+		Retention:    defaultWebhookRetention,
+	}
+	c.ChangeFeed = changefeed.Config{
+		PollInterval: defaultChangeFeedPollInterval,
+		Heartbeat:    defaultChangeFeedHeartbeat,
+		Retention:    defaultChangeFeedRetention,
+	}
+	durations := []struct {
+		key      string
+		value    *time.Duration
+		positive bool
+	}{
+		{"WEBHOOK_POLL_INTERVAL", &c.Webhooks.PollInterval, false},
+		{"WEBHOOK_TIMEOUT", &c.Webhooks.Timeout, true},
+		{"WEBHOOK_BACKOFF", &c.Webhooks.Backoff, true},
+		{"WEBHOOK_MAX_BACKOFF", &c.Webhooks.MaxBackoff, true},
+		{"WEBHOOK_LOG_RETENTION", &c.Webhooks.Retention, false},
+		{"CHANGE_FEED_POLL_INTERVAL", &c.ChangeFeed.PollInterval, true},
+		{"CHANGE_FEED_HEARTBEAT", &c.ChangeFeed.Heartbeat, true},
+		{"CHANGE_FEED_RETENTION", &c.ChangeFeed.Retention, false},
+	}
+	for _, d := range durations {
+		v := get(d.key)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed < 0 || (d.positive && parsed == 0) {
+			errs = append(errs, fmt.Errorf("%s: invalid duration %q", d.key, v))
+			continue
+		}
+		*d.value = parsed
+	}
+	if v := get("WEBHOOK_MAX_ATTEMPTS"); v != "" {
+		if c.Webhooks.MaxAttempts, err = strconv.Atoi(v); err != nil || c.Webhooks.MaxAttempts <= 0 {
+			errs = append(errs, fmt.Errorf("WEBHOOK_MAX_ATTEMPTS: invalid count %q", v))
+		}
+	}
+
+	c.Tenant = tenant.Config{
+		Default:    defaultTenant,
+		BaseDomain: strings.ToLower(strings.Trim(strings.TrimSpace(get("TENANT_BASE_DOMAIN")), ".")),
+	}
+	if v, ok := c.Lookup("DEFAULT_TENANT"); ok {
+		c.Tenant.Default = strings.TrimSpace(v)
+	}
+	c.Tracing = tracing.ConfigFrom(c.Lookup)
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return nil
+}
+
+func flagName(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
+}
+
+// readConfigFile flattens nested YAML keys into setting keys, so db:
+// {max_open_conns: 10} sets DB_MAX_OPEN_CONNS. Unknown keys are rejected to
+// catch typos.
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %w", err)
+	}
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+
+	values := map[string]string{}
+	if err := flatten("", doc, values); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+func flatten(prefix string, doc map[string]interface{}, values map[string]string) error {
+	for name, v := range doc {
+		key := strings.ToUpper(strings.ReplaceAll(prefix+name, "-", "_"))
+		if nested, ok := v.(map[string]interface{}); ok {
+			if err := flatten(key+"_", nested, values); err != nil {
+				return err
+			}
+			continue
+		}
+
+		setting, ok := lookupSetting(key)
+		if !ok {
+			if _, ok = fileSetting(key); !ok {
+				return fmt.Errorf("unknown setting %s", key)
+			}
+		}
+		switch v := v.(type) {
+		case nil:
+			values[key] = ""
+		case []interface{}:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+			separator := setting.Separator
+			if separator == "" {
+				separator = ","
+			}
+			values[key] = strings.Join(items, separator)
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+	return nil
+}
+
+// readEnvFile reads path, or .env when path is empty, in which case a missing
+// file is not an error. Variables in it that are not settings, such as the
+// OTLP exporter's OTEL_EXPORTER_OTLP_*, are exported to the process
+// environment unless already set, for the libraries that read them directly.
+func readEnvFile(path string) (map[string]string, error) {
+	optional := path == ""
+	if optional {
+		path = defaultEnvFile
+	}
+	vars, err := godotenv.Read(path)
+	if err != nil {
+		if optional && errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error loading env file: %w", err)
+	}
+
+	values := map[string]string{}
+	for key, v := range vars {
+		if _, ok := lookupSetting(key); ok {
+			values[key] = v
+		} else if _, ok := fileSetting(key); ok {
+			values[key] = v
+		} else if _, set := os.LookupEnv(key); !set {
+			os.Setenv(key, v)
+		}
+	}
+	return values, nil
+}
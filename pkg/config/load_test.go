@@ -0,0 +1,359 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mg4603/go-bookstore-management-system/pkg/changefeed"
+	"github.com/mg4603/go-bookstore-management-system/pkg/tenant"
+	"github.com/mg4603/go-bookstore-management-system/pkg/webhooks"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	configFile := writeFile(t, "bookstore.yaml", `
+db:
+  user_name: from-file
+  password: file-secret
+  name: from-file
+  max_open_conns: 10
+cache_size: 50
+`)
+	envFile := writeFile(t, ".env", "DB_NAME=from-dotenv\nDB_MAX_OPEN_CONNS=20\nCACHE_SIZE=60\n")
+	t.Setenv("DB_MAX_OPEN_CONNS", "30")
+	t.Setenv("CACHE_SIZE", "70")
+
+	cfg, args, err := Load("bookstore", []string{"-config", configFile, "-env-file", envFile, "-cache-size", "80", "migrate", "up"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []string{"migrate", "up"}, args)
+	assert.Equal(t, "from-file", cfg.Database.UserName)
+	assert.Equal(t, "from-dotenv", cfg.Database.Name)
+	assert.Equal(t, 30, cfg.Database.Pool.MaxOpenConns)
+	assert.Equal(t, 80, cfg.Cache.Size)
+	assert.Equal(t, defaultQueryTimeout, cfg.Database.QueryTimeout)
+	assert.Equal(t, defaultHTTPAddr, cfg.HTTPAddr)
+	assert.Equal(t, defaultGRPCAddr, cfg.GRPCAddr)
+
+	var out bytes.Buffer
+	assert.NoError(t, cfg.Print(&out))
+	for _, line := range []string{
+		"DB_USER_NAME=from-file\t# file\n",
+		"DB_PASSWORD=[redacted]\t# file\n",
+		"DB_NAME=from-dotenv\t# .env\n",
+		"DB_MAX_OPEN_CONNS=30\t# env\n",
+		"CACHE_SIZE=80\t# flag\n",
+		"CACHE_TTL=\t# default\n",
+	} {
+		assert.Contains(t, out.String(), line)
+	}
+	assert.NotContains(t, out.String(), "file-secret")
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		check       func(t *testing.T, cfg *Config)
+		expectError string
+	}{
+		{
+			name: "Flat and nested keys, lists",
+			content: `
+DB_USER_NAME: user
+db: {password: pass, name: bookstore, query-timeout: 2s}
+http_addr: ":8080"
+cors:
+  allowed_origins: [https://a.example, https://b.example]
+rate_limit_routes:
+  - POST /books/=1/1m
+  - GET /books/=off
+`,
+			check: func(t *testing.T, cfg *Config) {
+				assert.Equal(t, 2*time.Second, cfg.Database.QueryTimeout)
+				assert.Equal(t, ":8080", cfg.HTTPAddr)
+				assert.Equal(t, []string{"https://a.example", "https://b.example"}, cfg.CORS.AllowedOrigins)
+				assert.Equal(t, 1, cfg.RateLimit.Routes["POST /books/"].Requests)
+				assert.Equal(t, 0, cfg.RateLimit.Routes["GET /books/"].Requests)
+			},
+		},
+		{
+			name:        "Unknown key",
+			content:     "db: {user_name: user, pasword: pass}\n",
+			expectError: "unknown setting DB_PASWORD",
+		},
+		{
+			name:        "Malformed YAML",
+			content:     "db: [\n",
+			expectError: "error parsing config file",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeFile(t, "bookstore.yaml", tc.content)
+			cfg, _, err := Load("bookstore", []string{"-config", path, "-env-file", writeFile(t, ".env", "")})
+			if tc.expectError != "" {
+				assert.ErrorContains(t, err, tc.expectError)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			tc.check(t, cfg)
+		})
+	}
+}
+
+func TestLoadEnvFile(t *testing.T) {
+	t.Setenv("DB_USER_NAME", "user")
+	t.Setenv("DB_PASSWORD", "pass")
+	t.Setenv("DB_NAME", "bookstore")
+
+	t.Run("Missing default .env is ignored", func(t *testing.T) {
+		wd, err := os.Getwd()
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.NoError(t, os.Chdir(t.TempDir()))
+		defer os.Chdir(wd)
+
+		_, _, err = Load("bookstore", nil)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Missing explicit env file", func(t *testing.T) {
+		_, _, err := Load("bookstore", []string{"-env-file", filepath.Join(t.TempDir(), "missing.env")})
+		assert.ErrorContains(t, err, "error loading env file")
+	})
+
+	t.Run("Other variables are exported", func(t *testing.T) {
+		const key = "BOOKSTORE_CONFIG_TEST_EXPORTED"
+		defer os.Unsetenv(key)
+
+		_, _, err := Load("bookstore", []string{"-env-file", writeFile(t, ".env", key+"=yes\n")})
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "yes", os.Getenv(key))
+	})
+}
+
+func TestLoadValidation(t *testing.T) {
+	envFile := writeFile(t, ".env", "DB_USER_NAME=user\nCACHE_SIZE=lots\nMAX_BODY_BYTES=-1\n")
+
+	_, _, err := Load("bookstore", []string{"-env-file", envFile, "-cors-allowed-origins", "ftp://example.com"})
+
+	// Every problem is reported at once.
+	if !assert.Error(t, err) {
+		return
+	}
+	for _, msg := range []string{"DB_PASSWORD: required", "DB_NAME: required", "CACHE_SIZE", "MAX_BODY_BYTES", "CORS: invalid allowed origin"} {
+		assert.ErrorContains(t, err, msg)
+	}
+	assert.NotContains(t, err.Error(), "DB_USER_NAME")
+}
+
+func TestSecret(t *testing.T) {
+	db := Database{UserName: "user", Password: "hunter2"}
+
+	for _, format := range []string{"%v", "%+v", "%#v", "%s"} {
+		assert.NotContains(t, fmt.Sprintf(format, db), "hunter2", format)
+	}
+	assert.Equal(t, "", Secret("").String())
+	assert.Equal(t, "hunter2", string(db.Password))
+}
+
+func TestLoadSecretFiles(t *testing.T) {
+	t.Setenv("DB_USER_NAME", "user")
+	t.Setenv("DB_NAME", "bookstore")
+	passwordFile := writeFile(t, "db_password", "s3cret\r\n")
+
+	tests := []struct {
+		name        string
+		env         map[string]string
+		args        []string
+		expected    Secret
+		expectError string
+	}{
+		{name: "Environment", env: map[string]string{"DB_PASSWORD_FILE": passwordFile}, expected: "s3cret"},
+		{name: "Flag", args: []string{"-db-password-file", passwordFile}, expected: "s3cret"},
+		{
+			name:     "Flag overrides plain environment value",
+			env:      map[string]string{"DB_PASSWORD": "plain"},
+			args:     []string{"-db-password-file", passwordFile},
+			expected: "s3cret",
+		},
+		{
+			name:        "Both in one source",
+			env:         map[string]string{"DB_PASSWORD": "plain", "DB_PASSWORD_FILE": passwordFile},
+			expectError: "DB_PASSWORD and DB_PASSWORD_FILE are both set in env",
+		},
+		{
+			name:        "Missing file",
+			env:         map[string]string{"DB_PASSWORD_FILE": filepath.Join(t.TempDir(), "missing")},
+			expectError: "DB_PASSWORD_FILE: error reading secret file",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			for key, value := range tc.env {
+				t.Setenv(key, value)
+			}
+
+			cfg, _, err := Load("bookstore", append([]string{"-env-file", writeFile(t, ".env", "")}, tc.args...))
+			if tc.expectError != "" {
+				assert.ErrorContains(t, err, tc.expectError)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tc.expected, cfg.Database.Password)
+
+			var out bytes.Buffer
+			assert.NoError(t, cfg.Print(&out))
+			assert.Contains(t, out.String(), "DB_PASSWORD=[redacted]\t# ")
+			assert.Contains(t, out.String(), " "+passwordFile+"\n")
+		})
+	}
+}
+
+func TestLoadConfigFileSecretFile(t *testing.T) {
+	passwordFile := writeFile(t, "db_password", "s3cret")
+	configFile := writeFile(t, "bookstore.yaml", "db: {user_name: user, name: bookstore, password_file: "+passwordFile+"}\ncache_ttl_file: /tmp/ttl\n")
+
+	_, _, err := Load("bookstore", []string{"-config", configFile, "-env-file", writeFile(t, ".env", "")})
+	assert.ErrorContains(t, err, "unknown setting CACHE_TTL_FILE")
+
+	configFile = writeFile(t, "bookstore.yaml", "db: {user_name: user, name: bookstore, password_file: "+passwordFile+"}\n")
+	cfg, _, err := Load("bookstore", []string{"-config", configFile, "-env-file", writeFile(t, ".env", "")})
+	if assert.NoError(t, err) {
+		assert.Equal(t, Secret("s3cret"), cfg.Database.Password)
+	}
+}
+
+// loadEnv loads the configuration from env and the required database
+// settings.
+func loadEnv(t *testing.T, env map[string]string) (*Config, error) {
+	t.Helper()
+	t.Setenv("DB_USER_NAME", "user")
+	t.Setenv("DB_PASSWORD", "pass")
+	t.Setenv("DB_NAME", "bookstore")
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	cfg, _, err := Load("bookstore", []string{"-env-file", writeFile(t, ".env", "")})
+	return cfg, err
+}
+
+func TestLoadTenant(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected tenant.Config
+	}{
+		{name: "Defaults", expected: tenant.Config{Default: "default"}},
+		{name: "No default tenant", env: map[string]string{"DEFAULT_TENANT": ""}, expected: tenant.Config{}},
+		{
+			name:     "Base domain",
+			env:      map[string]string{"DEFAULT_TENANT": "acme", "TENANT_BASE_DOMAIN": " Bookstore.Example.com. "},
+			expected: tenant.Config{Default: "acme", BaseDomain: "bookstore.example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := loadEnv(t, tt.env)
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.expected, cfg.Tenant)
+			}
+		})
+	}
+}
+
+func TestLoadWebhooks(t *testing.T) {
+	tests := []struct {
+		name          string
+		env           map[string]string
+		expected      webhooks.Config
+		expectedError string
+	}{
+		{
+			name:     "Defaults",
+			expected: webhooks.Config{PollInterval: time.Second, Timeout: 10 * time.Second, MaxAttempts: 8, Backoff: 10 * time.Second, MaxBackoff: time.Hour, Retention: 7 * 24 * time.Hour},
+		},
+		{
+			name:     "Configured",
+			env:      map[string]string{"WEBHOOK_POLL_INTERVAL": "0s", "WEBHOOK_MAX_ATTEMPTS": "3", "WEBHOOK_BACKOFF": "1s", "WEBHOOK_LOG_RETENTION": "0s"},
+			expected: webhooks.Config{Timeout: 10 * time.Second, MaxAttempts: 3, Backoff: time.Second, MaxBackoff: time.Hour},
+		},
+		{name: "Zero timeout", env: map[string]string{"WEBHOOK_TIMEOUT": "0s"}, expectedError: `WEBHOOK_TIMEOUT: invalid duration "0s"`},
+		{name: "Bad backoff", env: map[string]string{"WEBHOOK_BACKOFF": "10"}, expectedError: `WEBHOOK_BACKOFF: invalid duration "10"`},
+		{name: "Bad attempts", env: map[string]string{"WEBHOOK_MAX_ATTEMPTS": "0"}, expectedError: `WEBHOOK_MAX_ATTEMPTS: invalid count "0"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := loadEnv(t, tt.env)
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.expected, cfg.Webhooks)
+			}
+		})
+	}
+}
+
+func TestLoadChangeFeed(t *testing.T) {
+	tests := []struct {
+		name          string
+		env           map[string]string
+		expected      changefeed.Config
+		expectedError string
+	}{
+		{
+			name:     "Defaults",
+			expected: changefeed.Config{PollInterval: 2 * time.Second, Heartbeat: 15 * time.Second, Retention: 7 * 24 * time.Hour},
+		},
+		{
+			name:     "Configured",
+			env:      map[string]string{"CHANGE_FEED_POLL_INTERVAL": "500ms", "CHANGE_FEED_HEARTBEAT": "30s", "CHANGE_FEED_RETENTION": "0s"},
+			expected: changefeed.Config{PollInterval: 500 * time.Millisecond, Heartbeat: 30 * time.Second},
+		},
+		{name: "Zero poll interval", env: map[string]string{"CHANGE_FEED_POLL_INTERVAL": "0s"}, expectedError: `CHANGE_FEED_POLL_INTERVAL: invalid duration "0s"`},
+		{name: "Bad heartbeat", env: map[string]string{"CHANGE_FEED_HEARTBEAT": "15"}, expectedError: `CHANGE_FEED_HEARTBEAT: invalid duration "15"`},
+		{name: "Negative retention", env: map[string]string{"CHANGE_FEED_RETENTION": "-1h"}, expectedError: `CHANGE_FEED_RETENTION: invalid duration "-1h"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := loadEnv(t, tt.env)
+			if tt.expectedError != "" {
+				assert.ErrorContains(t, err, tt.expectedError)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.expected, cfg.ChangeFeed)
+			}
+		})
+	}
+}
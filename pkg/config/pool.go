@@ -0,0 +1,108 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	gormlogger "gorm.io/gorm/logger"
+)
+
+const (
+	defaultMaxOpenConns    = 25
+	defaultMaxIdleConns    = 10
+	defaultConnMaxLifetime = 30 * time.Minute
+	defaultConnMaxIdleTime = 5 * time.Minute
+	slowQueryThreshold     = 200 * time.Millisecond
+)
+
+var logLevels = map[string]gormlogger.LogLevel{
+	"silent": gormlogger.Silent,
+	"error":  gormlogger.Error,
+	"warn":   gormlogger.Warn,
+	"info":   gormlogger.Info,
+}
+
+type PoolConfig struct {
+	// MaxOpenConns of zero leaves the number of open connections unlimited.
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	PrepareStmt     bool
+	LogLevel        string
+}
+
+// PoolConfigFrom reads DB_MAX_OPEN_CONNS, DB_MAX_IDLE_CONNS,
+// DB_CONN_MAX_LIFETIME, DB_CONN_MAX_IDLE_TIME, DB_PREPARE_STMT and
+// DB_LOG_LEVEL (silent, error, warn or info) through lookup.
+func PoolConfigFrom(lookup func(key string) (string, bool)) (PoolConfig, error) {
+	get := func(key string) string { v, _ := lookup(key); return v }
+	cfg := PoolConfig{
+		MaxOpenConns:    defaultMaxOpenConns,
+		MaxIdleConns:    defaultMaxIdleConns,
+		ConnMaxLifetime: defaultConnMaxLifetime,
+		ConnMaxIdleTime: defaultConnMaxIdleTime,
+		LogLevel:        "warn",
+	}
+	var err error
+
+	if v := get("DB_MAX_OPEN_CONNS"); v != "" {
+		if cfg.MaxOpenConns, err = strconv.Atoi(v); err != nil || cfg.MaxOpenConns < 0 {
+			return PoolConfig{}, fmt.Errorf("DB_MAX_OPEN_CONNS: invalid count %q", v)
+		}
+	}
+	if v := get("DB_MAX_IDLE_CONNS"); v != "" {
+		if cfg.MaxIdleConns, err = strconv.Atoi(v); err != nil || cfg.MaxIdleConns < 0 {
+			return PoolConfig{}, fmt.Errorf("DB_MAX_IDLE_CONNS: invalid count %q", v)
+		}
+	}
+	if v := get("DB_CONN_MAX_LIFETIME"); v != "" {
+		if cfg.ConnMaxLifetime, err = time.ParseDuration(v); err != nil || cfg.ConnMaxLifetime < 0 {
+			return PoolConfig{}, fmt.Errorf("DB_CONN_MAX_LIFETIME: invalid duration %q", v)
+		}
+	}
+	if v := get("DB_CONN_MAX_IDLE_TIME"); v != "" {
+		if cfg.ConnMaxIdleTime, err = time.ParseDuration(v); err != nil || cfg.ConnMaxIdleTime < 0 {
+			return PoolConfig{}, fmt.Errorf("DB_CONN_MAX_IDLE_TIME: invalid duration %q", v)
+		}
+	}
+	if v := get("DB_PREPARE_STMT"); v != "" {
+		if cfg.PrepareStmt, err = strconv.ParseBool(v); err != nil {
+			return PoolConfig{}, fmt.Errorf("DB_PREPARE_STMT: invalid boolean %q", v)
+		}
+	}
+	if v := get("DB_LOG_LEVEL"); v != "" {
+		cfg.LogLevel = strings.ToLower(v)
+		if _, ok := logLevels[cfg.LogLevel]; !ok {
+			return PoolConfig{}, fmt.Errorf("DB_LOG_LEVEL: unknown level %q", v)
+		}
+	}
+
+	// database/sql would silently lower the idle limit; reject it instead so
+	// the reported values are the ones in effect.
+	if cfg.MaxOpenConns > 0 && cfg.MaxIdleConns > cfg.MaxOpenConns {
+		return PoolConfig{}, fmt.Errorf("DB_MAX_IDLE_CONNS: %d exceeds DB_MAX_OPEN_CONNS %d", cfg.MaxIdleConns, cfg.MaxOpenConns)
+	}
+	return cfg, nil
+}
+
+// gormLogger sends gorm's logs through logger so they share its format and
+// destination.
+func gormLogger(logger *slog.Logger, level string) gormlogger.Interface {
+	gormLevel := logLevels[level]
+	slogLevel := slog.LevelWarn
+	switch gormLevel {
+	case gormlogger.Error:
+		slogLevel = slog.LevelError
+	case gormlogger.Info:
+		slogLevel = slog.LevelInfo
+	}
+	return gormlogger.New(slog.NewLogLogger(logger.Handler(), slogLevel), gormlogger.Config{
+		SlowThreshold:             slowQueryThreshold,
+		IgnoreRecordNotFoundError: true,
+		LogLevel:                  gormLevel,
+	})
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoolConfigFrom(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		expected    PoolConfig
+		expectError string
+	}{
+		{
+			name: "Defaults",
+			expected: PoolConfig{
+				MaxOpenConns:    defaultMaxOpenConns,
+				MaxIdleConns:    defaultMaxIdleConns,
+				ConnMaxLifetime: defaultConnMaxLifetime,
+				ConnMaxIdleTime: defaultConnMaxIdleTime,
+				LogLevel:        "warn",
+			},
+		},
+		{
+			name: "All settings",
+			env: map[string]string{
+				"DB_MAX_OPEN_CONNS":     "50",
+				"DB_MAX_IDLE_CONNS":     "20",
+				"DB_CONN_MAX_LIFETIME":  "1h",
+				"DB_CONN_MAX_IDLE_TIME": "0",
+				"DB_PREPARE_STMT":       "true",
+				"DB_LOG_LEVEL":          "Silent",
+			},
+			expected: PoolConfig{
+				MaxOpenConns:    50,
+				MaxIdleConns:    20,
+				ConnMaxLifetime: time.Hour,
+				PrepareStmt:     true,
+				LogLevel:        "silent",
+			},
+		},
+		{
+			name: "Unlimited open connections",
+			env:  map[string]string{"DB_MAX_OPEN_CONNS": "0", "DB_MAX_IDLE_CONNS": "100"},
+			expected: PoolConfig{
+				MaxIdleConns:    100,
+				ConnMaxLifetime: defaultConnMaxLifetime,
+				ConnMaxIdleTime: defaultConnMaxIdleTime,
+				LogLevel:        "warn",
+			},
+		},
+		{name: "Invalid open connections", env: map[string]string{"DB_MAX_OPEN_CONNS": "-1"}, expectError: "DB_MAX_OPEN_CONNS"},
+		{name: "Invalid idle connections", env: map[string]string{"DB_MAX_IDLE_CONNS": "few"}, expectError: "DB_MAX_IDLE_CONNS"},
+		{name: "Invalid lifetime", env: map[string]string{"DB_CONN_MAX_LIFETIME": "forever"}, expectError: "DB_CONN_MAX_LIFETIME"},
+		{name: "Negative idle time", env: map[string]string{"DB_CONN_MAX_IDLE_TIME": "-1s"}, expectError: "DB_CONN_MAX_IDLE_TIME"},
+		{name: "Invalid prepare flag", env: map[string]string{"DB_PREPARE_STMT": "sometimes"}, expectError: "DB_PREPARE_STMT"},
+		{name: "Unknown log level", env: map[string]string{"DB_LOG_LEVEL": "debug"}, expectError: "DB_LOG_LEVEL"},
+		{name: "More idle than open", env: map[string]string{"DB_MAX_OPEN_CONNS": "5", "DB_MAX_IDLE_CONNS": "10"}, expectError: "exceeds DB_MAX_OPEN_CONNS"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, err := PoolConfigFrom(func(key string) (string, bool) {
+				v, ok := tc.env[key]
+				return v, ok
+			})
+			if tc.expectError != "" {
+				assert.ErrorContains(t, err, tc.expectError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, cfg)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package config
+
+import "strings"
+
+const fileSuffix = "_FILE"
+
+// Setting is one configuration key. The same key is used in the environment
+// and .env, in the config file (where DB_MAX_OPEN_CONNS may also be written
+// as db.max_open_conns) and, lower-cased with dashes, as a command-line flag.
+type Setting struct {
+	Key   string
+	Usage string
+	// Secret values are redacted when the configuration is printed.
+	Secret bool
+	// File settings may instead be read from the file named by the key with a
+	// _FILE suffix, e.g. DB_PASSWORD_FILE, for mounted Docker or Kubernetes
+	// secrets.
+	File bool
+	// Separator joins a list given in the config file; it defaults to a comma.
+	Separator string
+}
+
+var Settings = []Setting{
+	{Key: "DB_USER_NAME", Usage: "database user", File: true},
+	{Key: "DB_PASSWORD", Usage: "database password", Secret: true, File: true},
+	{Key: "DB_NAME", Usage: "database name"},
+	{Key: "DB_MAX_OPEN_CONNS", Usage: "maximum open database connections; 0 is unlimited"},
+	{Key: "DB_MAX_IDLE_CONNS", Usage: "maximum idle database connections"},
+	{Key: "DB_CONN_MAX_LIFETIME", Usage: "maximum lifetime of a database connection"},
+	{Key: "DB_CONN_MAX_IDLE_TIME", Usage: "maximum idle time of a database connection"},
+	{Key: "DB_PREPARE_STMT", Usage: "cache prepared statements"},
+	{Key: "DB_LOG_LEVEL", Usage: "gorm log level: silent, error, warn or info"},
+	{Key: "DB_REPLICA_ADDRS", Usage: "comma-separated host:port of read replicas, which share the primary's credentials"},
+	{Key: "DB_REPLICA_CHECK_INTERVAL", Usage: "how often to health check read replicas"},
+	{Key: "DB_QUERY_TIMEOUT", Usage: "timeout for each database query; 0 disables it"},
+	{Key: "SECRET_FILE_POLL_INTERVAL", Usage: "how often to check secret files for rotated credentials; 0 disables it"},
+	{Key: "MAX_BODY_BYTES", Usage: "maximum request body size in bytes"},
+	{Key: "HTTP_ADDR", Usage: "HTTP listen address"},
+	{Key: "GRPC_ADDR", Usage: "gRPC listen address"},
+	{Key: "CACHE_TTL", Usage: "server-side cache TTL, or off"},
+	{Key: "CACHE_SIZE", Usage: "server-side cache capacity in entries"},
+	{Key: "CACHE_MAX_AGE", Usage: "Cache-Control max-age sent on reads"},
+	{Key: "CORS_ALLOWED_ORIGINS", Usage: "comma-separated CORS origins; empty disables CORS"},
+	{Key: "CORS_ALLOWED_METHODS", Usage: "comma-separated CORS methods"},
+	{Key: "CORS_ALLOWED_HEADERS", Usage: "comma-separated CORS request headers"},
+	{Key: "CORS_EXPOSED_HEADERS", Usage: "comma-separated CORS response headers"},
+	{Key: "CORS_ALLOW_CREDENTIALS", Usage: "allow credentialed CORS requests"},
+	{Key: "CORS_MAX_AGE", Usage: "CORS preflight cache duration"},
+	{Key: "RATE_LIMIT", Usage: "default rate limit, e.g. 100/1m, or off"},
+	{Key: "RATE_LIMIT_ROUTES", Usage: "per-route rate limits, e.g. POST /books/=30/1m; GET /metrics=off", Separator: "; "},
+	{Key: "TRUSTED_PROXIES", Usage: "comma-separated CIDRs whose X-Forwarded-For is trusted"},
+	{Key: "DEFAULT_TENANT", Usage: "slug of the tenant for requests that name none; empty rejects them"},
+	{Key: "TENANT_BASE_DOMAIN", Usage: "domain whose subdomains name tenants, e.g. bookstore.example.com"},
+	{Key: "WEBHOOK_POLL_INTERVAL", Usage: "how often to deliver webhook events from the outbox; 0 disables delivery"},
+	{Key: "WEBHOOK_TIMEOUT", Usage: "timeout for each webhook delivery request"},
+	{Key: "WEBHOOK_MAX_ATTEMPTS", Usage: "attempts at a webhook delivery before it is marked failed"},
+	{Key: "WEBHOOK_BACKOFF", Usage: "wait after a failed webhook delivery, doubled after each further failure"},
+	{Key: "WEBHOOK_MAX_BACKOFF", Usage: "longest wait between webhook delivery attempts"},
+	{Key: "WEBHOOK_LOG_RETENTION", Usage: "how long finished webhook deliveries are kept; 0 keeps them"},
+	{Key: "CHANGE_FEED_POLL_INTERVAL", Usage: "how often book event streams check for changes made by other instances"},
+	{Key: "CHANGE_FEED_HEARTBEAT", Usage: "how often idle book event streams send a keepalive"},
+	{Key: "CHANGE_FEED_RETENTION", Usage: "how long book events are kept for streams to resume from; 0 keeps them"},
+	{Key: "OTEL_TRACES_EXPORTER", Usage: "trace exporter: none, stdout, file or otlp"},
+	{Key: "OTEL_TRACES_FILE", Usage: "trace file for the file exporter"},
+	{Key: "OTEL_SERVICE_NAME", Usage: "service name reported in traces"},
+}
+
+// fileSetting returns the setting named by a _FILE key.
+func fileSetting(key string) (Setting, bool) {
+	base, ok := strings.CutSuffix(key, fileSuffix)
+	if !ok {
+		return Setting{}, false
+	}
+	s, ok := lookupSetting(base)
+	return s, ok && s.File
+}
+
+func lookupSetting(key string) (Setting, bool) {
+	for _, s := range Settings {
+		if s.Key == key {
+			return s, true
+		}
+	}
+	return Setting{}, false
+}
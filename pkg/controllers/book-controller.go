@@ -1,16 +1,22 @@
 package controllers
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/mg4603/go-bookstore-management-system/pkg/models"
 	"github.com/mg4603/go-bookstore-management-system/pkg/utils"
 )
 
+// streamBatchSize is how many books GetBooksHandler reads and writes at a
+// time, which bounds its memory use however large the catalogue is.
+const streamBatchSize = 500
+
 type BookstoreController struct {
 	CreateBook  http.HandlerFunc
 	GetBooks    http.HandlerFunc
@@ -19,173 +25,185 @@ type BookstoreController struct {
 	DeleteBook  http.HandlerFunc
 }
 
-func NewBookStoreController(db *models.DBModel) *BookstoreController {
+// NewBookStoreController lets clients cache successful reads for maxAge;
+// writes are never cached.
+func NewBookStoreController(db models.BookstoreDB, logger *slog.Logger, maxAge time.Duration) *BookstoreController {
+	read := utils.CacheControl("no-cache")
+	if seconds := int(maxAge.Seconds()); seconds > 0 {
+		read = utils.CacheControl(fmt.Sprintf("public, max-age=%d", seconds))
+	}
+	write := utils.CacheControl("no-store")
+
 	return &BookstoreController{
-		CreateBook:  CreateBookHandler(db),
-		GetBooks:    GetBooksHandler(db),
-		GetBookById: GetBookByIdHandler(db),
-		UpdateBook:  UpdateBookHandler(db),
-		DeleteBook:  DeleteBookHandler(db),
+		CreateBook:  withLogger(logger, write(CreateBookHandler(db)).ServeHTTP),
+		GetBooks:    withLogger(logger, read(GetBooksHandler(db)).ServeHTTP),
+		GetBookById: withLogger(logger, read(GetBookByIdHandler(db)).ServeHTTP),
+		UpdateBook:  withLogger(logger, write(UpdateBookHandler(db)).ServeHTTP),
+		DeleteBook:  withLogger(logger, write(DeleteBookHandler(db)).ServeHTTP),
 	}
 }
 
-func CreateBookHandler(db *models.DBModel) http.HandlerFunc {
+func withLogger(logger *slog.Logger, h http.HandlerFunc) http.HandlerFunc {
+	return utils.WithLogger(logger.With("component", "bookstore-controller"), h).ServeHTTP
+}
+
+func CreateBookHandler(db models.BookstoreDB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		createBook := &models.Book{}
 
 		if err := utils.ParseBody(r, createBook); err != nil {
-			utils.HandleError(w, http.StatusBadRequest, fmt.Sprintf("error parsing input into book model: %s", err))
+			utils.HandleError(w, r, utils.ParseErrorStatus(err), fmt.Sprintf("error parsing input into book model: %s", err))
 			return
 		}
 
-		if err := db.CreateBook(createBook); err != nil {
-			utils.HandleError(w, http.StatusInternalServerError, fmt.Sprintf("error while trying to create book: %s", err.Error()))
+		if err := db.CreateBook(r.Context(), createBook); err != nil {
+			utils.HandleError(w, r, queryErrorStatus(err), fmt.Sprintf("error while trying to create book: %s", err.Error()))
 			return
 		}
 
-		w.WriteHeader(http.StatusCreated)
-		if err := json.NewEncoder(w).Encode(createBook); err != nil {
-			utils.HandleError(w, http.StatusInternalServerError, fmt.Sprintf("error occured while encoding created book: %s", err.Error()))
+		if err := utils.WriteResponse(w, r, http.StatusCreated, createBook); err != nil {
+			utils.HandleError(w, r, utils.ResponseErrorStatus(err), fmt.Sprintf("error occured while encoding created book: %s", err.Error()))
 			return
 		}
 	}
 }
 
-func GetBooksHandler(db *models.DBModel) http.HandlerFunc {
+func GetBooksHandler(db models.BookstoreDB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		newBooks, err := db.GetAllBooks()
-		if err != nil {
-			utils.HandleError(w, http.StatusInternalServerError, "error fetching books from database")
+		err := utils.StreamResponse(w, r, http.StatusOK, models.Book{}, func(yield func(interface{}) error) error {
+			return db.EachBook(r.Context(), streamBatchSize, func(batch []models.Book) error {
+				return yield(batch)
+			})
+		})
+		if errors.Is(err, utils.ErrStreamInterrupted) {
+			// Part of the list is already sent; abort the connection so the
+			// client does not mistake it for the whole catalogue.
+			utils.LoggerFromContext(r.Context()).Error("error streaming books", "error", err)
+			panic(http.ErrAbortHandler)
+		}
+		if errors.Is(err, utils.ErrNotAcceptable) {
+			utils.HandleError(w, r, http.StatusNotAcceptable, fmt.Sprintf("error streaming books: %s", err))
 			return
 		}
-
-		if err := json.NewEncoder(w).Encode(newBooks); err != nil {
-			utils.HandleError(w, http.StatusInternalServerError, "error marshalling new books")
+		if err != nil {
+			utils.HandleError(w, r, queryErrorStatus(err), fmt.Sprintf("error fetching books from database: %s", err))
 			return
 		}
 	}
 }
 
-func GetBookByIdHandler(db *models.DBModel) http.HandlerFunc {
+func GetBookByIdHandler(db models.BookstoreDB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		bookId, ok := vars["id"]
 
 		if !ok {
-			utils.HandleError(w, http.StatusBadRequest, "required field (id) is missing")
+			utils.HandleError(w, r, http.StatusBadRequest, "required field (id) is missing")
 			return
 		}
 
 		ID, err := strconv.ParseInt(bookId, 0, 0)
 		if err != nil {
-			utils.HandleError(w, http.StatusBadRequest, "bad input: couldn't parse int id, from string bookId: "+bookId)
+			utils.HandleError(w, r, http.StatusBadRequest, "bad input: couldn't parse int id, from string bookId: "+bookId)
 			return
 		}
 
-		bookDetails, err := db.GetBookById(ID)
+		bookDetails, err := db.GetBookById(r.Context(), ID)
 
 		if err != nil {
-			if err.Error() == fmt.Sprintf("book with ID %d not found", ID) {
-				utils.HandleError(w, http.StatusNotFound, fmt.Sprintf("book with id %d does not exist in database", ID))
+			if errors.Is(err, models.ErrNotFound) {
+				utils.HandleError(w, r, http.StatusNotFound, fmt.Sprintf("book with id %d does not exist in database", ID))
 			} else {
-				utils.HandleError(w, http.StatusInternalServerError, fmt.Sprintf("error occured while trying to fetch record from db: %s", err.Error()))
+				utils.HandleError(w, r, queryErrorStatus(err), fmt.Sprintf("error occured while trying to fetch record from db: %s", err.Error()))
 			}
 			return
 		}
 
-		if err := json.NewEncoder(w).Encode(bookDetails); err != nil {
-			utils.HandleError(w, http.StatusInternalServerError, fmt.Sprintf("error occurred while encoding response: %s", err.Error()))
+		if err := utils.WriteResponse(w, r, http.StatusOK, bookDetails); err != nil {
+			utils.HandleError(w, r, utils.ResponseErrorStatus(err), fmt.Sprintf("error occurred while encoding response: %s", err.Error()))
 			return
 		}
 	}
 }
 
-func UpdateBookHandler(db *models.DBModel) http.HandlerFunc {
+func UpdateBookHandler(db models.BookstoreDB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-
 		updateBook := &models.Book{}
 		if err := utils.ParseBody(r, updateBook); err != nil {
-			utils.HandleError(w, http.StatusBadRequest, fmt.Sprintf("error occurred while trying to parse json input: %s", err.Error()))
+			utils.HandleError(w, r, utils.ParseErrorStatus(err), fmt.Sprintf("error occurred while trying to parse json input: %s", err.Error()))
 			return
 		}
 
 		vars := mux.Vars(r)
 		bookId, ok := vars["id"]
 		if !ok {
-			utils.HandleError(w, http.StatusBadRequest, "required parameter id missing in input")
+			utils.HandleError(w, r, http.StatusBadRequest, "required parameter id missing in input")
 			return
 		}
 
 		ID, err := strconv.ParseInt(bookId, 0, 0)
 		if err != nil {
-			utils.HandleError(w, http.StatusBadRequest, fmt.Sprintf("error parsing int ID from string bookID = %s", bookId))
+			utils.HandleError(w, r, http.StatusBadRequest, fmt.Sprintf("error parsing int ID from string bookID = %s", bookId))
 			return
 		}
 
-		book, err := db.GetBookById(ID)
-		if err != nil {
-			if err.Error() == fmt.Sprintf("book with ID %d not found", ID) {
-				utils.HandleError(w, http.StatusNotFound, fmt.Sprintf("book with ID %d not found; %s", ID, err.Error()))
+		// Fields left empty keep their stored values.
+		updateBook.ID = uint(ID)
+		if err := db.UpdateBook(r.Context(), updateBook); err != nil {
+			if errors.Is(err, models.ErrNotFound) {
+				utils.HandleError(w, r, http.StatusNotFound, fmt.Sprintf("book with ID %d not found; %s", ID, err.Error()))
 			} else {
-				utils.HandleError(w, http.StatusInternalServerError, fmt.Sprintf("error occurred during database lookup: %s", err.Error()))
+				utils.HandleError(w, r, queryErrorStatus(err), fmt.Sprintf("error updating book: %s", err.Error()))
 			}
 			return
 		}
 
-		if updateBook.Name != "" {
-			book.Name = updateBook.Name
-		}
-
-		if updateBook.Author != "" {
-			book.Author = updateBook.Author
-		}
-
-		if updateBook.Publication != "" {
-			book.Publication = updateBook.Publication
-		}
-
-		if err := db.DB.Save(&book).Error; err != nil {
-			utils.HandleError(w, http.StatusInternalServerError, fmt.Sprintf("error updating book: %s", err.Error()))
-			return
-		}
-
-		if err := json.NewEncoder(w).Encode((&book)); err != nil {
-			utils.HandleError(w, http.StatusInternalServerError, fmt.Sprintf("error occurred while trying to encode book for response %s", err.Error()))
+		if err := utils.WriteResponse(w, r, http.StatusOK, updateBook); err != nil {
+			utils.HandleError(w, r, utils.ResponseErrorStatus(err), fmt.Sprintf("error occurred while trying to encode book for response %s", err.Error()))
 			return
 		}
 	}
 }
 
-func DeleteBookHandler(db *models.DBModel) http.HandlerFunc {
+func DeleteBookHandler(db models.BookstoreDB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		bookId, ok := vars["id"]
 		if !ok {
-			utils.HandleError(w, http.StatusBadRequest, "required field (id) is missing")
+			utils.HandleError(w, r, http.StatusBadRequest, "required field (id) is missing")
 			return
 		}
 
 		ID, err := strconv.ParseInt(bookId, 0, 0)
 
 		if err != nil {
-			utils.HandleError(w, http.StatusBadRequest, fmt.Sprintf("bad input; couldn't parse integer id from string bookID: %s", err.Error()))
+			utils.HandleError(w, r, http.StatusBadRequest, fmt.Sprintf("bad input; couldn't parse integer id from string bookID: %s", err.Error()))
 			return
 		}
 
-		book, err := db.DeleteBook(ID)
+		book, err := db.DeleteBook(r.Context(), ID)
 		if err != nil {
-			if err.Error() == fmt.Sprintf("book with ID %d not found", ID) {
-				utils.HandleError(w, http.StatusNotFound, fmt.Sprintf("book with id %d does not exist in database", ID))
+			if errors.Is(err, models.ErrNotFound) {
+				utils.HandleError(w, r, http.StatusNotFound, fmt.Sprintf("book with id %d does not exist in database", ID))
 			} else {
-				utils.HandleError(w, http.StatusInternalServerError, fmt.Sprintf("err while trying to delete book of id %d from db: %s", ID, err.Error()))
+				utils.HandleError(w, r, queryErrorStatus(err), fmt.Sprintf("err while trying to delete book of id %d from db: %s", ID, err.Error()))
 			}
 			return
 		}
 
-		if err := json.NewEncoder(w).Encode(&book); err != nil {
-			utils.HandleError(w, http.StatusInternalServerError, fmt.Sprintf("error occurred while encoding server response: %s", err.Error()))
+		if err := utils.WriteResponse(w, r, http.StatusOK, book); err != nil {
+			utils.HandleError(w, r, utils.ResponseErrorStatus(err), fmt.Sprintf("error occurred while encoding server response: %s", err.Error()))
 			return
 		}
 
 	}
 }
+
+// queryErrorStatus is utils.QueryErrorStatus, except that a request with no
+// tenant or without the fields it needs is the client's to fix.
+func queryErrorStatus(err error) int {
+	if errors.Is(err, models.ErrNoTenant) || errors.Is(err, models.ErrMissingFields) {
+		return http.StatusBadRequest
+	}
+	return utils.QueryErrorStatus(err)
+}
@@ -2,12 +2,20 @@ package controllers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/mg4603/go-bookstore-management-system/pkg/cache"
+	"github.com/mg4603/go-bookstore-management-system/pkg/metrics"
 	"github.com/mg4603/go-bookstore-management-system/pkg/models"
 	"github.com/mg4603/go-bookstore-management-system/pkg/tests"
 	"github.com/mg4603/go-bookstore-management-system/pkg/utils"
@@ -30,7 +38,7 @@ func TestGetBooksHandler(t *testing.T) {
 				}
 
 				for _, book := range books {
-					err := db.CreateBook(&book)
+					err := db.CreateBook(tests.Context(), &book)
 					assert.NoError(t, err)
 				}
 			},
@@ -70,7 +78,7 @@ func TestGetBooksHandler(t *testing.T) {
 			rec := httptest.NewRecorder()
 			req := httptest.NewRequest(http.MethodGet, "/books/", nil)
 
-			handler := utils.SetJSONContentType(GetBooksHandler(db))
+			handler := tests.WithDefaultTenant(utils.SetJSONContentType(GetBooksHandler(db)))
 			handler.ServeHTTP(rec, req)
 
 			assert.Equal(t, tt.expectedStatus, rec.Code)
@@ -79,6 +87,31 @@ func TestGetBooksHandler(t *testing.T) {
 	}
 }
 
+func TestGetBooksHandlerBehindBookCache(t *testing.T) {
+	mockDB, err := tests.Setup()
+	assert.NoError(t, err)
+	defer func() {
+		sqlDB, _ := mockDB.DB()
+		if sqlDB != nil {
+			sqlDB.Close()
+		}
+	}()
+	db := &models.DBModel{DB: mockDB}
+	handler := tests.WithDefaultTenant(utils.SetJSONContentType(GetBooksHandler(cache.NewBookCache(db, cache.NewLRU(10), time.Minute, metrics.NewRegistry()))))
+
+	list := func() string {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/books/", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		return rec.Body.String()
+	}
+	assert.JSONEq(t, `[]`, list())
+
+	// A write that bypasses the cache, as from another instance.
+	assert.NoError(t, db.CreateBook(tests.Context(), &models.Book{Name: "Book1", Author: "Author1", Publication: "Publication1"}))
+	assert.JSONEq(t, `[{"ID":1,"name":"Book1","author":"Author1","publication":"Publication1"}]`, list(), "lists are read from the database, not the cache")
+}
+
 func TestGetBookByIdHandler(t *testing.T) {
 	testCases := []struct {
 		name           string
@@ -92,7 +125,7 @@ func TestGetBookByIdHandler(t *testing.T) {
 			bookId: "1",
 			mockSetup: func(db *models.DBModel) {
 				book := models.Book{Name: "Book1", Author: "Author1", Publication: "Publication1"}
-				err := db.CreateBook(&book)
+				err := db.CreateBook(tests.Context(), &book)
 				assert.NoError(t, err)
 			},
 			expectedStatus: http.StatusOK,
@@ -134,7 +167,7 @@ func TestGetBookByIdHandler(t *testing.T) {
 			req := httptest.NewRequest(http.MethodGet, "/books/{id}", nil)
 			req = mux.SetURLVars(req, map[string]string{"id": tt.bookId})
 
-			handler := utils.SetJSONContentType(GetBookByIdHandler(db))
+			handler := tests.WithDefaultTenant(utils.SetJSONContentType(GetBookByIdHandler(db)))
 			handler.ServeHTTP(rec, req)
 
 			assert.Equal(t, tt.expectedStatus, rec.Code)
@@ -166,7 +199,34 @@ func TestCreateBookHandler(t *testing.T) {
 			mockSetup: func(db *models.DBModel) {
 
 			},
-			expectedStatus: http.StatusInternalServerError,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message":"An error occurred. Please try again later."}`,
+		},
+		{
+			name:      "Misspelled field",
+			inputBody: map[string]string{"name": "Book1", "auther": "Author1", "publication": "Publication1"},
+			mockSetup: func(db *models.DBModel) {
+
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message":"An error occurred. Please try again later."}`,
+		},
+		{
+			name:      "Missing field",
+			inputBody: map[string]string{"name": "Book1"},
+			mockSetup: func(db *models.DBModel) {
+
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message":"An error occurred. Please try again later."}`,
+		},
+		{
+			name:      "Body too large",
+			inputBody: &models.Book{Name: strings.Repeat("x", int(utils.MaxBodyBytes)), Author: "Author1", Publication: "Publication1"},
+			mockSetup: func(db *models.DBModel) {
+
+			},
+			expectedStatus: http.StatusRequestEntityTooLarge,
 			expectedBody:   `{"message":"An error occurred. Please try again later."}`,
 		},
 		{
@@ -202,7 +262,7 @@ func TestCreateBookHandler(t *testing.T) {
 			req := httptest.NewRequest(http.MethodPost, "/books", bytes.NewReader(bodyBytes))
 			rec := httptest.NewRecorder()
 
-			handler := utils.SetJSONContentType(CreateBookHandler(db))
+			handler := tests.WithDefaultTenant(utils.SetJSONContentType(CreateBookHandler(db)))
 			handler.ServeHTTP(rec, req)
 
 			assert.Equal(t, tt.expectedStatus, rec.Code)
@@ -224,7 +284,7 @@ func TestDeleteBookHandler(t *testing.T) {
 			bookId: "1",
 			mockSetup: func(db *models.DBModel) {
 				book := &models.Book{Name: "Book1", Author: "Author1", Publication: "Publication1"}
-				err := db.CreateBook(book)
+				err := db.CreateBook(tests.Context(), book)
 				assert.NoError(t, err)
 			},
 			expectedStatus: http.StatusOK,
@@ -278,7 +338,7 @@ func TestDeleteBookHandler(t *testing.T) {
 			req := httptest.NewRequest(http.MethodDelete, "/books/{id}", nil)
 			req = mux.SetURLVars(req, map[string]string{"id": tc.bookId})
 
-			handler := utils.SetJSONContentType(DeleteBookHandler(db))
+			handler := tests.WithDefaultTenant(utils.SetJSONContentType(DeleteBookHandler(db)))
 			handler.ServeHTTP(rec, req)
 
 			assert.Equal(t, tc.expectedStatus, rec.Code)
@@ -291,7 +351,7 @@ func TestUpdateBookHandler(t *testing.T) {
 	testCases := []struct {
 		name           string
 		bookId         string
-		inputBody      *models.Book
+		inputBody      interface{}
 		mockSetup      func(db *models.DBModel)
 		expectedStatus int
 		expectedBody   string
@@ -302,12 +362,36 @@ func TestUpdateBookHandler(t *testing.T) {
 			inputBody: &models.Book{Name: "Updated Book", Author: "Updated Author", Publication: "Updated Publication"},
 			mockSetup: func(db *models.DBModel) {
 				book := &models.Book{Name: "Original Book", Author: "Original Author", Publication: "Original Publication"}
-				err := db.CreateBook(book)
+				err := db.CreateBook(tests.Context(), book)
 				assert.NoError(t, err)
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody:   `{"ID":1,"name":"Updated Book","author":"Updated Author","publication":"Updated Publication"}`,
 		},
+		{
+			name:      "Empty body",
+			bookId:    "1",
+			inputBody: map[string]string{},
+			mockSetup: func(db *models.DBModel) {
+				book := &models.Book{Name: "Original Book", Author: "Original Author", Publication: "Original Publication"}
+				err := db.CreateBook(tests.Context(), book)
+				assert.NoError(t, err)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message":"An error occurred. Please try again later."}`,
+		},
+		{
+			name:      "All fields empty",
+			bookId:    "1",
+			inputBody: map[string]string{"name": "", "author": "", "publication": ""},
+			mockSetup: func(db *models.DBModel) {
+				book := &models.Book{Name: "Original Book", Author: "Original Author", Publication: "Original Publication"}
+				err := db.CreateBook(tests.Context(), book)
+				assert.NoError(t, err)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"message":"An error occurred. Please try again later."}`,
+		},
 		{
 			name:           "Record not found",
 			bookId:         "2",
@@ -322,7 +406,7 @@ func TestUpdateBookHandler(t *testing.T) {
 			inputBody: &models.Book{Name: "Invalid Update", Author: "Invalid Author", Publication: "Invalid Publication"},
 			mockSetup: func(db *models.DBModel) {
 				book := &models.Book{Name: "Updated Book", Author: "Updated Author", Publication: "Updated Publication"}
-				err := db.CreateBook(book)
+				err := db.CreateBook(tests.Context(), book)
 				assert.NoError(t, err)
 			},
 			expectedStatus: http.StatusBadRequest,
@@ -334,7 +418,7 @@ func TestUpdateBookHandler(t *testing.T) {
 			inputBody: &models.Book{Name: "Database Error"},
 			mockSetup: func(db *models.DBModel) {
 				book := &models.Book{Name: "Original Book", Author: "Original Author", Publication: "Original Publication"}
-				db.CreateBook(book)
+				db.CreateBook(tests.Context(), book)
 				sqlDB, _ := db.DB.DB()
 				if sqlDB != nil {
 					sqlDB.Close()
@@ -367,7 +451,7 @@ func TestUpdateBookHandler(t *testing.T) {
 			req := httptest.NewRequest(http.MethodPut, "/books/{id}", bytes.NewBuffer(body))
 			req = mux.SetURLVars(req, map[string]string{"id": tc.bookId})
 
-			handler := utils.SetJSONContentType(UpdateBookHandler(db))
+			handler := tests.WithDefaultTenant(utils.SetJSONContentType(UpdateBookHandler(db)))
 			handler.ServeHTTP(rec, req)
 
 			assert.Equal(t, tc.expectedStatus, rec.Code)
@@ -376,3 +460,210 @@ func TestUpdateBookHandler(t *testing.T) {
 	}
 
 }
+
+func TestBooksContentNegotiation(t *testing.T) {
+	testCases := []struct {
+		name                string
+		handler             func(db models.BookstoreDB) http.HandlerFunc
+		bookId              string
+		accept              string
+		expectedStatus      int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "Books as CSV",
+			handler:             GetBooksHandler,
+			accept:              "text/csv",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/csv",
+			expectedBody:        "ID,name,author,publication\n1,Book1,Author1,Publication1\n",
+		},
+		{
+			name:                "Books as XML",
+			handler:             GetBooksHandler,
+			accept:              "application/xml",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/xml",
+			expectedBody:        "<books><book><id>1</id><name>Book1</name><author>Author1</author><publication>Publication1</publication></book></books>",
+		},
+		{
+			name:                "Books as NDJSON",
+			handler:             GetBooksHandler,
+			accept:              "application/x-ndjson",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/x-ndjson",
+			expectedBody:        "{\"ID\":1,\"name\":\"Book1\",\"author\":\"Author1\",\"publication\":\"Publication1\"}\n",
+		},
+		{
+			name:                "Single book as CSV is not acceptable",
+			handler:             GetBookByIdHandler,
+			bookId:              "1",
+			accept:              "text/csv",
+			expectedStatus:      http.StatusNotAcceptable,
+			expectedContentType: "application/json",
+			expectedBody:        "{\"message\":\"An error occurred. Please try again later.\"}\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, err := tests.Setup()
+			assert.NoError(t, err)
+			defer func() {
+				sqlDB, _ := mockDB.DB()
+				if sqlDB != nil {
+					sqlDB.Close()
+				}
+			}()
+
+			db := &models.DBModel{DB: mockDB}
+			err = db.CreateBook(tests.Context(), &models.Book{Name: "Book1", Author: "Author1", Publication: "Publication1"})
+			assert.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/books/", nil)
+			req.Header.Set("Accept", tc.accept)
+			req = mux.SetURLVars(req, map[string]string{"id": tc.bookId})
+
+			handler := tests.WithDefaultTenant(utils.NegotiateContentType(tc.handler(db)))
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			assert.Equal(t, tc.expectedContentType, rec.Header().Get("Content-Type"))
+			assert.Equal(t, tc.expectedBody, rec.Body.String())
+		})
+	}
+}
+
+func TestCreateBookRejectsCSVBeforeWriting(t *testing.T) {
+	mockDB, err := tests.Setup()
+	assert.NoError(t, err)
+	defer func() {
+		sqlDB, _ := mockDB.DB()
+		if sqlDB != nil {
+			sqlDB.Close()
+		}
+	}()
+	db := &models.DBModel{DB: mockDB}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/books/", strings.NewReader(`{"name":"Book1","author":"Author1","publication":"Publication1"}`))
+	req.Header.Set("Accept", "text/csv")
+	tests.WithDefaultTenant(utils.NegotiateRecord(CreateBookHandler(db))).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+	count, err := db.CountBooks(tests.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count, "a rejected create must not write the book")
+}
+
+func TestBookStoreControllerCacheControl(t *testing.T) {
+	mockDB, err := tests.Setup()
+	assert.NoError(t, err)
+	defer func() {
+		sqlDB, _ := mockDB.DB()
+		if sqlDB != nil {
+			sqlDB.Close()
+		}
+	}()
+	db := &models.DBModel{DB: mockDB}
+	assert.NoError(t, db.CreateBook(tests.Context(), &models.Book{Name: "Book1", Author: "Author1", Publication: "Publication1"}))
+
+	testCases := []struct {
+		name           string
+		maxAge         time.Duration
+		handler        func(c *BookstoreController) http.HandlerFunc
+		bookId         string
+		body           string
+		expectedStatus int
+		expectedHeader string
+	}{
+		{name: "List", maxAge: 30 * time.Second, handler: func(c *BookstoreController) http.HandlerFunc { return c.GetBooks }, expectedStatus: http.StatusOK, expectedHeader: "public, max-age=30"},
+		{name: "Book", maxAge: 30 * time.Second, handler: func(c *BookstoreController) http.HandlerFunc { return c.GetBookById }, bookId: "1", expectedStatus: http.StatusOK, expectedHeader: "public, max-age=30"},
+		{name: "Missing book", maxAge: 30 * time.Second, handler: func(c *BookstoreController) http.HandlerFunc { return c.GetBookById }, bookId: "2", expectedStatus: http.StatusNotFound, expectedHeader: "no-store"},
+		{name: "Client caching disabled", handler: func(c *BookstoreController) http.HandlerFunc { return c.GetBooks }, expectedStatus: http.StatusOK, expectedHeader: "no-cache"},
+		{name: "Write", maxAge: 30 * time.Second, handler: func(c *BookstoreController) http.HandlerFunc { return c.UpdateBook }, bookId: "1", body: `{"name":"Book2"}`, expectedStatus: http.StatusOK, expectedHeader: "no-store"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewBookStoreController(db, slog.New(slog.NewTextHandler(io.Discard, nil)), tc.maxAge)
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/books/", strings.NewReader(tc.body))
+			req = mux.SetURLVars(req, map[string]string{"id": tc.bookId})
+			tests.WithDefaultTenant(utils.SetJSONContentType(tc.handler(c))).ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			assert.Equal(t, tc.expectedHeader, rec.Header().Get("Cache-Control"))
+		})
+	}
+}
+
+// failingStream yields one batch and then fails, as if the database went away
+// mid-walk.
+type failingStream struct {
+	models.BookstoreDB
+}
+
+func (failingStream) EachBook(_ context.Context, _ int, fn func([]models.Book) error) error {
+	if err := fn([]models.Book{{ID: 1, Name: "Book1", Author: "Author1", Publication: "Publication1"}}); err != nil {
+		return err
+	}
+	return errors.New("connection reset")
+}
+
+func TestGetBooksHandlerAbortsInterruptedStream(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/books/", nil)
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		utils.NegotiateContentType(GetBooksHandler(failingStream{})).ServeHTTP(rec, req)
+	})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `[{"ID":1,"name":"Book1","author":"Author1","publication":"Publication1"}`, rec.Body.String())
+}
+
+func TestBookHandlersContextErrors(t *testing.T) {
+	mockDB, err := tests.Setup()
+	assert.NoError(t, err)
+	defer func() {
+		sqlDB, _ := mockDB.DB()
+		if sqlDB != nil {
+			sqlDB.Close()
+		}
+	}()
+
+	cancelled, cancel := context.WithCancel(tests.Context())
+	cancel()
+
+	testCases := []struct {
+		name           string
+		handler        func(db models.BookstoreDB) http.HandlerFunc
+		queryTimeout   time.Duration
+		ctx            context.Context
+		expectedStatus int
+	}{
+		{name: "Book: client went away", handler: GetBookByIdHandler, ctx: cancelled, expectedStatus: utils.StatusClientClosedRequest},
+		{name: "Book: query timed out", handler: GetBookByIdHandler, queryTimeout: time.Nanosecond, ctx: tests.Context(), expectedStatus: http.StatusGatewayTimeout},
+		{name: "Books: client went away", handler: GetBooksHandler, ctx: cancelled, expectedStatus: utils.StatusClientClosedRequest},
+		{name: "Books: query timed out", handler: GetBooksHandler, queryTimeout: time.Nanosecond, ctx: tests.Context(), expectedStatus: http.StatusGatewayTimeout},
+		{name: "Delete: query timed out", handler: DeleteBookHandler, queryTimeout: time.Nanosecond, ctx: tests.Context(), expectedStatus: http.StatusGatewayTimeout},
+		{name: "Book: no tenant", handler: GetBookByIdHandler, ctx: context.Background(), expectedStatus: http.StatusBadRequest},
+		{name: "Delete: no tenant", handler: DeleteBookHandler, ctx: context.Background(), expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := &models.DBModel{DB: mockDB, QueryTimeout: tc.queryTimeout}
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/books/1", nil).WithContext(tc.ctx)
+			req = mux.SetURLVars(req, map[string]string{"id": "1"})
+			utils.SetJSONContentType(tc.handler(db)).ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+		})
+	}
+}
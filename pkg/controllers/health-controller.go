@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// HealthStatus holds the process state that readiness depends on besides the
+// database itself.
+type HealthStatus struct {
+	mu           sync.RWMutex
+	migrated     bool
+	migrationErr error
+	shuttingDown bool
+}
+
+func (s *HealthStatus) SetMigrated(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.migrated = err == nil
+	s.migrationErr = err
+}
+
+func (s *HealthStatus) SetShuttingDown() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shuttingDown = true
+}
+
+func (s *HealthStatus) snapshot() (migrated bool, migrationErr error, shuttingDown bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.migrated, s.migrationErr, s.shuttingDown
+}
+
+type HealthCheck struct {
+	Status  string `json:"status"`
+	Latency string `json:"latency,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+type HealthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks,omitempty"`
+}
+
+const (
+	statusOK          = "ok"
+	statusUnavailable = "unavailable"
+)
+
+type HealthController struct {
+	Healthz http.HandlerFunc
+	Readyz  http.HandlerFunc
+}
+
+func NewHealthController(db Pinger, status *HealthStatus, pingTimeout time.Duration) *HealthController {
+	return &HealthController{
+		Healthz: HealthzHandler(),
+		Readyz:  ReadyzHandler(db, status, pingTimeout),
+	}
+}
+
+func HealthzHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, http.StatusOK, HealthResponse{Status: statusOK})
+	}
+}
+
+func ReadyzHandler(db Pinger, status *HealthStatus, pingTimeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		migrated, migrationErr, shuttingDown := status.snapshot()
+		checks := map[string]HealthCheck{}
+		ready := true
+
+		if shuttingDown {
+			checks["shutdown"] = HealthCheck{Status: statusUnavailable, Error: "server is shutting down"}
+			ready = false
+		} else {
+			checks["shutdown"] = HealthCheck{Status: statusOK}
+		}
+
+		switch {
+		case migrationErr != nil:
+			checks["migrations"] = HealthCheck{Status: statusUnavailable, Error: migrationErr.Error()}
+			ready = false
+		case !migrated:
+			checks["migrations"] = HealthCheck{Status: statusUnavailable, Error: "migrations have not run"}
+			ready = false
+		default:
+			checks["migrations"] = HealthCheck{Status: statusOK}
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), pingTimeout)
+		defer cancel()
+		start := time.Now()
+		if err := db.PingContext(ctx); err != nil {
+			checks["database"] = HealthCheck{Status: statusUnavailable, Latency: time.Since(start).String(), Error: err.Error()}
+			ready = false
+		} else {
+			checks["database"] = HealthCheck{Status: statusOK, Latency: time.Since(start).String()}
+		}
+
+		if !ready {
+			writeHealth(w, http.StatusServiceUnavailable, HealthResponse{Status: statusUnavailable, Checks: checks})
+			return
+		}
+		writeHealth(w, http.StatusOK, HealthResponse{Status: statusOK, Checks: checks})
+	}
+}
+
+func writeHealth(w http.ResponseWriter, statusCode int, response HealthResponse) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockPinger struct {
+	err   error
+	delay time.Duration
+}
+
+func (m *mockPinger) PingContext(ctx context.Context) error {
+	select {
+	case <-time.After(m.delay):
+		return m.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestHealthzHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	HealthzHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, rec.Body.String())
+}
+
+func TestReadyzHandler(t *testing.T) {
+	testCases := []struct {
+		name           string
+		pinger         *mockPinger
+		migrationErr   error
+		skipMigration  bool
+		shuttingDown   bool
+		expectedStatus int
+		expectedChecks map[string]string
+	}{
+		{
+			name:           "Ready",
+			pinger:         &mockPinger{},
+			expectedStatus: http.StatusOK,
+			expectedChecks: map[string]string{"database": "ok", "migrations": "ok", "shutdown": "ok"},
+		},
+		{
+			name:           "Database unreachable",
+			pinger:         &mockPinger{err: errors.New("connection refused")},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedChecks: map[string]string{"database": "unavailable", "migrations": "ok", "shutdown": "ok"},
+		},
+		{
+			name:           "Database ping times out",
+			pinger:         &mockPinger{delay: time.Second},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedChecks: map[string]string{"database": "unavailable", "migrations": "ok", "shutdown": "ok"},
+		},
+		{
+			name:           "Migration failed",
+			pinger:         &mockPinger{},
+			migrationErr:   errors.New("duplicate column"),
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedChecks: map[string]string{"database": "ok", "migrations": "unavailable", "shutdown": "ok"},
+		},
+		{
+			name:           "Migration not run",
+			pinger:         &mockPinger{},
+			skipMigration:  true,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedChecks: map[string]string{"database": "ok", "migrations": "unavailable", "shutdown": "ok"},
+		},
+		{
+			name:           "Shutting down",
+			pinger:         &mockPinger{},
+			shuttingDown:   true,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedChecks: map[string]string{"database": "ok", "migrations": "ok", "shutdown": "unavailable"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			status := &HealthStatus{}
+			if !tc.skipMigration {
+				status.SetMigrated(tc.migrationErr)
+			}
+			if tc.shuttingDown {
+				status.SetShuttingDown()
+			}
+
+			rec := httptest.NewRecorder()
+			handler := ReadyzHandler(tc.pinger, status, 20*time.Millisecond)
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+
+			var response HealthResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+			for name, expected := range tc.expectedChecks {
+				assert.Equal(t, expected, response.Checks[name].Status, "check %s", name)
+			}
+		})
+	}
+}
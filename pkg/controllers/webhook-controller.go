@@ -0,0 +1,212 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/mg4603/go-bookstore-management-system/pkg/models"
+	"github.com/mg4603/go-bookstore-management-system/pkg/utils"
+)
+
+// defaultDeliveryLimit is how many deliveries GetWebhookDeliveries returns
+// when the request gives no limit.
+const defaultDeliveryLimit = 50
+
+type WebhookController struct {
+	CreateWebhook        http.HandlerFunc
+	GetWebhooks          http.HandlerFunc
+	GetWebhookById       http.HandlerFunc
+	UpdateWebhook        http.HandlerFunc
+	DeleteWebhook        http.HandlerFunc
+	GetWebhookDeliveries http.HandlerFunc
+}
+
+// webhookRequest is the body of a create or update. Active defaults to true
+// on create and is left unchanged by an update that omits it.
+type webhookRequest struct {
+	URL    string   `json:"url" xml:"url"`
+	Events []string `json:"events" xml:"events"`
+	Active *bool    `json:"active" xml:"active"`
+}
+
+// createdWebhook is the one response that includes the signing secret.
+type createdWebhook struct {
+	*models.Webhook
+	Secret string `json:"secret" xml:"secret"`
+}
+
+// NewWebhookController never lets responses be cached: they describe
+// subscriptions, and a create response carries the secret.
+func NewWebhookController(db models.WebhookStore, logger *slog.Logger) *WebhookController {
+	noStore := utils.CacheControl("no-store")
+	logger = logger.With("component", "webhook-controller")
+	handle := func(h http.HandlerFunc) http.HandlerFunc {
+		return utils.WithLogger(logger, noStore(h)).ServeHTTP
+	}
+
+	return &WebhookController{
+		CreateWebhook:        handle(CreateWebhookHandler(db)),
+		GetWebhooks:          handle(GetWebhooksHandler(db)),
+		GetWebhookById:       handle(GetWebhookByIdHandler(db)),
+		UpdateWebhook:        handle(UpdateWebhookHandler(db)),
+		DeleteWebhook:        handle(DeleteWebhookHandler(db)),
+		GetWebhookDeliveries: handle(GetWebhookDeliveriesHandler(db)),
+	}
+}
+
+func CreateWebhookHandler(db models.WebhookStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req webhookRequest
+		if err := utils.ParseBody(r, &req); err != nil {
+			utils.HandleError(w, r, utils.ParseErrorStatus(err), fmt.Sprintf("error parsing input into webhook: %s", err))
+			return
+		}
+
+		webhook := &models.Webhook{URL: req.URL, Events: req.Events, Active: req.Active == nil || *req.Active}
+		if err := db.CreateWebhook(r.Context(), webhook); err != nil {
+			utils.HandleError(w, r, webhookErrorStatus(err), fmt.Sprintf("error creating webhook: %s", err))
+			return
+		}
+
+		if err := utils.WriteResponse(w, r, http.StatusCreated, createdWebhook{Webhook: webhook, Secret: webhook.Secret}); err != nil {
+			utils.HandleError(w, r, utils.ResponseErrorStatus(err), fmt.Sprintf("error encoding created webhook: %s", err))
+		}
+	}
+}
+
+func GetWebhooksHandler(db models.WebhookStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		webhooks, err := db.GetWebhooks(r.Context())
+		if err != nil {
+			utils.HandleError(w, r, webhookErrorStatus(err), fmt.Sprintf("error fetching webhooks: %s", err))
+			return
+		}
+		if webhooks == nil {
+			webhooks = []models.Webhook{}
+		}
+
+		if err := utils.WriteResponse(w, r, http.StatusOK, webhooks); err != nil {
+			utils.HandleError(w, r, utils.ResponseErrorStatus(err), fmt.Sprintf("error encoding webhooks: %s", err))
+		}
+	}
+}
+
+func GetWebhookByIdHandler(db models.WebhookStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := webhookID(w, r)
+		if !ok {
+			return
+		}
+
+		webhook, err := db.GetWebhookById(r.Context(), id)
+		if err != nil {
+			utils.HandleError(w, r, webhookErrorStatus(err), fmt.Sprintf("error fetching webhook: %s", err))
+			return
+		}
+
+		if err := utils.WriteResponse(w, r, http.StatusOK, webhook); err != nil {
+			utils.HandleError(w, r, utils.ResponseErrorStatus(err), fmt.Sprintf("error encoding webhook: %s", err))
+		}
+	}
+}
+
+// UpdateWebhookHandler changes only the fields the body sets.
+func UpdateWebhookHandler(db models.WebhookStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := webhookID(w, r)
+		if !ok {
+			return
+		}
+		var req webhookRequest
+		if err := utils.ParseBody(r, &req); err != nil {
+			utils.HandleError(w, r, utils.ParseErrorStatus(err), fmt.Sprintf("error parsing input into webhook: %s", err))
+			return
+		}
+
+		webhook, err := db.UpdateWebhook(r.Context(), id, models.WebhookUpdate{URL: req.URL, Events: req.Events, Active: req.Active})
+		if err != nil {
+			utils.HandleError(w, r, webhookErrorStatus(err), fmt.Sprintf("error updating webhook: %s", err))
+			return
+		}
+
+		if err := utils.WriteResponse(w, r, http.StatusOK, webhook); err != nil {
+			utils.HandleError(w, r, utils.ResponseErrorStatus(err), fmt.Sprintf("error encoding webhook: %s", err))
+		}
+	}
+}
+
+func DeleteWebhookHandler(db models.WebhookStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := webhookID(w, r)
+		if !ok {
+			return
+		}
+
+		webhook, err := db.DeleteWebhook(r.Context(), id)
+		if err != nil {
+			utils.HandleError(w, r, webhookErrorStatus(err), fmt.Sprintf("error deleting webhook: %s", err))
+			return
+		}
+
+		if err := utils.WriteResponse(w, r, http.StatusOK, webhook); err != nil {
+			utils.HandleError(w, r, utils.ResponseErrorStatus(err), fmt.Sprintf("error encoding webhook: %s", err))
+		}
+	}
+}
+
+// GetWebhookDeliveriesHandler returns the newest deliveries first, up to the
+// limit query parameter.
+func GetWebhookDeliveriesHandler(db models.WebhookStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := webhookID(w, r)
+		if !ok {
+			return
+		}
+		limit := defaultDeliveryLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			var err error
+			if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+				utils.HandleError(w, r, http.StatusBadRequest, fmt.Sprintf("bad input: invalid limit %q", v))
+				return
+			}
+		}
+
+		deliveries, err := db.GetWebhookDeliveries(r.Context(), id, limit)
+		if err != nil {
+			utils.HandleError(w, r, webhookErrorStatus(err), fmt.Sprintf("error fetching webhook deliveries: %s", err))
+			return
+		}
+		if deliveries == nil {
+			deliveries = []models.WebhookDelivery{}
+		}
+
+		if err := utils.WriteResponse(w, r, http.StatusOK, deliveries); err != nil {
+			utils.HandleError(w, r, utils.ResponseErrorStatus(err), fmt.Sprintf("error encoding webhook deliveries: %s", err))
+		}
+	}
+}
+
+// webhookID parses the id route variable, answering 400 if it is not an
+// integer.
+func webhookID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.HandleError(w, r, http.StatusBadRequest, fmt.Sprintf("bad input: couldn't parse webhook id %q", mux.Vars(r)["id"]))
+		return 0, false
+	}
+	return id, true
+}
+
+func webhookErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrMissingFields), errors.Is(err, models.ErrInvalidWebhook):
+		return http.StatusBadRequest
+	}
+	return queryErrorStatus(err)
+}
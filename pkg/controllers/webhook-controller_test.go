@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mg4603/go-bookstore-management-system/pkg/models"
+	"github.com/mg4603/go-bookstore-management-system/pkg/tests"
+	"github.com/mg4603/go-bookstore-management-system/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookController(t *testing.T) {
+	mockDB, err := tests.Setup()
+	assert.NoError(t, err)
+	defer func() {
+		sqlDB, _ := mockDB.DB()
+		if sqlDB != nil {
+			sqlDB.Close()
+		}
+	}()
+	db := &models.DBModel{DB: mockDB}
+
+	c := NewWebhookController(db, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	r := mux.NewRouter()
+	r.HandleFunc("/webhooks/", c.CreateWebhook).Methods(http.MethodPost)
+	r.HandleFunc("/webhooks/", c.GetWebhooks).Methods(http.MethodGet)
+	r.HandleFunc("/webhooks/{id}", c.GetWebhookById).Methods(http.MethodGet)
+	r.HandleFunc("/webhooks/{id}", c.UpdateWebhook).Methods(http.MethodPut)
+	r.HandleFunc("/webhooks/{id}", c.DeleteWebhook).Methods(http.MethodDelete)
+	r.HandleFunc("/webhooks/{id}/deliveries", c.GetWebhookDeliveries).Methods(http.MethodGet)
+	handler := tests.WithDefaultTenant(utils.SetJSONContentType(r))
+
+	testCases := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "Create", method: http.MethodPost, path: "/webhooks/", body: `{"url":"https://example.com/hook","events":["book.created"]}`, expectedStatus: http.StatusCreated},
+		{name: "Create with bad URL", method: http.MethodPost, path: "/webhooks/", body: `{"url":"example.com","events":["book.created"]}`, expectedStatus: http.StatusBadRequest},
+		{name: "Create without events", method: http.MethodPost, path: "/webhooks/", body: `{"url":"https://example.com/hook"}`, expectedStatus: http.StatusBadRequest},
+		{name: "Get", method: http.MethodGet, path: "/webhooks/1", expectedStatus: http.StatusOK},
+		{name: "Get missing", method: http.MethodGet, path: "/webhooks/9", expectedStatus: http.StatusNotFound},
+		{name: "Get bad ID", method: http.MethodGet, path: "/webhooks/one", expectedStatus: http.StatusBadRequest},
+		{name: "Deactivate", method: http.MethodPut, path: "/webhooks/1", body: `{"active":false}`, expectedStatus: http.StatusOK},
+		{name: "Update with unknown event", method: http.MethodPut, path: "/webhooks/1", body: `{"events":["book.read"]}`, expectedStatus: http.StatusBadRequest},
+		{name: "List", method: http.MethodGet, path: "/webhooks/", expectedStatus: http.StatusOK},
+		{name: "Deliveries", method: http.MethodGet, path: "/webhooks/1/deliveries?limit=10", expectedStatus: http.StatusOK, expectedBody: `[]`},
+		{name: "Deliveries with bad limit", method: http.MethodGet, path: "/webhooks/1/deliveries?limit=0", expectedStatus: http.StatusBadRequest},
+		{name: "Delete", method: http.MethodDelete, path: "/webhooks/1", expectedStatus: http.StatusOK},
+		{name: "Delete again", method: http.MethodDelete, path: "/webhooks/1", expectedStatus: http.StatusNotFound},
+	}
+	responses := map[string]string{}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+			if tc.expectedBody != "" {
+				assert.JSONEq(t, tc.expectedBody, rec.Body.String())
+			}
+			responses[tc.name] = rec.Body.String()
+		})
+	}
+
+	var created, fetched, deactivated map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(responses["Create"]), &created))
+	assert.NoError(t, json.Unmarshal([]byte(responses["Get"]), &fetched))
+	assert.NoError(t, json.Unmarshal([]byte(responses["Deactivate"]), &deactivated))
+	assert.True(t, strings.HasPrefix(created["secret"].(string), "whsec_"), "the secret is shown on create")
+	assert.Equal(t, true, created["active"])
+	assert.NotContains(t, fetched, "secret", "and never again")
+	assert.Equal(t, false, deactivated["active"])
+	assert.Equal(t, []interface{}{"book.created"}, deactivated["events"])
+}
@@ -0,0 +1,90 @@
+package cors
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/mg4603/go-bookstore-management-system/pkg/utils"
+)
+
+const (
+	defaultAllowedMethods = "GET, POST, PUT, DELETE"
+	defaultAllowedHeaders = "Accept, Authorization, Content-Type, Last-Event-ID, X-Request-ID, X-Tenant"
+	defaultExposedHeaders = "X-Request-ID, Retry-After, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset"
+	defaultMaxAge         = 10 * time.Minute
+)
+
+// Config controls which cross-origin requests browsers may make. With no
+// AllowedOrigins, CORS is disabled and responses carry no CORS headers.
+type Config struct {
+	// AllowedOrigins are exact origins such as "https://admin.example.com",
+	// or "*" for any origin.
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+func (c Config) Validate() error {
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			if c.AllowCredentials {
+				return errors.New(`allowed origin "*" cannot be combined with credentials`)
+			}
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+			u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+			return fmt.Errorf("invalid allowed origin %q: want scheme://host[:port]", origin)
+		}
+	}
+	if c.MaxAge < 0 {
+		return errors.New("max age cannot be negative")
+	}
+	return nil
+}
+
+// ConfigFrom reads CORS_ALLOWED_ORIGINS, CORS_ALLOWED_METHODS,
+// CORS_ALLOWED_HEADERS, CORS_EXPOSED_HEADERS, CORS_ALLOW_CREDENTIALS and
+// CORS_MAX_AGE through lookup. Lists are comma-separated.
+func ConfigFrom(lookup func(key string) (string, bool)) (Config, error) {
+	get := func(key, fallback string) string {
+		if v, ok := lookup(key); ok {
+			return v
+		}
+		return fallback
+	}
+	cfg := Config{
+		AllowedOrigins: utils.SplitList(get("CORS_ALLOWED_ORIGINS", "")),
+		AllowedMethods: utils.SplitList(get("CORS_ALLOWED_METHODS", defaultAllowedMethods)),
+		AllowedHeaders: utils.SplitList(get("CORS_ALLOWED_HEADERS", defaultAllowedHeaders)),
+		ExposedHeaders: utils.SplitList(get("CORS_EXPOSED_HEADERS", defaultExposedHeaders)),
+		MaxAge:         defaultMaxAge,
+	}
+
+	if v := get("CORS_ALLOW_CREDENTIALS", ""); v != "" {
+		allow, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("CORS_ALLOW_CREDENTIALS: invalid boolean %q", v)
+		}
+		cfg.AllowCredentials = allow
+	}
+	if v := get("CORS_MAX_AGE", ""); v != "" {
+		maxAge, err := time.ParseDuration(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("CORS_MAX_AGE: invalid duration %q", v)
+		}
+		cfg.MaxAge = maxAge
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
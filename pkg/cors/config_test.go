@@ -0,0 +1,53 @@
+package cors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigFrom(t *testing.T) {
+	cfg, err := ConfigFrom(lookup(map[string]string{
+		"CORS_ALLOWED_ORIGINS":   "https://admin.example.com, http://localhost:5173",
+		"CORS_ALLOW_CREDENTIALS": "true",
+		"CORS_MAX_AGE":           "1h",
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://admin.example.com", "http://localhost:5173"}, cfg.AllowedOrigins)
+	assert.Equal(t, []string{"GET", "POST", "PUT", "DELETE"}, cfg.AllowedMethods)
+	assert.Equal(t, []string{"Accept", "Authorization", "Content-Type", "Last-Event-ID", "X-Request-ID", "X-Tenant"}, cfg.AllowedHeaders)
+	assert.Contains(t, cfg.ExposedHeaders, "Retry-After")
+	assert.True(t, cfg.AllowCredentials)
+	assert.Equal(t, time.Hour, cfg.MaxAge)
+}
+
+func TestConfigFromErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		env           map[string]string
+		expectedError string
+	}{
+		{name: "Wildcard with credentials", env: map[string]string{"CORS_ALLOWED_ORIGINS": "*", "CORS_ALLOW_CREDENTIALS": "true"}, expectedError: `allowed origin "*" cannot be combined with credentials`},
+		{name: "Origin with path", env: map[string]string{"CORS_ALLOWED_ORIGINS": "https://admin.example.com/"}, expectedError: `invalid allowed origin "https://admin.example.com/": want scheme://host[:port]`},
+		{name: "Bare host", env: map[string]string{"CORS_ALLOWED_ORIGINS": "admin.example.com"}, expectedError: `invalid allowed origin "admin.example.com": want scheme://host[:port]`},
+		{name: "Bad boolean", env: map[string]string{"CORS_ALLOW_CREDENTIALS": "sure"}, expectedError: `CORS_ALLOW_CREDENTIALS: invalid boolean "sure"`},
+		{name: "Bad max age", env: map[string]string{"CORS_MAX_AGE": "600"}, expectedError: `CORS_MAX_AGE: invalid duration "600"`},
+		{name: "Negative max age", env: map[string]string{"CORS_MAX_AGE": "-1m"}, expectedError: "max age cannot be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ConfigFrom(lookup(tt.env))
+			assert.EqualError(t, err, tt.expectedError)
+		})
+	}
+}
+
+// lookup reads settings from env instead of the environment.
+func lookup(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+}
@@ -0,0 +1,165 @@
+package cors
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/mg4603/go-bookstore-management-system/pkg/utils"
+)
+
+// RouteMatcher is satisfied by *mux.Router.
+type RouteMatcher interface {
+	Match(r *http.Request, match *mux.RouteMatch) bool
+}
+
+type CORS struct {
+	config  Config
+	origins map[string]bool
+	methods map[string]bool
+	headers map[string]bool
+	routes  RouteMatcher
+}
+
+// New answers preflight requests for the methods routes actually register on
+// the requested path, so no OPTIONS routes are needed. The middleware must
+// wrap the router rather than be installed with Router.Use, because mux
+// answers OPTIONS with 405 before route middleware runs.
+func New(cfg Config, routes RouteMatcher) *CORS {
+	c := &CORS{
+		config:  cfg,
+		origins: map[string]bool{},
+		methods: map[string]bool{},
+		headers: map[string]bool{},
+		routes:  routes,
+	}
+	for _, origin := range cfg.AllowedOrigins {
+		c.origins[origin] = true
+	}
+	for _, method := range cfg.AllowedMethods {
+		c.methods[strings.ToUpper(method)] = true
+	}
+	for _, header := range cfg.AllowedHeaders {
+		c.headers[http.CanonicalHeaderKey(header)] = true
+	}
+	return c
+}
+
+func (c *CORS) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if len(c.origins) == 0 || origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// The response depends on the origin even when it is not allowed.
+		w.Header().Add("Vary", "Origin")
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			c.preflight(w, r, origin, next)
+			return
+		}
+
+		if c.allowOrigin(w, origin) && len(c.config.ExposedHeaders) > 0 {
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(c.config.ExposedHeaders, ", "))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *CORS) preflight(w http.ResponseWriter, r *http.Request, origin string, next http.Handler) {
+	w.Header().Add("Vary", "Access-Control-Request-Method")
+	w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+	methods := c.routeMethods(r)
+	if len(methods) == 0 {
+		// Let the router answer for paths it does not serve.
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	requested := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	if !c.origins["*"] && !c.origins[origin] {
+		c.reject(w, r, "origin "+origin+" is not allowed")
+		return
+	}
+	if !contains(methods, requested) {
+		c.reject(w, r, "method "+requested+" is not allowed")
+		return
+	}
+	for _, header := range requestedHeaders(r) {
+		if !c.headers[header] {
+			c.reject(w, r, "header "+header+" is not allowed")
+			return
+		}
+	}
+
+	c.allowOrigin(w, origin)
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	if len(c.config.AllowedHeaders) > 0 {
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(c.config.AllowedHeaders, ", "))
+	}
+	if c.config.MaxAge > 0 {
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(c.config.MaxAge.Seconds())))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// allowOrigin sets the headers that let origin read the response and reports
+// whether it is allowed.
+func (c *CORS) allowOrigin(w http.ResponseWriter, origin string) bool {
+	switch {
+	case c.origins["*"] && !c.config.AllowCredentials:
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	case c.origins["*"] || c.origins[origin]:
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	default:
+		return false
+	}
+	if c.config.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+	return true
+}
+
+// routeMethods returns the allowed methods registered for the request's path,
+// in the order they were configured.
+func (c *CORS) routeMethods(r *http.Request) []string {
+	var methods []string
+	for _, method := range c.config.AllowedMethods {
+		method = strings.ToUpper(method)
+		probe := r.Clone(r.Context())
+		probe.Method = method
+		var match mux.RouteMatch
+		if c.routes.Match(probe, &match) && match.MatchErr == nil {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
+
+func (c *CORS) reject(w http.ResponseWriter, r *http.Request, message string) {
+	utils.HandleError(w, r, http.StatusForbidden, "CORS preflight rejected: "+message)
+}
+
+func requestedHeaders(r *http.Request) []string {
+	var headers []string
+	for _, value := range r.Header.Values("Access-Control-Request-Headers") {
+		for _, header := range strings.Split(value, ",") {
+			if header = strings.TrimSpace(header); header != "" {
+				headers = append(headers, http.CanonicalHeaderKey(header))
+			}
+		}
+	}
+	return headers
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mg4603/go-bookstore-management-system/pkg/controllers"
+	"github.com/mg4603/go-bookstore-management-system/pkg/routes"
+	"github.com/stretchr/testify/assert"
+)
+
+func newHandler(cfg Config) http.Handler {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) }
+	r := mux.NewRouter()
+	routes.RegisterBookstoreRoutes(r, &controllers.BookstoreController{
+		CreateBook:  ok,
+		GetBooks:    ok,
+		GetBookById: ok,
+		UpdateBook:  ok,
+		DeleteBook:  ok,
+	})
+	return New(cfg, r).Middleware(r)
+}
+
+var testConfig = Config{
+	AllowedOrigins: []string{"https://admin.example.com"},
+	AllowedMethods: []string{"GET", "POST", "PUT", "DELETE"},
+	AllowedHeaders: []string{"Content-Type", "Authorization"},
+	ExposedHeaders: []string{"X-Request-ID"},
+	MaxAge:         10 * time.Minute,
+}
+
+func TestPreflight(t *testing.T) {
+	tests := []struct {
+		name            string
+		config          Config
+		path            string
+		origin          string
+		method          string
+		headers         string
+		expectedStatus  int
+		expectedOrigin  string
+		expectedMethods string
+	}{
+		{name: "Collection", config: testConfig, path: "/books/", origin: "https://admin.example.com", method: "POST", headers: "content-type", expectedStatus: http.StatusNoContent, expectedOrigin: "https://admin.example.com", expectedMethods: "GET, POST"},
+		{name: "Item", config: testConfig, path: "/books/1", origin: "https://admin.example.com", method: "PUT", headers: "Content-Type, Authorization", expectedStatus: http.StatusNoContent, expectedOrigin: "https://admin.example.com", expectedMethods: "GET, PUT, DELETE"},
+		{name: "Origin not allowed", config: testConfig, path: "/books/", origin: "https://evil.example.com", method: "POST", expectedStatus: http.StatusForbidden},
+		{name: "Method not registered for path", config: testConfig, path: "/books/", origin: "https://admin.example.com", method: "DELETE", expectedStatus: http.StatusForbidden},
+		{name: "Header not allowed", config: testConfig, path: "/books/", origin: "https://admin.example.com", method: "POST", headers: "X-Custom", expectedStatus: http.StatusForbidden},
+		{name: "Unknown path", config: testConfig, path: "/authors/", origin: "https://admin.example.com", method: "GET", expectedStatus: http.StatusNotFound},
+		{name: "Any origin", config: Config{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET"}}, path: "/books/", origin: "https://other.example.com", method: "GET", expectedStatus: http.StatusNoContent, expectedOrigin: "*", expectedMethods: "GET"},
+		{name: "Disabled", config: Config{}, path: "/books/", origin: "https://admin.example.com", method: "GET", expectedStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("OPTIONS", tt.path, nil)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", tt.method)
+			if tt.headers != "" {
+				req.Header.Set("Access-Control-Request-Headers", tt.headers)
+			}
+			rec := httptest.NewRecorder()
+			newHandler(tt.config).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Equal(t, tt.expectedOrigin, rec.Header().Get("Access-Control-Allow-Origin"))
+			assert.Equal(t, tt.expectedMethods, rec.Header().Get("Access-Control-Allow-Methods"))
+			if tt.expectedStatus == http.StatusNoContent && len(tt.config.AllowedHeaders) > 0 {
+				assert.Equal(t, "Content-Type, Authorization", rec.Header().Get("Access-Control-Allow-Headers"))
+				assert.Equal(t, "600", rec.Header().Get("Access-Control-Max-Age"))
+			}
+			if len(tt.config.AllowedOrigins) > 0 {
+				assert.Contains(t, rec.Header().Values("Vary"), "Origin")
+			}
+		})
+	}
+}
+
+func TestActualRequest(t *testing.T) {
+	tests := []struct {
+		name                string
+		config              Config
+		origin              string
+		expectedOrigin      string
+		expectedCredentials string
+		expectedExposed     string
+	}{
+		{name: "Allowed origin", config: testConfig, origin: "https://admin.example.com", expectedOrigin: "https://admin.example.com", expectedExposed: "X-Request-ID"},
+		{name: "Other origin", config: testConfig, origin: "https://evil.example.com"},
+		{name: "Same origin", config: testConfig},
+		{name: "Credentials echo the origin", config: Config{AllowedOrigins: []string{"https://admin.example.com"}, AllowCredentials: true}, origin: "https://admin.example.com", expectedOrigin: "https://admin.example.com", expectedCredentials: "true"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/books/", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			rec := httptest.NewRecorder()
+			newHandler(tt.config).ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code, "the request itself is always served")
+			assert.Equal(t, "ok", rec.Body.String())
+			assert.Equal(t, tt.expectedOrigin, rec.Header().Get("Access-Control-Allow-Origin"))
+			assert.Equal(t, tt.expectedCredentials, rec.Header().Get("Access-Control-Allow-Credentials"))
+			assert.Equal(t, tt.expectedExposed, rec.Header().Get("Access-Control-Expose-Headers"))
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package graphqlapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/mg4603/go-bookstore-management-system/pkg/models"
+	"github.com/mg4603/go-bookstore-management-system/pkg/utils"
+)
+
+// maxQueryDepth stops clients from nesting book { author { books { ... } } }
+// arbitrarily deep.
+const maxQueryDepth = 8
+
+type request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+	Extensions    map[string]interface{} `json:"extensions"`
+}
+
+// NewHandler serves GraphQL queries and mutations over POST, with a fresh
+// batching loader for every request.
+func NewHandler(db models.BookstoreDB, logger *slog.Logger) (http.Handler, error) {
+	s, err := graphql.ParseSchema(schema, &resolver{db: db}, graphql.MaxDepth(maxQueryDepth))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing GraphQL schema: %w", err)
+	}
+
+	return utils.WithLogger(logger.With("component", "graphql"), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req request
+		if err := utils.ParseBody(r, &req); err != nil {
+			utils.HandleError(w, r, utils.ParseErrorStatus(err), fmt.Sprintf("error parsing GraphQL request: %s", err))
+			return
+		}
+
+		ctx := withLoader(r.Context(), newAuthorLoader(db))
+		response := s.Exec(ctx, req.Query, req.OperationName, req.Variables)
+
+		body, err := json.Marshal(response)
+		if err != nil {
+			utils.HandleError(w, r, http.StatusInternalServerError, fmt.Sprintf("error encoding GraphQL response: %s", err))
+			return
+		}
+		w.Write(body)
+	})), nil
+}
@@ -0,0 +1,151 @@
+package graphqlapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mg4603/go-bookstore-management-system/pkg/models"
+	"github.com/mg4603/go-bookstore-management-system/pkg/tests"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func setup(t *testing.T, seed []models.Book) (http.Handler, *models.DBModel) {
+	mockDB, err := tests.Setup()
+	assert.NoError(t, err, "failed to setup test database")
+	t.Cleanup(func() {
+		sqlDB, _ := mockDB.DB()
+		if sqlDB != nil {
+			sqlDB.Close()
+		}
+	})
+
+	db := &models.DBModel{DB: mockDB}
+	for _, book := range seed {
+		assert.NoError(t, db.CreateBook(tests.Context(), &book))
+	}
+
+	handler, err := NewHandler(db, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	assert.NoError(t, err)
+	return tests.WithDefaultTenant(handler), db
+}
+
+func execute(t *testing.T, handler http.Handler, query string, variables map[string]interface{}) (int, string) {
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest("POST", "/graphql", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Code, rec.Body.String()
+}
+
+var seedBooks = []models.Book{
+	{Name: "Book1", Author: "Author1", Publication: "Publication1"},
+	{Name: "Book2", Author: "Author2", Publication: "Publication1"},
+	{Name: "Book3", Author: "Author1", Publication: "Publication2"},
+}
+
+func TestQueries(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		variables    map[string]interface{}
+		expectedBody string
+	}{
+		{
+			name:         "Books page",
+			query:        `{ books(limit: 2) { totalCount items { id name publication } } }`,
+			expectedBody: `{"data":{"books":{"totalCount":3,"items":[{"id":"1","name":"Book1","publication":"Publication1"},{"id":"2","name":"Book2","publication":"Publication1"}]}}}`,
+		},
+		{
+			name:         "Filtered with offset",
+			query:        `query($author: String) { books(filter: {author: $author}, offset: 1) { totalCount items { name } } }`,
+			variables:    map[string]interface{}{"author": "Author1"},
+			expectedBody: `{"data":{"books":{"totalCount":2,"items":[{"name":"Book3"}]}}}`,
+		},
+		{
+			name:         "Book with its author's other books",
+			query:        `{ book(id: "1") { name author { name books { name } } } }`,
+			expectedBody: `{"data":{"book":{"name":"Book1","author":{"name":"Author1","books":[{"name":"Book1"},{"name":"Book3"}]}}}}`,
+		},
+		{
+			name:         "Missing book is null",
+			query:        `{ book(id: "42") { name } }`,
+			expectedBody: `{"data":{"book":null}}`,
+		},
+		{
+			name:         "Limit out of range",
+			query:        `{ books(limit: 500) { totalCount } }`,
+			expectedBody: `{"errors":[{"message":"limit must be between 1 and 100","path":["books"]}],"data":null}`,
+		},
+	}
+
+	handler, _ := setup(t, seedBooks)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := execute(t, handler, tt.query, tt.variables)
+			assert.Equal(t, http.StatusOK, status)
+			assert.JSONEq(t, tt.expectedBody, body)
+		})
+	}
+}
+
+func TestMutations(t *testing.T) {
+	handler, db := setup(t, nil)
+
+	_, body := execute(t, handler, `mutation { createBook(input: {name: "Book1", author: "Author1", publication: "Publication1"}) { id name } }`, nil)
+	assert.JSONEq(t, `{"data":{"createBook":{"id":"1","name":"Book1"}}}`, body)
+
+	_, body = execute(t, handler, `mutation { createBook(input: {name: "Book2", author: "", publication: "Publication1"}) { id } }`, nil)
+	assert.Contains(t, body, `"message":"missing required fields"`)
+
+	_, body = execute(t, handler, `mutation { updateBook(id: "1", input: {author: "Author2"}) { name author { name } } }`, nil)
+	assert.JSONEq(t, `{"data":{"updateBook":{"name":"Book1","author":{"name":"Author2"}}}}`, body)
+
+	_, body = execute(t, handler, `mutation { updateBook(id: "9", input: {name: "Book9"}) { id } }`, nil)
+	assert.Contains(t, body, `"message":"book with ID 9 not found"`)
+
+	_, body = execute(t, handler, `mutation { deleteBook(id: "1") { name } }`, nil)
+	assert.JSONEq(t, `{"data":{"deleteBook":{"name":"Book1"}}}`, body)
+
+	count, err := db.CountBooks(tests.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestRequestErrors(t *testing.T) {
+	handler, db := setup(t, seedBooks)
+
+	req := httptest.NewRequest("POST", "/graphql", bytes.NewReader([]byte(`{"query": "{ books { totalCount } }", "unknown": 1}`)))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	_, body := execute(t, handler, `{ books { items { author { books { author { books { author { books { author { books { name } } } } } } } } } } }`, nil)
+	assert.Contains(t, body, "exceeds max depth 8")
+
+	sqlDB, _ := db.DB.DB()
+	sqlDB.Close()
+	_, body = execute(t, handler, `{ books { totalCount } }`, nil)
+	assert.Contains(t, body, `"message":"An error occurred. Please try again later."`, "internal errors must not leak")
+}
+
+func TestAuthorBooksAreBatched(t *testing.T) {
+	handler, db := setup(t, seedBooks)
+
+	var queries int
+	assert.NoError(t, db.DB.Callback().Query().After("gorm:query").Register("test:count", func(*gorm.DB) { queries++ }))
+
+	_, body := execute(t, handler, `{ books { items { name author { name books { name } } } } }`, nil)
+	assert.NotContains(t, body, "errors")
+	// Counting and fetching the page take two queries; the three books'
+	// authors then share a single one.
+	assert.Equal(t, 3, queries)
+}
@@ -0,0 +1,70 @@
+package graphqlapi
+
+import (
+	"context"
+	"sync"
+
+	"github.com/mg4603/go-bookstore-management-system/pkg/models"
+)
+
+// authorLoader batches the books-by-author lookups of one request. Resolving a
+// list of books primes the loader with every author on the page, so the first
+// Author.books field fetches all of them in a single query instead of one
+// query per book.
+type authorLoader struct {
+	db models.BookstoreDB
+
+	mu      sync.Mutex
+	pending map[string]bool
+	loaded  map[string][]models.Book
+	queries int
+}
+
+func newAuthorLoader(db models.BookstoreDB) *authorLoader {
+	return &authorLoader{db: db, pending: map[string]bool{}, loaded: map[string][]models.Book{}}
+}
+
+func (l *authorLoader) prime(authors ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, author := range authors {
+		if _, ok := l.loaded[author]; !ok {
+			l.pending[author] = true
+		}
+	}
+}
+
+func (l *authorLoader) load(ctx context.Context, author string) ([]models.Book, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if books, ok := l.loaded[author]; ok {
+		return books, nil
+	}
+
+	l.pending[author] = true
+	authors := make([]string, 0, len(l.pending))
+	for a := range l.pending {
+		authors = append(authors, a)
+	}
+
+	l.queries++
+	byAuthor, err := l.db.GetBooksByAuthors(ctx, authors)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range authors {
+		l.loaded[a] = byAuthor[a]
+		delete(l.pending, a)
+	}
+	return l.loaded[author], nil
+}
+
+type loaderKey struct{}
+
+func withLoader(ctx context.Context, l *authorLoader) context.Context {
+	return context.WithValue(ctx, loaderKey{}, l)
+}
+
+func loaderFromContext(ctx context.Context) *authorLoader {
+	return ctx.Value(loaderKey{}).(*authorLoader)
+}
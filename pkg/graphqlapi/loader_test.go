@@ -0,0 +1,43 @@
+package graphqlapi
+
+import (
+	"testing"
+
+	"github.com/mg4603/go-bookstore-management-system/pkg/models"
+	"github.com/mg4603/go-bookstore-management-system/pkg/tests"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorLoader(t *testing.T) {
+	_, db := setup(t, seedBooks)
+	loader := newAuthorLoader(db)
+
+	loader.prime("Author1", "Author2", "Author1")
+	books, err := loader.load(tests.Context(), "Author1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Book1", "Book3"}, names(books))
+	assert.Equal(t, 1, loader.queries)
+
+	books, err = loader.load(tests.Context(), "Author2")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Book2"}, names(books))
+	assert.Equal(t, 1, loader.queries, "primed authors should be fetched in the same batch")
+
+	books, err = loader.load(tests.Context(), "Nobody")
+	assert.NoError(t, err)
+	assert.Empty(t, books)
+	assert.Equal(t, 2, loader.queries, "unprimed authors cost one more query")
+
+	loader.prime("Author1", "Nobody")
+	_, err = loader.load(tests.Context(), "Nobody")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, loader.queries, "loaded authors are cached for the request")
+}
+
+func names(books []models.Book) []string {
+	var ns []string
+	for _, b := range books {
+		ns = append(ns, b.Name)
+	}
+	return ns
+}
@@ -0,0 +1,213 @@
+package graphqlapi
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/mg4603/go-bookstore-management-system/pkg/models"
+	"github.com/mg4603/go-bookstore-management-system/pkg/utils"
+)
+
+const maxPageSize = 100
+
+var (
+	errInternal = errors.New("An error occurred. Please try again later.")
+	errTimeout  = errors.New("The query took too long. Please try again later.")
+)
+
+type resolver struct {
+	db models.BookstoreDB
+}
+
+type bookFilterInput struct {
+	NameContains *string
+	Author       *string
+	Publication  *string
+}
+
+type bookInput struct {
+	Name        string
+	Author      string
+	Publication string
+}
+
+type bookUpdateInput struct {
+	Name        *string
+	Author      *string
+	Publication *string
+}
+
+func (r *resolver) Books(ctx context.Context, args struct {
+	Filter *bookFilterInput
+	Limit  int32
+	Offset int32
+}) (*bookPageResolver, error) {
+	if args.Limit < 1 || args.Limit > maxPageSize {
+		return nil, errors.New("limit must be between 1 and " + strconv.Itoa(maxPageSize))
+	}
+	if args.Offset < 0 {
+		return nil, errors.New("offset must not be negative")
+	}
+
+	filter := models.BookFilter{Limit: int(args.Limit), Offset: int(args.Offset)}
+	if args.Filter != nil {
+		filter.NameContains = deref(args.Filter.NameContains)
+		filter.Author = deref(args.Filter.Author)
+		filter.Publication = deref(args.Filter.Publication)
+	}
+
+	books, total, err := r.db.FindBooks(ctx, filter)
+	if err != nil {
+		return nil, publicError(ctx, err)
+	}
+	return &bookPageResolver{total: total, items: newBookResolvers(ctx, books)}, nil
+}
+
+func (r *resolver) Book(ctx context.Context, args struct{ ID graphql.ID }) (*bookResolver, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	book, err := r.db.GetBookById(ctx, id)
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, publicError(ctx, err)
+	}
+	return newBookResolvers(ctx, []models.Book{*book})[0], nil
+}
+
+func (r *resolver) CreateBook(ctx context.Context, args struct{ Input bookInput }) (*bookResolver, error) {
+	book := &models.Book{Name: args.Input.Name, Author: args.Input.Author, Publication: args.Input.Publication}
+	if err := r.db.CreateBook(ctx, book); err != nil {
+		return nil, publicError(ctx, err)
+	}
+	return newBookResolvers(ctx, []models.Book{*book})[0], nil
+}
+
+func (r *resolver) UpdateBook(ctx context.Context, args struct {
+	ID    graphql.ID
+	Input bookUpdateInput
+}) (*bookResolver, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	book := &models.Book{
+		ID:          uint(id),
+		Name:        deref(args.Input.Name),
+		Author:      deref(args.Input.Author),
+		Publication: deref(args.Input.Publication),
+	}
+	if err := r.db.UpdateBook(ctx, book); err != nil {
+		return nil, publicError(ctx, err)
+	}
+	return newBookResolvers(ctx, []models.Book{*book})[0], nil
+}
+
+func (r *resolver) DeleteBook(ctx context.Context, args struct{ ID graphql.ID }) (*bookResolver, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+	book, err := r.db.DeleteBook(ctx, id)
+	if err != nil {
+		return nil, publicError(ctx, err)
+	}
+	return newBookResolvers(ctx, []models.Book{*book})[0], nil
+}
+
+type bookPageResolver struct {
+	total int64
+	items []*bookResolver
+}
+
+func (p *bookPageResolver) TotalCount() int32 {
+	return int32(p.total)
+}
+
+func (p *bookPageResolver) Items() []*bookResolver {
+	return p.items
+}
+
+type bookResolver struct {
+	book models.Book
+}
+
+// newBookResolvers primes the request's author loader with the authors of
+// books, so resolving their Author.books fields costs one query in total.
+func newBookResolvers(ctx context.Context, books []models.Book) []*bookResolver {
+	resolvers := make([]*bookResolver, len(books))
+	authors := make([]string, len(books))
+	for i, b := range books {
+		resolvers[i] = &bookResolver{book: b}
+		authors[i] = b.Author
+	}
+	loaderFromContext(ctx).prime(authors...)
+	return resolvers
+}
+
+func (b *bookResolver) ID() graphql.ID {
+	return graphql.ID(strconv.FormatUint(uint64(b.book.ID), 10))
+}
+
+func (b *bookResolver) Name() string {
+	return b.book.Name
+}
+
+func (b *bookResolver) Author() *authorResolver {
+	return &authorResolver{name: b.book.Author}
+}
+
+func (b *bookResolver) Publication() string {
+	return b.book.Publication
+}
+
+type authorResolver struct {
+	name string
+}
+
+func (a *authorResolver) Name() string {
+	return a.name
+}
+
+func (a *authorResolver) Books(ctx context.Context) ([]*bookResolver, error) {
+	books, err := loaderFromContext(ctx).load(ctx, a.name)
+	if err != nil {
+		return nil, publicError(ctx, err)
+	}
+	return newBookResolvers(ctx, books), nil
+}
+
+func parseID(id graphql.ID) (int64, error) {
+	n, err := strconv.ParseInt(string(id), 10, 64)
+	if err != nil {
+		return 0, errors.New("id must be an integer")
+	}
+	return n, nil
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// publicError passes model error classes through to the client and hides
+// anything else, as the REST handlers do.
+func publicError(ctx context.Context, err error) error {
+	if errors.Is(err, models.ErrNotFound) || errors.Is(err, models.ErrMissingFields) || errors.Is(err, models.ErrNoTenant) {
+		return err
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		utils.LoggerFromContext(ctx).Warn("graphql resolver timed out", "error", err)
+		return errTimeout
+	}
+	utils.LoggerFromContext(ctx).Error("graphql resolver failed", "error", err)
+	return errInternal
+}
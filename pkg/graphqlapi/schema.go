@@ -0,0 +1,56 @@
+package graphqlapi
+
+// Authors are not a table of their own; an Author is the set of books that
+// share an author name.
+const schema = `
+schema {
+	query: Query
+	mutation: Mutation
+}
+
+type Query {
+	books(filter: BookFilter, limit: Int = 20, offset: Int = 0): BookPage!
+	book(id: ID!): Book
+}
+
+type Mutation {
+	createBook(input: BookInput!): Book!
+	updateBook(id: ID!, input: BookUpdate!): Book!
+	deleteBook(id: ID!): Book!
+}
+
+input BookFilter {
+	nameContains: String
+	author: String
+	publication: String
+}
+
+input BookInput {
+	name: String!
+	author: String!
+	publication: String!
+}
+
+input BookUpdate {
+	name: String
+	author: String
+	publication: String
+}
+
+type BookPage {
+	totalCount: Int!
+	items: [Book!]!
+}
+
+type Book {
+	id: ID!
+	name: String!
+	author: Author!
+	publication: String!
+}
+
+type Author {
+	name: String!
+	books: [Book!]!
+}
+`
@@ -0,0 +1,29 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mg4603/go-bookstore-management-system/pkg/models"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// statusFromError maps model error classes to gRPC status codes. Unexpected
+// errors are reported as Internal without their details, as the REST API
+// does.
+func statusFromError(err error) error {
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, models.ErrMissingFields), errors.Is(err, models.ErrNoTenant):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, models.ErrInvalidAPIKey):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	return status.Error(codes.Internal, "An error occurred. Please try again later.")
+}
@@ -0,0 +1,35 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mg4603/go-bookstore-management-system/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestStatusFromError(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		expectedCode codes.Code
+	}{
+		{name: "Not found", err: fmt.Errorf("book with ID 7 %w", models.ErrNotFound), expectedCode: codes.NotFound},
+		{name: "Missing fields", err: models.ErrMissingFields, expectedCode: codes.InvalidArgument},
+		{name: "No tenant", err: models.ErrNoTenant, expectedCode: codes.InvalidArgument},
+		{name: "Invalid API key", err: models.ErrInvalidAPIKey, expectedCode: codes.Unauthenticated},
+		{name: "Canceled", err: fmt.Errorf("query: %w", context.Canceled), expectedCode: codes.Canceled},
+		{name: "Deadline", err: context.DeadlineExceeded, expectedCode: codes.DeadlineExceeded},
+		{name: "Anything else", err: errors.New("sql: database is closed"), expectedCode: codes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedCode, status.Code(statusFromError(tt.err)))
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package grpcserver
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/mg4603/go-bookstore-management-system/pkg/bookstorepb"
+	"github.com/mg4603/go-bookstore-management-system/pkg/models"
+	"github.com/mg4603/go-bookstore-management-system/pkg/tenant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// listBatchSize is how many books ListBooks reads from the database at a time.
+const listBatchSize = 500
+
+// BookService implements bookstorepb.BookServiceServer on the same models
+// layer as the REST controllers.
+type BookService struct {
+	bookstorepb.UnimplementedBookServiceServer
+	db models.BookstoreDB
+}
+
+func NewBookService(db models.BookstoreDB) *BookService {
+	return &BookService{db: db}
+}
+
+// NewServer returns a gRPC server with BookService registered, every call
+// logged and scoped to the tenant tenants resolves for it.
+func NewServer(db models.BookstoreDB, tenants *tenant.Resolver, logger *slog.Logger) *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unaryLogger(logger), unaryTenant(tenants)),
+		grpc.ChainStreamInterceptor(streamLogger(logger), streamTenant(tenants)),
+	)
+	bookstorepb.RegisterBookServiceServer(srv, NewBookService(db))
+	return srv
+}
+
+func (s *BookService) CreateBook(ctx context.Context, req *bookstorepb.CreateBookRequest) (*bookstorepb.Book, error) {
+	if req.GetBook() == nil {
+		return nil, status.Error(codes.InvalidArgument, "book is required")
+	}
+
+	book := &models.Book{Name: req.Book.GetName(), Author: req.Book.GetAuthor(), Publication: req.Book.GetPublication()}
+	if err := s.db.CreateBook(ctx, book); err != nil {
+		return nil, statusFromError(err)
+	}
+	return toProto(book), nil
+}
+
+func (s *BookService) GetBook(ctx context.Context, req *bookstorepb.GetBookRequest) (*bookstorepb.Book, error) {
+	book, err := s.db.GetBookById(ctx, req.GetId())
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	return toProto(book), nil
+}
+
+func (s *BookService) ListBooks(req *bookstorepb.ListBooksRequest, stream grpc.ServerStreamingServer[bookstorepb.Book]) error {
+	// Send errors already carry a status and are returned as they are.
+	var sendErr error
+	err := s.db.EachBook(stream.Context(), listBatchSize, func(batch []models.Book) error {
+		for i := range batch {
+			if sendErr = stream.Send(toProto(&batch[i])); sendErr != nil {
+				return sendErr
+			}
+		}
+		return nil
+	})
+	if sendErr != nil {
+		return sendErr
+	}
+	if err != nil {
+		return statusFromError(err)
+	}
+	return nil
+}
+
+func (s *BookService) UpdateBook(ctx context.Context, req *bookstorepb.UpdateBookRequest) (*bookstorepb.Book, error) {
+	book := &models.Book{
+		ID:          uint(req.GetId()),
+		Name:        req.GetBook().GetName(),
+		Author:      req.GetBook().GetAuthor(),
+		Publication: req.GetBook().GetPublication(),
+	}
+	if err := s.db.UpdateBook(ctx, book); err != nil {
+		return nil, statusFromError(err)
+	}
+	return toProto(book), nil
+}
+
+func (s *BookService) DeleteBook(ctx context.Context, req *bookstorepb.DeleteBookRequest) (*bookstorepb.Book, error) {
+	book, err := s.db.DeleteBook(ctx, req.GetId())
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	return toProto(book), nil
+}
+
+func toProto(b *models.Book) *bookstorepb.Book {
+	return &bookstorepb.Book{Id: uint64(b.ID), Name: b.Name, Author: b.Author, Publication: b.Publication}
+}
+
+func unaryLogger(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logCall(ctx, logger, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+func streamLogger(logger *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		logCall(ss.Context(), logger, info.FullMethod, start, err)
+		return err
+	}
+}
+
+func logCall(ctx context.Context, logger *slog.Logger, method string, start time.Time, err error) {
+	code := status.Code(err)
+	level := slog.LevelInfo
+	switch code {
+	case codes.OK, codes.NotFound, codes.InvalidArgument, codes.Canceled:
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+		level = slog.LevelError
+	default:
+		level = slog.LevelWarn
+	}
+	attrs := []slog.Attr{
+		slog.String("method", method),
+		slog.String("code", code.String()),
+		slog.Duration("duration", time.Since(start)),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	logger.LogAttrs(ctx, level, "grpc call", attrs...)
+}
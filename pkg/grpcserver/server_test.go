@@ -0,0 +1,189 @@
+package grpcserver
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+
+	"github.com/mg4603/go-bookstore-management-system/pkg/bookstorepb"
+	"github.com/mg4603/go-bookstore-management-system/pkg/models"
+	"github.com/mg4603/go-bookstore-management-system/pkg/tenant"
+	"github.com/mg4603/go-bookstore-management-system/pkg/tests"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newClient serves BookService over an in-memory connection backed by a
+// fresh test database.
+func newClient(t *testing.T) (bookstorepb.BookServiceClient, *models.DBModel) {
+	mockDB, err := tests.Setup()
+	assert.NoError(t, err, "failed to setup test database")
+	db := &models.DBModel{DB: mockDB}
+
+	listener := bufconn.Listen(1 << 20)
+	srv := NewServer(db, tenant.NewResolver(db, tenant.Config{Default: "default"}), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	go srv.Serve(listener)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.NoError(t, err)
+
+	t.Cleanup(func() {
+		conn.Close()
+		srv.Stop()
+		sqlDB, _ := mockDB.DB()
+		if sqlDB != nil {
+			sqlDB.Close()
+		}
+	})
+	return bookstorepb.NewBookServiceClient(conn), db
+}
+
+func TestBookService(t *testing.T) {
+	client, _ := newClient(t)
+	ctx := context.Background()
+
+	created, err := client.CreateBook(ctx, &bookstorepb.CreateBookRequest{Book: &bookstorepb.Book{Name: "Book1", Author: "Author1", Publication: "Publication1"}})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), created.GetId())
+
+	_, err = client.CreateBook(ctx, &bookstorepb.CreateBookRequest{Book: &bookstorepb.Book{Name: "Book2", Author: "Author2", Publication: "Publication2"}})
+	assert.NoError(t, err)
+
+	fetched, err := client.GetBook(ctx, &bookstorepb.GetBookRequest{Id: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, "Book1", fetched.GetName())
+
+	updated, err := client.UpdateBook(ctx, &bookstorepb.UpdateBookRequest{Id: 1, Book: &bookstorepb.Book{Author: "Author3"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "Book1", updated.GetName(), "empty fields should be left unchanged")
+	assert.Equal(t, "Author3", updated.GetAuthor())
+
+	stream, err := client.ListBooks(ctx, &bookstorepb.ListBooksRequest{})
+	assert.NoError(t, err)
+	var names []string
+	for {
+		book, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		names = append(names, book.GetName())
+	}
+	assert.Equal(t, []string{"Book1", "Book2"}, names)
+
+	deleted, err := client.DeleteBook(ctx, &bookstorepb.DeleteBookRequest{Id: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, "Book2", deleted.GetName())
+
+	_, err = client.GetBook(ctx, &bookstorepb.GetBookRequest{Id: 2})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestBookServiceErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		call         func(client bookstorepb.BookServiceClient) error
+		expectedCode codes.Code
+	}{
+		{
+			name: "Create without book",
+			call: func(client bookstorepb.BookServiceClient) error {
+				_, err := client.CreateBook(context.Background(), &bookstorepb.CreateBookRequest{})
+				return err
+			},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name: "Create with missing fields",
+			call: func(client bookstorepb.BookServiceClient) error {
+				_, err := client.CreateBook(context.Background(), &bookstorepb.CreateBookRequest{Book: &bookstorepb.Book{Name: "Book1"}})
+				return err
+			},
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			name: "Update missing book",
+			call: func(client bookstorepb.BookServiceClient) error {
+				_, err := client.UpdateBook(context.Background(), &bookstorepb.UpdateBookRequest{Id: 7, Book: &bookstorepb.Book{Name: "Book7"}})
+				return err
+			},
+			expectedCode: codes.NotFound,
+		},
+		{
+			name: "Delete missing book",
+			call: func(client bookstorepb.BookServiceClient) error {
+				_, err := client.DeleteBook(context.Background(), &bookstorepb.DeleteBookRequest{Id: 7})
+				return err
+			},
+			expectedCode: codes.NotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := newClient(t)
+			assert.Equal(t, tt.expectedCode, status.Code(tt.call(client)))
+		})
+	}
+}
+
+func TestBookServiceDatabaseError(t *testing.T) {
+	client, db := newClient(t)
+	sqlDB, _ := db.DB.DB()
+	sqlDB.Close()
+
+	_, err := client.GetBook(context.Background(), &bookstorepb.GetBookRequest{Id: 1})
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Equal(t, "An error occurred. Please try again later.", status.Convert(err).Message(), "internal details must not leak")
+}
+
+func TestBookServiceTenants(t *testing.T) {
+	client, db := newClient(t)
+	other, err := db.CreateTenant(context.Background(), "other", "Other")
+	assert.NoError(t, err)
+	otherKey, _, err := db.CreateAPIKey(models.WithTenant(context.Background(), other.ID), "other")
+	assert.NoError(t, err)
+	mine := context.Background()
+	theirs := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+otherKey)
+
+	created, err := client.CreateBook(mine, &bookstorepb.CreateBookRequest{Book: &bookstorepb.Book{Name: "Book1", Author: "Author1", Publication: "Publication1"}})
+	assert.NoError(t, err)
+
+	_, err = client.GetBook(theirs, &bookstorepb.GetBookRequest{Id: int64(created.GetId())})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.DeleteBook(theirs, &bookstorepb.DeleteBookRequest{Id: int64(created.GetId())})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	stream, err := client.ListBooks(theirs, &bookstorepb.ListBooksRequest{})
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, io.EOF, err, "another tenant's list must be empty")
+
+	_, err = client.GetBook(mine, &bookstorepb.GetBookRequest{Id: int64(created.GetId())})
+	assert.NoError(t, err)
+
+	unknown := metadata.AppendToOutgoingContext(context.Background(), "x-tenant", "unknown", "authorization", "Bearer "+otherKey)
+	_, err = client.GetBook(unknown, &bookstorepb.GetBookRequest{Id: int64(created.GetId())})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	stream, err = client.ListBooks(unknown, &bookstorepb.ListBooksRequest{})
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	unauthenticated := metadata.AppendToOutgoingContext(context.Background(), "x-tenant", "other")
+	_, err = client.GetBook(unauthenticated, &bookstorepb.GetBookRequest{Id: int64(created.GetId())})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "naming a tenant without its API key is rejected")
+	unauthenticated = metadata.AppendToOutgoingContext(context.Background(), "x-tenant", "unknown")
+	_, err = client.GetBook(unauthenticated, &bookstorepb.GetBookRequest{Id: int64(created.GetId())})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "callers without a key cannot tell unknown tenants from real ones")
+}
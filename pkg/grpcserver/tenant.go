@@ -0,0 +1,76 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/mg4603/go-bookstore-management-system/pkg/models"
+	"github.com/mg4603/go-bookstore-management-system/pkg/tenant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// withTenant resolves the tenant of a call as the REST API does, from the
+// authorization and x-tenant metadata and the :authority host.
+func withTenant(ctx context.Context, tenants *tenant.Resolver) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	get := func(key string) string {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	var apiKey string
+	if token, ok := strings.CutPrefix(get("authorization"), "Bearer "); ok {
+		apiKey = token
+	}
+
+	tenantID, ok, err := tenants.Resolve(ctx, tenant.Request{APIKey: apiKey, Slug: get(strings.ToLower(tenant.Header)), Host: get(":authority")})
+	switch {
+	case errors.Is(err, tenant.ErrKeyRequired):
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, tenant.ErrUnknownTenant):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, tenant.ErrWrongTenant):
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, tenant.ErrTenantMismatch):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case err != nil:
+		return nil, statusFromError(err)
+	case !ok:
+		return ctx, nil
+	}
+	return models.WithTenant(ctx, tenantID), nil
+}
+
+func unaryTenant(tenants *tenant.Resolver) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := withTenant(ctx, tenants)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func streamTenant(tenants *tenant.Resolver) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := withTenant(ss.Context(), tenants)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &tenantStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+type tenantStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *tenantStream) Context() context.Context {
+	return s.ctx
+}
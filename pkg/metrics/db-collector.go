@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"database/sql"
+)
+
+type DBStatser interface {
+	Stats() sql.DBStats
+}
+
+type dbStatsCollector struct {
+	db DBStatser
+}
+
+// NewDBStatsCollector exposes the connection pool statistics of db.
+func NewDBStatsCollector(db DBStatser) Collector {
+	return &dbStatsCollector{db: db}
+}
+
+func (c *dbStatsCollector) Collect() []Family {
+	stats := c.db.Stats()
+
+	gauge := func(name, help string, v float64) Family {
+		return Family{Name: name, Help: help, Type: "gauge", Samples: []Sample{{Value: v}}}
+	}
+	counter := func(name, help string, v float64) Family {
+		return Family{Name: name, Help: help, Type: "counter", Samples: []Sample{{Value: v}}}
+	}
+
+	return []Family{
+		gauge("db_max_open_connections", "Maximum number of open connections to the database.", float64(stats.MaxOpenConnections)),
+		gauge("db_open_connections", "Number of established connections, both in use and idle.", float64(stats.OpenConnections)),
+		gauge("db_in_use_connections", "Number of connections currently in use.", float64(stats.InUse)),
+		gauge("db_idle_connections", "Number of idle connections.", float64(stats.Idle)),
+		counter("db_wait_count_total", "Total number of connections waited for.", float64(stats.WaitCount)),
+		counter("db_wait_duration_seconds_total", "Total time blocked waiting for a new connection.", stats.WaitDuration.Seconds()),
+		counter("db_max_idle_closed_total", "Total connections closed due to SetMaxIdleConns.", float64(stats.MaxIdleClosed)),
+		counter("db_max_idle_time_closed_total", "Total connections closed due to SetConnMaxIdleTime.", float64(stats.MaxIdleTimeClosed)),
+		counter("db_max_lifetime_closed_total", "Total connections closed due to SetConnMaxLifetime.", float64(stats.MaxLifetimeClosed)),
+	}
+}
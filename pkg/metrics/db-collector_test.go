@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeStatser struct {
+	stats sql.DBStats
+}
+
+func (f fakeStatser) Stats() sql.DBStats {
+	return f.stats
+}
+
+func TestDBStatsCollector(t *testing.T) {
+	c := NewDBStatsCollector(fakeStatser{stats: sql.DBStats{
+		MaxOpenConnections: 10,
+		OpenConnections:    4,
+		InUse:              3,
+		Idle:               1,
+		WaitCount:          7,
+		WaitDuration:       1500 * time.Millisecond,
+	}})
+
+	values := map[string]float64{}
+	for _, f := range c.Collect() {
+		assert.Len(t, f.Samples, 1, "family %s", f.Name)
+		values[f.Name] = f.Samples[0].Value
+	}
+
+	assert.Equal(t, float64(10), values["db_max_open_connections"])
+	assert.Equal(t, float64(4), values["db_open_connections"])
+	assert.Equal(t, float64(3), values["db_in_use_connections"])
+	assert.Equal(t, float64(1), values["db_idle_connections"])
+	assert.Equal(t, float64(7), values["db_wait_count_total"])
+	assert.Equal(t, 1.5, values["db_wait_duration_seconds_total"])
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mg4603/go-bookstore-management-system/pkg/utils"
+)
+
+type HTTPMetrics struct {
+	requests *CounterVec
+	duration *HistogramVec
+}
+
+func NewHTTPMetrics(reg *Registry) *HTTPMetrics {
+	return &HTTPMetrics{
+		requests: reg.NewCounterVec("http_requests_total", "Total HTTP requests by method, route template and status code.", "method", "route", "status"),
+		duration: reg.NewHistogramVec("http_request_duration_seconds", "HTTP request latency by method and route template.", DefaultBuckets, "method", "route"),
+	}
+}
+
+// Middleware records each request against its mux route template rather than
+// the raw path, so /books/1 and /books/2 share one series. It must be
+// installed with Router.Use so the matched route is available.
+func (m *HTTPMetrics) Middleware(n http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := utils.NewResponseRecorder(w)
+
+		n.ServeHTTP(rec, r)
+
+		route := routeTemplate(r)
+		m.requests.Inc(r.Method, route, strconv.Itoa(rec.Status()))
+		m.duration.Observe(time.Since(start).Seconds(), r.Method, route)
+	})
+}
+
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			return tmpl
+		}
+	}
+	return "unmatched"
+}
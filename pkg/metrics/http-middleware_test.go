@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPMetricsMiddleware(t *testing.T) {
+	reg := NewRegistry()
+	m := NewHTTPMetrics(reg)
+
+	r := mux.NewRouter()
+	r.Use(m.Middleware)
+	r.HandleFunc("/books/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["id"] == "404" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	}).Methods("GET")
+
+	for _, path := range []string{"/books/1", "/books/2", "/books/404"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteText(&buf, m.requests.Collect()))
+	assert.Equal(t, "# HELP http_requests_total Total HTTP requests by method, route template and status code.\n"+
+		"# TYPE http_requests_total counter\n"+
+		"http_requests_total{method=\"GET\",route=\"/books/{id}\",status=\"200\"} 2\n"+
+		"http_requests_total{method=\"GET\",route=\"/books/{id}\",status=\"404\"} 1\n", buf.String())
+
+	families := m.duration.Collect()
+	assert.Len(t, families, 1)
+	var count float64
+	for _, s := range families[0].Samples {
+		if s.Suffix == "_count" {
+			count = s.Value
+		}
+	}
+	assert.Equal(t, float64(3), count)
+}
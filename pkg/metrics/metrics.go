@@ -0,0 +1,293 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+type Sample struct {
+	// Suffix is appended to the family name, e.g. "_bucket" for histograms.
+	Suffix string
+	Labels []Label
+	Value  float64
+}
+
+type Family struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []Sample
+}
+
+type Collector interface {
+	Collect() []Family
+}
+
+type Registry struct {
+	mu         sync.Mutex
+	collectors []Collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (reg *Registry) Register(c Collector) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.collectors = append(reg.collectors, c)
+}
+
+func (reg *Registry) NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	c := &CounterVec{name: name, help: help, labelNames: labelNames, values: map[string]*counterValue{}}
+	reg.Register(c)
+	return c
+}
+
+func (reg *Registry) NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	h := &HistogramVec{name: name, help: help, buckets: buckets, labelNames: labelNames, values: map[string]*histogramValue{}}
+	reg.Register(h)
+	return h
+}
+
+func (reg *Registry) NewGaugeFunc(name, help string, fn func() (float64, error)) {
+	reg.Register(&gaugeFunc{name: name, help: help, fn: fn})
+}
+
+func (reg *Registry) Gather() []Family {
+	reg.mu.Lock()
+	collectors := append([]Collector(nil), reg.collectors...)
+	reg.mu.Unlock()
+
+	var families []Family
+	for _, c := range collectors {
+		families = append(families, c.Collect()...)
+	}
+	sort.SliceStable(families, func(i, j int) bool { return families[i].Name < families[j].Name })
+	return families
+}
+
+func (reg *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		WriteText(w, reg.Gather())
+	})
+}
+
+// WriteText encodes families in the Prometheus text exposition format.
+func WriteText(w io.Writer, families []Family) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		if f.Help != "" {
+			fmt.Fprintf(bw, "# HELP %s %s\n", f.Name, escapeHelp(f.Help))
+		}
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.Name, f.Type)
+		for _, s := range f.Samples {
+			bw.WriteString(f.Name + s.Suffix)
+			if len(s.Labels) > 0 {
+				bw.WriteByte('{')
+				for i, l := range s.Labels {
+					if i > 0 {
+						bw.WriteByte(',')
+					}
+					fmt.Fprintf(bw, "%s=\"%s\"", l.Name, escapeLabelValue(l.Value))
+				}
+				bw.WriteByte('}')
+			}
+			bw.WriteByte(' ')
+			bw.WriteString(formatValue(s.Value))
+			bw.WriteByte('\n')
+		}
+	}
+	return bw.Flush()
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func labelPairs(names, values []string) []Label {
+	labels := make([]Label, len(names))
+	for i, name := range names {
+		labels[i] = Label{Name: name, Value: values[i]}
+	}
+	return labels
+}
+
+// labelKey joins label values with a separator that cannot appear in valid
+// UTF-8, so distinct label sets never collide.
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+type counterValue struct {
+	labels []string
+	value  float64
+}
+
+type CounterVec struct {
+	name       string
+	help       string
+	labelNames []string
+
+	mu     sync.Mutex
+	values map[string]*counterValue
+}
+
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if len(labelValues) != len(c.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", c.name, len(c.labelNames), len(labelValues)))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := labelKey(labelValues)
+	v, ok := c.values[key]
+	if !ok {
+		v = &counterValue{labels: append([]string(nil), labelValues...)}
+		c.values[key] = v
+	}
+	v.value += delta
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) Collect() []Family {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := make([]string, 0, len(c.values))
+	for k := range c.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	f := Family{Name: c.name, Help: c.help, Type: "counter"}
+	for _, k := range keys {
+		v := c.values[k]
+		f.Samples = append(f.Samples, Sample{Labels: labelPairs(c.labelNames, v.labels), Value: v.value})
+	}
+	return []Family{f}
+}
+
+type histogramValue struct {
+	labels []string
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+type HistogramVec struct {
+	name       string
+	help       string
+	buckets    []float64
+	labelNames []string
+
+	mu     sync.Mutex
+	values map[string]*histogramValue
+}
+
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	if len(labelValues) != len(h.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", h.name, len(h.labelNames), len(labelValues)))
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := labelKey(labelValues)
+	hv, ok := h.values[key]
+	if !ok {
+		hv = &histogramValue{labels: append([]string(nil), labelValues...), counts: make([]uint64, len(h.buckets))}
+		h.values[key] = hv
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			hv.counts[i]++
+		}
+	}
+	hv.count++
+	hv.sum += v
+}
+
+func (h *HistogramVec) Collect() []Family {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	keys := make([]string, 0, len(h.values))
+	for k := range h.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	f := Family{Name: h.name, Help: h.help, Type: "histogram"}
+	for _, k := range keys {
+		hv := h.values[k]
+		labels := labelPairs(h.labelNames, hv.labels)
+		for i, upper := range h.buckets {
+			le := append(append([]Label(nil), labels...), Label{Name: "le", Value: formatValue(upper)})
+			f.Samples = append(f.Samples, Sample{Suffix: "_bucket", Labels: le, Value: float64(hv.counts[i])})
+		}
+		inf := append(append([]Label(nil), labels...), Label{Name: "le", Value: "+Inf"})
+		f.Samples = append(f.Samples,
+			Sample{Suffix: "_bucket", Labels: inf, Value: float64(hv.count)},
+			Sample{Suffix: "_sum", Labels: labels, Value: hv.sum},
+			Sample{Suffix: "_count", Labels: labels, Value: float64(hv.count)},
+		)
+	}
+	return []Family{f}
+}
+
+type gaugeFunc struct {
+	name string
+	help string
+	fn   func() (float64, error)
+}
+
+// Collect omits the sample when fn fails, so a scrape never reports a stale or
+// made-up value.
+func (g *gaugeFunc) Collect() []Family {
+	f := Family{Name: g.name, Help: g.help, Type: "gauge"}
+	if v, err := g.fn(); err == nil {
+		f.Samples = []Sample{{Value: v}}
+	}
+	return []Family{f}
+}
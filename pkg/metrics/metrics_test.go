@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteText(t *testing.T) {
+	tests := []struct {
+		name     string
+		families []Family
+		expected string
+	}{
+		{
+			name: "Counter with labels",
+			families: []Family{{
+				Name: "requests_total", Help: "Total requests.", Type: "counter",
+				Samples: []Sample{{Labels: []Label{{Name: "method", Value: "GET"}, {Name: "status", Value: "200"}}, Value: 3}},
+			}},
+			expected: "# HELP requests_total Total requests.\n# TYPE requests_total counter\nrequests_total{method=\"GET\",status=\"200\"} 3\n",
+		},
+		{
+			name: "Escaping of help and label values",
+			families: []Family{{
+				Name: "escaped", Help: "line\\one\nline two", Type: "gauge",
+				Samples: []Sample{{Labels: []Label{{Name: "path", Value: "a\"b\\c\nd"}}, Value: 1.5}},
+			}},
+			expected: "# HELP escaped line\\\\one\\nline two\n# TYPE escaped gauge\nescaped{path=\"a\\\"b\\\\c\\nd\"} 1.5\n",
+		},
+		{
+			name: "Special float values",
+			families: []Family{{
+				Name: "special", Type: "gauge",
+				Samples: []Sample{{Value: math.Inf(1)}, {Value: math.Inf(-1)}, {Value: math.NaN()}},
+			}},
+			expected: "# TYPE special gauge\nspecial +Inf\nspecial -Inf\nspecial NaN\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			assert.NoError(t, WriteText(&buf, tt.families))
+			assert.Equal(t, tt.expected, buf.String())
+		})
+	}
+}
+
+func TestCounterVec(t *testing.T) {
+	reg := NewRegistry()
+	c := reg.NewCounterVec("hits_total", "Hits.", "route")
+	c.Inc("/b")
+	c.Inc("/a")
+	c.Add(2, "/a")
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteText(&buf, reg.Gather()))
+	assert.Equal(t, "# HELP hits_total Hits.\n# TYPE hits_total counter\nhits_total{route=\"/a\"} 3\nhits_total{route=\"/b\"} 1\n", buf.String())
+
+	assert.Panics(t, func() { c.Inc() })
+}
+
+func TestHistogramVec(t *testing.T) {
+	reg := NewRegistry()
+	h := reg.NewHistogramVec("latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+	h.Observe(0.05, "/a")
+	h.Observe(0.5, "/a")
+	h.Observe(2, "/a")
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteText(&buf, reg.Gather()))
+	assert.Equal(t, "# HELP latency_seconds Latency.\n# TYPE latency_seconds histogram\n"+
+		"latency_seconds_bucket{route=\"/a\",le=\"0.1\"} 1\n"+
+		"latency_seconds_bucket{route=\"/a\",le=\"1\"} 2\n"+
+		"latency_seconds_bucket{route=\"/a\",le=\"+Inf\"} 3\n"+
+		"latency_seconds_sum{route=\"/a\"} 2.55\n"+
+		"latency_seconds_count{route=\"/a\"} 3\n", buf.String())
+}
+
+func TestGaugeFunc(t *testing.T) {
+	tests := []struct {
+		name     string
+		fn       func() (float64, error)
+		expected string
+	}{
+		{
+			name:     "Value reported",
+			fn:       func() (float64, error) { return 42, nil },
+			expected: "# HELP books_total Books.\n# TYPE books_total gauge\nbooks_total 42\n",
+		},
+		{
+			name:     "Error omits the sample",
+			fn:       func() (float64, error) { return 0, errors.New("db down") },
+			expected: "# HELP books_total Books.\n# TYPE books_total gauge\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := NewRegistry()
+			reg.NewGaugeFunc("books_total", "Books.", tt.fn)
+
+			rec := httptest.NewRecorder()
+			reg.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, contentType, rec.Header().Get("Content-Type"))
+			assert.Equal(t, tt.expected, rec.Body.String())
+		})
+	}
+}
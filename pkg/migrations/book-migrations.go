@@ -0,0 +1,240 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migrations use their own snapshots of the schema rather than the models
+// package, so later model changes never alter what an old migration does.
+
+type booksV1 struct {
+	ID          uint `gorm:"primarykey"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   time.Time `gorm:"index"`
+	Name        string    `gorm:"not null"`
+	Author      string    `gorm:"not null"`
+	Publication string    `gorm:"not null"`
+}
+
+func (booksV1) TableName() string {
+	return "books"
+}
+
+type apiKeysV1 struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	Name      string `gorm:"not null"`
+	Prefix    string `gorm:"not null"`
+	KeyHash   string `gorm:"not null;uniqueIndex;size:64"`
+}
+
+func (apiKeysV1) TableName() string {
+	return "api_keys"
+}
+
+type tenantsV1 struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	Slug      string `gorm:"not null;uniqueIndex;size:63"`
+	Name      string `gorm:"not null"`
+}
+
+func (tenantsV1) TableName() string {
+	return "tenants"
+}
+
+type booksV2 struct {
+	ID          uint `gorm:"primarykey"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   time.Time `gorm:"index"`
+	TenantID    uint      `gorm:"not null;default:0;index"`
+	Name        string    `gorm:"not null"`
+	Author      string    `gorm:"not null"`
+	Publication string    `gorm:"not null"`
+}
+
+func (booksV2) TableName() string {
+	return "books"
+}
+
+type apiKeysV2 struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	TenantID  uint   `gorm:"not null;default:0;index"`
+	Name      string `gorm:"not null"`
+	Prefix    string `gorm:"not null"`
+	KeyHash   string `gorm:"not null;uniqueIndex;size:64"`
+}
+
+func (apiKeysV2) TableName() string {
+	return "api_keys"
+}
+
+type webhooksV1 struct {
+	ID        uint `gorm:"primarykey"`
+	TenantID  uint `gorm:"not null;index"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	URL       string `gorm:"not null"`
+	Events    string `gorm:"not null"`
+	Secret    string `gorm:"not null"`
+	Active    bool   `gorm:"not null"`
+}
+
+func (webhooksV1) TableName() string {
+	return "webhooks"
+}
+
+type outboxEventsV1 struct {
+	ID           uint `gorm:"primarykey"`
+	TenantID     uint `gorm:"not null"`
+	CreatedAt    time.Time
+	Type         string     `gorm:"not null"`
+	Payload      []byte     `gorm:"not null"`
+	DispatchedAt *time.Time `gorm:"index"`
+}
+
+func (outboxEventsV1) TableName() string {
+	return "outbox_events"
+}
+
+type webhookDeliveriesV1 struct {
+	ID             uint `gorm:"primarykey"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	WebhookID      uint      `gorm:"not null;index"`
+	EventID        uint      `gorm:"not null;index"`
+	EventType      string    `gorm:"not null"`
+	Status         string    `gorm:"not null;index:idx_webhook_deliveries_due,priority:1"`
+	NextAttemptAt  time.Time `gorm:"not null;index:idx_webhook_deliveries_due,priority:2"`
+	Attempts       int       `gorm:"not null"`
+	ResponseStatus int
+	LastError      string
+}
+
+func (webhookDeliveriesV1) TableName() string {
+	return "webhook_deliveries"
+}
+
+type tenantsV2 struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	Slug      string `gorm:"not null;uniqueIndex;size:63"`
+	Name      string `gorm:"not null"`
+	EventSeq  uint64 `gorm:"not null;default:0"`
+}
+
+func (tenantsV2) TableName() string {
+	return "tenants"
+}
+
+type bookEventsV1 struct {
+	ID        uint      `gorm:"primarykey"`
+	TenantID  uint      `gorm:"not null;uniqueIndex:idx_book_events_tenant_seq,priority:1"`
+	Seq       uint64    `gorm:"not null;uniqueIndex:idx_book_events_tenant_seq,priority:2"`
+	CreatedAt time.Time `gorm:"index"`
+	Type      string    `gorm:"not null"`
+	Payload   []byte    `gorm:"not null"`
+}
+
+func (bookEventsV1) TableName() string {
+	return "book_events"
+}
+
+// DefaultTenantSlug names the tenant that add_tenants gives existing rows.
+const DefaultTenantSlug = "default"
+
+var All = []Migration{
+	{
+		Version: 1,
+		Name:    "create_books",
+		// Databases created by the old AutoMigrate startup already have the
+		// table; adopt it instead of failing.
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasTable(&booksV1{}) {
+				return nil
+			}
+			return tx.Migrator().CreateTable(&booksV1{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&booksV1{})
+		},
+	},
+	{
+		Version: 2,
+		Name:    "create_api_keys",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&apiKeysV1{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&apiKeysV1{})
+		},
+	},
+	{
+		Version: 3,
+		Name:    "add_tenants",
+		// Books and API keys from before tenants go to a default tenant.
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().CreateTable(&tenantsV1{}); err != nil {
+				return err
+			}
+			tenant := &tenantsV1{Slug: DefaultTenantSlug, Name: "Default"}
+			if err := tx.Create(tenant).Error; err != nil {
+				return err
+			}
+			for _, table := range []interface{}{&booksV2{}, &apiKeysV2{}} {
+				if err := tx.Migrator().AddColumn(table, "TenantID"); err != nil {
+					return err
+				}
+				if err := tx.Migrator().CreateIndex(table, "TenantID"); err != nil {
+					return err
+				}
+				if err := tx.Model(table).Where("1 = 1").Update("tenant_id", tenant.ID).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, table := range []interface{}{&booksV2{}, &apiKeysV2{}} {
+				if err := tx.Migrator().DropIndex(table, "TenantID"); err != nil {
+					return err
+				}
+				if err := tx.Migrator().DropColumn(table, "TenantID"); err != nil {
+					return err
+				}
+			}
+			return tx.Migrator().DropTable(&tenantsV1{})
+		},
+	},
+	{
+		Version: 4,
+		Name:    "create_webhooks",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&webhooksV1{}, &outboxEventsV1{}, &webhookDeliveriesV1{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&webhookDeliveriesV1{}, &outboxEventsV1{}, &webhooksV1{})
+		},
+	},
+	{
+		Version: 5,
+		Name:    "create_book_events",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&tenantsV2{}, "EventSeq"); err != nil {
+				return err
+			}
+			return tx.Migrator().CreateTable(&bookEventsV1{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(&bookEventsV1{}); err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&tenantsV2{}, "EventSeq")
+		},
+	},
+}
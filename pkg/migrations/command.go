@@ -0,0 +1,62 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+)
+
+const Usage = "usage: migrate up | down [steps] | status"
+
+// RunCommand implements the "migrate" subcommand shared by the server and the
+// admin tool.
+func RunCommand(ctx context.Context, migrator *Migrator, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(Usage)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Fprintf(out, "applied %d %s\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Fprintln(out, "no pending migrations")
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps %q: %s", args[1], Usage)
+			}
+			steps = n
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			fmt.Fprintf(out, "reverted %d %s\n", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, s := range statuses {
+			state, appliedAt := "pending", "-"
+			if s.Applied {
+				state, appliedAt = "applied", s.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("unknown migrate command %q: %s", args[0], Usage)
+}
@@ -0,0 +1,127 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const lockName = "bookstore_schema_migrations"
+
+var (
+	lockRetryInterval = 250 * time.Millisecond
+	// staleLockAge is how long a table lock may be held before another
+	// instance assumes its holder crashed and takes it over.
+	staleLockAge = 15 * time.Minute
+)
+
+type Locker interface {
+	Lock(ctx context.Context) (unlock func() error, err error)
+}
+
+// NewLocker uses a MySQL named lock when available, which the server releases
+// on its own if the holder's connection dies, and a lock row otherwise.
+func NewLocker(db *gorm.DB) Locker {
+	if db.Dialector.Name() == "mysql" {
+		return &mysqlLocker{db: db}
+	}
+	return &tableLocker{db: db}
+}
+
+type mysqlLocker struct {
+	db *gorm.DB
+}
+
+func (l *mysqlLocker) Lock(ctx context.Context) (func() error, error) {
+	sqlDB, err := l.db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	// GET_LOCK is tied to the session, so hold one connection until unlock.
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		var acquired sql.NullInt64
+		if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 1)", lockName).Scan(&acquired); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if acquired.Valid && acquired.Int64 == 1 {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return func() error {
+		defer conn.Close()
+		_, err := conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName)
+		return err
+	}, nil
+}
+
+type migrationLock struct {
+	ID       int       `gorm:"primaryKey;autoIncrement:false"`
+	Holder   string    `gorm:"not null"`
+	LockedAt time.Time `gorm:"not null"`
+}
+
+func (migrationLock) TableName() string {
+	return "schema_migrations_lock"
+}
+
+type tableLocker struct {
+	db *gorm.DB
+}
+
+func (l *tableLocker) Lock(ctx context.Context) (func() error, error) {
+	db := l.db.WithContext(ctx)
+	if !db.Migrator().HasTable(&migrationLock{}) {
+		if err := db.Migrator().CreateTable(&migrationLock{}); err != nil && !db.Migrator().HasTable(&migrationLock{}) {
+			return nil, fmt.Errorf("error creating lock table: %w", err)
+		}
+	}
+
+	hostname, _ := os.Hostname()
+	holder := fmt.Sprintf("%s/%d/%d", hostname, os.Getpid(), time.Now().UnixNano())
+
+	// A failed insert is the normal signal that someone else holds the lock,
+	// so keep it out of the query log.
+	quiet := db.Session(&gorm.Session{Logger: db.Logger.LogMode(logger.Silent)})
+	for {
+		err := quiet.Create(&migrationLock{ID: 1, Holder: holder, LockedAt: time.Now().UTC()}).Error
+		if err == nil {
+			break
+		}
+
+		var current migrationLock
+		if findErr := db.First(&current, 1).Error; findErr == nil && time.Since(current.LockedAt) > staleLockAge {
+			db.Where("id = ? AND holder = ?", 1, current.Holder).Delete(&migrationLock{})
+			continue
+		} else if findErr != nil && !errors.Is(findErr, gorm.ErrRecordNotFound) {
+			return nil, findErr
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+
+	return func() error {
+		return l.db.Where("id = ? AND holder = ?", 1, holder).Delete(&migrationLock{}).Error
+	}, nil
+}
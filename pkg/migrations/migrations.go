@@ -0,0 +1,213 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration is one versioned schema change. Up and Down run inside a
+// transaction together with the bookkeeping in schema_migrations, although
+// some databases (MySQL) commit DDL implicitly.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+type schemaMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+	locker     Locker
+	logger     *slog.Logger
+}
+
+func New(db *gorm.DB, logger *slog.Logger, migrations []Migration) (*Migrator, error) {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].Version == sorted[i-1].Version {
+			return nil, fmt.Errorf("duplicate migration version %d", sorted[i].Version)
+		}
+	}
+
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Migrator{db: db, migrations: sorted, locker: NewLocker(db), logger: logger}, nil
+}
+
+// Up applies every pending migration in version order and returns the ones it
+// applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.withLock(ctx, func(done map[int64]schemaMigration) error {
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+			if err := m.apply(ctx, migration); err != nil {
+				return err
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the most recently applied migrations, at most steps of them.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.withLock(ctx, func(done map[int64]schemaMigration) error {
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+			if err := m.revert(ctx, migration); err != nil {
+				return err
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	done, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if record, ok := done[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = record.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Pending reports whether any known migration has not been applied yet.
+func (m *Migrator) Pending(ctx context.Context) (bool, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, s := range statuses {
+		if !s.Applied {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *Migrator) withLock(ctx context.Context, fn func(done map[int64]schemaMigration) error) error {
+	if err := m.ensureTable(ctx); err != nil {
+		return err
+	}
+
+	unlock, err := m.locker.Lock(ctx)
+	if err != nil {
+		return fmt.Errorf("error acquiring migration lock: %w", err)
+	}
+	defer func() {
+		if err := unlock(); err != nil {
+			m.logger.Error("error releasing migration lock", "error", err)
+		}
+	}()
+
+	// Read the applied set only once the lock is held, since another
+	// instance may have migrated while we were waiting.
+	done, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	return fn(done)
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	db := m.db.WithContext(ctx)
+	if db.Migrator().HasTable(&schemaMigration{}) {
+		return nil
+	}
+	if err := db.Migrator().CreateTable(&schemaMigration{}); err != nil && !db.Migrator().HasTable(&schemaMigration{}) {
+		return fmt.Errorf("error creating schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int64]schemaMigration, error) {
+	var records []schemaMigration
+	if err := m.db.WithContext(ctx).Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("error reading schema_migrations: %w", err)
+	}
+
+	done := make(map[int64]schemaMigration, len(records))
+	for _, r := range records {
+		done[r.Version] = r
+	}
+	return done, nil
+}
+
+func (m *Migrator) apply(ctx context.Context, migration Migration) error {
+	m.logger.Info("applying migration", "version", migration.Version, "name", migration.Name)
+	err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := migration.Up(tx); err != nil {
+			return err
+		}
+		return tx.Create(&schemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now().UTC()}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
+
+func (m *Migrator) revert(ctx context.Context, migration Migration) error {
+	if migration.Down == nil {
+		return fmt.Errorf("migration %d (%s) cannot be rolled back", migration.Version, migration.Name)
+	}
+
+	m.logger.Info("reverting migration", "version", migration.Version, "name", migration.Name)
+	err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := migration.Down(tx); err != nil {
+			return err
+		}
+		return tx.Delete(&schemaMigration{Version: migration.Version}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("rollback of migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func setup(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	assert.NoError(t, err, "failed to open database")
+	t.Cleanup(func() {
+		sqlDB, _ := db.DB()
+		if sqlDB != nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+type widgetsV1 struct {
+	ID   uint `gorm:"primarykey"`
+	Name string
+}
+
+func (widgetsV1) TableName() string {
+	return "widgets"
+}
+
+var testMigrations = []Migration{
+	{
+		Version: 2,
+		Name:    "add_widget_colour",
+		Up: func(tx *gorm.DB) error {
+			return tx.Exec("ALTER TABLE widgets ADD COLUMN colour TEXT").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Exec("ALTER TABLE widgets DROP COLUMN colour").Error
+		},
+	},
+	{
+		Version: 1,
+		Name:    "create_widgets",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&widgetsV1{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&widgetsV1{})
+		},
+	},
+}
+
+func TestNewRejectsDuplicateVersions(t *testing.T) {
+	db := setup(t)
+	_, err := New(db, discardLogger, []Migration{{Version: 1, Name: "a"}, {Version: 1, Name: "b"}})
+	assert.EqualError(t, err, "duplicate migration version 1")
+}
+
+func TestUpDownStatus(t *testing.T) {
+	db := setup(t)
+	ctx := context.Background()
+	migrator, err := New(db, discardLogger, testMigrations)
+	assert.NoError(t, err)
+
+	pending, err := migrator.Pending(ctx)
+	assert.NoError(t, err)
+	assert.True(t, pending)
+
+	applied, err := migrator.Up(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, versions(applied), "migrations should apply in version order")
+	assert.True(t, db.Migrator().HasColumn(&widgetsV1{}, "colour"))
+
+	applied, err = migrator.Up(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, applied, "second run should be a no-op")
+
+	statuses, err := migrator.Status(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, statuses, 2)
+	for _, s := range statuses {
+		assert.True(t, s.Applied, "migration %d should be applied", s.Version)
+		assert.False(t, s.AppliedAt.IsZero())
+	}
+
+	reverted, err := migrator.Down(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2}, versions(reverted))
+	assert.False(t, db.Migrator().HasColumn(&widgetsV1{}, "colour"))
+
+	statuses, err = migrator.Status(ctx)
+	assert.NoError(t, err)
+	assert.True(t, statuses[0].Applied)
+	assert.False(t, statuses[1].Applied)
+
+	reverted, err = migrator.Down(ctx, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1}, versions(reverted))
+	assert.False(t, db.Migrator().HasTable(&widgetsV1{}))
+}
+
+func TestFailedMigrationIsNotRecorded(t *testing.T) {
+	db := setup(t)
+	ctx := context.Background()
+	migrator, err := New(db, discardLogger, []Migration{
+		testMigrations[1],
+		{Version: 2, Name: "broken", Up: func(tx *gorm.DB) error { return errors.New("boom") }},
+	})
+	assert.NoError(t, err)
+
+	applied, err := migrator.Up(ctx)
+	assert.ErrorContains(t, err, "migration 2 (broken) failed: boom")
+	assert.Equal(t, []int64{1}, versions(applied))
+
+	statuses, err := migrator.Status(ctx)
+	assert.NoError(t, err)
+	assert.True(t, statuses[0].Applied)
+	assert.False(t, statuses[1].Applied)
+}
+
+func TestCreateBooksAdoptsExistingTable(t *testing.T) {
+	db := setup(t)
+	ctx := context.Background()
+	assert.NoError(t, db.AutoMigrate(&booksV1{}))
+	assert.NoError(t, db.Create(&booksV1{Name: "Kept", Author: "Author", Publication: "Pub"}).Error)
+
+	migrator, err := New(db, discardLogger, All)
+	assert.NoError(t, err)
+	_, err = migrator.Up(ctx)
+	assert.NoError(t, err)
+
+	var count int64
+	assert.NoError(t, db.Model(&booksV1{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count, "existing rows must survive adoption")
+}
+
+func TestTableLockerExcludesConcurrentHolders(t *testing.T) {
+	db := setup(t)
+	locker := &tableLocker{db: db}
+
+	unlock, err := locker.Lock(context.Background())
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = (&tableLocker{db: db}).Lock(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "second holder must wait while the lock is held")
+
+	assert.NoError(t, unlock())
+
+	unlockAgain, err := (&tableLocker{db: db}).Lock(context.Background())
+	assert.NoError(t, err)
+	assert.NoError(t, unlockAgain())
+}
+
+func TestTableLockerTakesOverStaleLock(t *testing.T) {
+	db := setup(t)
+	_, err := (&tableLocker{db: db}).Lock(context.Background())
+	assert.NoError(t, err)
+
+	assert.NoError(t, db.Model(&migrationLock{}).Where("id = ?", 1).Update("locked_at", time.Now().Add(-2*staleLockAge)).Error)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	unlock, err := (&tableLocker{db: db}).Lock(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, unlock())
+}
+
+func versions(ms []Migration) []int64 {
+	var vs []int64
+	for _, m := range ms {
+		vs = append(vs, m.Version)
+	}
+	return vs
+}
+
+func TestAddTenantsAssignsExistingRowsToDefaultTenant(t *testing.T) {
+	db := setup(t)
+	ctx := context.Background()
+	migrator, err := New(db, discardLogger, All[:2])
+	assert.NoError(t, err)
+	_, err = migrator.Up(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, db.Create(&booksV1{Name: "Kept", Author: "Author", Publication: "Pub"}).Error)
+	assert.NoError(t, db.Create(&apiKeysV1{Name: "ci", Prefix: "bsk_", KeyHash: "hash"}).Error)
+
+	migrator, err = New(db, discardLogger, All[:3])
+	assert.NoError(t, err)
+	_, err = migrator.Up(ctx)
+	assert.NoError(t, err)
+
+	var tenant tenantsV1
+	assert.NoError(t, db.Where("slug = ?", DefaultTenantSlug).First(&tenant).Error)
+	var book booksV2
+	assert.NoError(t, db.First(&book).Error)
+	assert.Equal(t, tenant.ID, book.TenantID)
+	var apiKey apiKeysV2
+	assert.NoError(t, db.First(&apiKey).Error)
+	assert.Equal(t, tenant.ID, apiKey.TenantID)
+
+	_, err = migrator.Down(ctx, 1)
+	assert.NoError(t, err)
+	assert.False(t, db.Migrator().HasTable(&tenantsV1{}))
+	assert.False(t, db.Migrator().HasColumn(&booksV1{}, "tenant_id"))
+	assert.NoError(t, db.First(&booksV1{}).Error, "rows must survive the rollback")
+}
+
+func TestCreateWebhooksUpDown(t *testing.T) {
+	db := setup(t)
+	ctx := context.Background()
+	migrator, err := New(db, discardLogger, All[:4])
+	assert.NoError(t, err)
+	_, err = migrator.Up(ctx)
+	assert.NoError(t, err)
+	for _, table := range []string{"webhooks", "outbox_events", "webhook_deliveries"} {
+		assert.True(t, db.Migrator().HasTable(table), "missing table %s", table)
+	}
+
+	_, err = migrator.Down(ctx, 1)
+	assert.NoError(t, err)
+	for _, table := range []string{"webhooks", "outbox_events", "webhook_deliveries"} {
+		assert.False(t, db.Migrator().HasTable(table), "table %s should be dropped", table)
+	}
+	assert.True(t, db.Migrator().HasTable(&tenantsV1{}))
+}
+
+func TestCreateBookEventsUpDown(t *testing.T) {
+	db := setup(t)
+	ctx := context.Background()
+	migrator, err := New(db, discardLogger, All)
+	assert.NoError(t, err)
+	_, err = migrator.Up(ctx)
+	assert.NoError(t, err)
+	assert.True(t, db.Migrator().HasTable("book_events"))
+	var tenant tenantsV2
+	assert.NoError(t, db.Where("slug = ?", DefaultTenantSlug).First(&tenant).Error)
+	assert.Equal(t, uint64(0), tenant.EventSeq, "existing tenants start their sequence at zero")
+
+	_, err = migrator.Down(ctx, 1)
+	assert.NoError(t, err)
+	assert.False(t, db.Migrator().HasTable("book_events"))
+	assert.False(t, db.Migrator().HasColumn(&tenantsV2{}, "event_seq"))
+	assert.True(t, db.Migrator().HasTable("webhooks"))
+}
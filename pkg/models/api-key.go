@@ -0,0 +1,76 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const apiKeyPrefix = "bks_"
+
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+type APIKeyStore interface {
+	CreateAPIKey(ctx context.Context, name string) (string, *APIKey, error)
+	AuthenticateAPIKey(ctx context.Context, key string) (*APIKey, error)
+}
+
+// APIKey stores only a hash of the key; the plaintext is shown once, when the
+// key is created.
+type APIKey struct {
+	ID        uint      `gorm:"primarykey" json:"ID"`
+	TenantID  uint      `gorm:"not null;index" json:"tenantID"`
+	CreatedAt time.Time `json:"createdAt"`
+	Name      string    `gorm:"not null" json:"name"`
+	Prefix    string    `gorm:"not null" json:"prefix"`
+	KeyHash   string    `gorm:"not null;uniqueIndex;size:64" json:"-"`
+}
+
+func (db *DBModel) CreateAPIKey(ctx context.Context, name string) (string, *APIKey, error) {
+	if name == "" {
+		return "", nil, ErrMissingFields
+	}
+
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, fmt.Errorf("error generating API key: %w", err)
+	}
+	key := apiKeyPrefix + hex.EncodeToString(secret)
+
+	tenantID, _ := TenantFromContext(ctx)
+	apiKey := &APIKey{TenantID: tenantID, Name: name, Prefix: key[:len(apiKeyPrefix)+8], KeyHash: hashAPIKey(key)}
+	err := db.write(ctx, func(tx *gorm.DB) error {
+		return tx.Create(apiKey).Error
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return key, apiKey, nil
+}
+
+// AuthenticateAPIKey looks the key up across all tenants, as the key is what
+// tells a request's tenant.
+func (db *DBModel) AuthenticateAPIKey(ctx context.Context, key string) (*APIKey, error) {
+	var apiKey APIKey
+	err := db.query(ctx, func(tx *gorm.DB) error {
+		return tx.Where("key_hash = ?", hashAPIKey(key)).First(&apiKey).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+	return &apiKey, nil
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
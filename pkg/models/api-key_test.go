@@ -0,0 +1,57 @@
+package models
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateAndAuthenticateAPIKey(t *testing.T) {
+	mockDB, err := setup()
+	assert.NoError(t, err, "failed to setup test database")
+	db := &DBModel{DB: mockDB}
+
+	defer func() {
+		sqlDB, _ := mockDB.DB()
+		if sqlDB != nil {
+			sqlDB.Close()
+		}
+	}()
+
+	_, _, err = db.CreateAPIKey(testContext(), "")
+	assert.EqualError(t, err, "missing required fields")
+	_, _, err = db.CreateAPIKey(context.Background(), "ops")
+	assert.ErrorIs(t, err, ErrNoTenant)
+
+	key, apiKey, err := db.CreateAPIKey(testContext(), "ops")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, apiKeyPrefix))
+	assert.True(t, strings.HasPrefix(key, apiKey.Prefix))
+	assert.NotContains(t, apiKey.KeyHash, key, "plaintext key must not be stored")
+
+	tests := []struct {
+		name          string
+		key           string
+		expectedError error
+	}{
+		{name: "Valid key", key: key},
+		{name: "Unknown key", key: apiKeyPrefix + "0000", expectedError: ErrInvalidAPIKey},
+		{name: "Empty key", key: "", expectedError: ErrInvalidAPIKey},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Keys are looked up before the tenant is known.
+			authenticated, err := db.AuthenticateAPIKey(context.Background(), tc.key)
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, apiKey.ID, authenticated.ID)
+			assert.Equal(t, "ops", authenticated.Name)
+			assert.Equal(t, defaultTenantID, authenticated.TenantID)
+		})
+	}
+}
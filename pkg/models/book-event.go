@@ -0,0 +1,119 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// BookEventStore reads the change feed of the context's tenant.
+type BookEventStore interface {
+	// GetBookEvents returns up to limit events with a Seq above afterSeq,
+	// oldest first.
+	GetBookEvents(ctx context.Context, afterSeq uint64, limit int) ([]BookEvent, error)
+	// LatestBookEventSeq returns the Seq of the tenant's last event, or zero.
+	LatestBookEventSeq(ctx context.Context) (uint64, error)
+	// PruneBookEvents deletes the events of every tenant created before
+	// before.
+	PruneBookEvents(ctx context.Context, before time.Time) (int64, error)
+}
+
+// BookEvent is one entry in a tenant's change feed. Payload is the book as
+// JSON.
+//
+// Seq numbers a tenant's events from 1 in commit order, without gaps: taking
+// the next number locks the tenant's row until the mutation commits, so a
+// reader that has seen Seq n has seen every earlier event. The price is that
+// book writes within one tenant are serialised.
+type BookEvent struct {
+	ID        uint      `gorm:"primarykey" json:"-"`
+	TenantID  uint      `gorm:"not null" json:"-"`
+	Seq       uint64    `gorm:"not null" json:"seq"`
+	CreatedAt time.Time `json:"createdAt"`
+	Type      string    `gorm:"not null" json:"type"`
+	Payload   []byte    `gorm:"not null" json:"-"`
+}
+
+// changeHooks run, in order, in the transaction of each book mutation after
+// the change is made. An error from one rolls the mutation back.
+var changeHooks = []func(tx *gorm.DB, event *BookEvent) error{
+	appendBookEvent,
+	writeOutbox,
+}
+
+// emit records eventType for b through changeHooks and returns the event.
+func emit(tx *gorm.DB, eventType string, b *Book) (*BookEvent, error) {
+	payload, err := json.Marshal(b)
+	if err != nil {
+		return nil, err
+	}
+	event := &BookEvent{TenantID: b.TenantID, Type: eventType, Payload: payload}
+	for _, hook := range changeHooks {
+		if err := hook(tx, event); err != nil {
+			return nil, err
+		}
+	}
+	return event, nil
+}
+
+// changed hands a committed event to OnBookChange.
+func (db *DBModel) changed(event *BookEvent) {
+	if db.OnBookChange != nil && event != nil {
+		db.OnBookChange(*event)
+	}
+}
+
+// appendBookEvent is a change hook that adds event to its tenant's feed.
+func appendBookEvent(tx *gorm.DB, event *BookEvent) error {
+	tenant := func() *gorm.DB {
+		return unscoped(tx).Table("tenants").Where("id = ?", event.TenantID)
+	}
+	if err := tenant().UpdateColumn("event_seq", gorm.Expr("event_seq + 1")).Error; err != nil {
+		return err
+	}
+	if err := tenant().Select("event_seq").Row().Scan(&event.Seq); err != nil {
+		return err
+	}
+	return tx.Create(event).Error
+}
+
+// GetBookEvents reads the primary: a lagging replica would return nothing
+// for events the feed has been told about, and a stream that moved between
+// replicas could see sequence numbers go backwards.
+func (db *DBModel) GetBookEvents(ctx context.Context, afterSeq uint64, limit int) ([]BookEvent, error) {
+	var events []BookEvent
+	err := db.scopedOn(ctx, db.DB, func(tx *gorm.DB) error {
+		return tx.Where("seq > ?", afterSeq).Order("seq").Limit(limit).Find(&events).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// LatestBookEventSeq reads the tenant's sequence rather than its newest
+// event, which may have been pruned. Like GetBookEvents it reads the
+// primary, so a stream never starts ahead of the events it can read.
+func (db *DBModel) LatestBookEventSeq(ctx context.Context) (uint64, error) {
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return 0, ErrNoTenant
+	}
+	var seq uint64
+	err := db.query(ctx, func(tx *gorm.DB) error {
+		return tx.Table("tenants").Where("id = ?", tenantID).Select("event_seq").Row().Scan(&seq)
+	})
+	return seq, err
+}
+
+func (db *DBModel) PruneBookEvents(ctx context.Context, before time.Time) (int64, error) {
+	var pruned int64
+	err := db.query(ctx, func(tx *gorm.DB) error {
+		result := tx.Where("created_at < ?", before).Delete(&BookEvent{})
+		pruned = result.RowsAffected
+		return result.Error
+	})
+	return pruned, err
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBookEvents(t *testing.T) {
+	db := setupWebhooks(t)
+	other, err := db.CreateTenant(context.Background(), "other", "Other")
+	assert.NoError(t, err)
+	otherCtx := WithTenant(context.Background(), other.ID)
+
+	var committed []BookEvent
+	db.OnBookChange = func(event BookEvent) {
+		committed = append(committed, event)
+	}
+
+	latest, err := db.LatestBookEventSeq(testContext())
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), latest)
+
+	book := &Book{Name: "Book1", Author: "Author1", Publication: "Publication1"}
+	assert.NoError(t, db.CreateBook(testContext(), book))
+	assert.NoError(t, db.CreateBook(otherCtx, &Book{Name: "Other", Author: "Author", Publication: "Publication"}))
+	book.Name = "Book2"
+	assert.NoError(t, db.UpdateBook(testContext(), book))
+	assert.ErrorIs(t, db.UpdateBook(testContext(), &Book{ID: 99, Name: "Book", Author: "Author", Publication: "Publication"}), ErrNotFound)
+	_, err = db.DeleteBook(testContext(), int64(book.ID))
+	assert.NoError(t, err)
+
+	events, err := db.GetBookEvents(testContext(), 0, 10)
+	assert.NoError(t, err)
+	var seqs []uint64
+	var types []string
+	for _, event := range events {
+		seqs = append(seqs, event.Seq)
+		types = append(types, event.Type)
+		var payload Book
+		assert.NoError(t, json.Unmarshal(event.Payload, &payload))
+		assert.Equal(t, book.ID, payload.ID)
+	}
+	assert.Equal(t, []uint64{1, 2, 3}, seqs, "each tenant has its own sequence, unbroken by failed writes")
+	assert.Equal(t, []string{EventBookCreated, EventBookUpdated, EventBookDeleted}, types)
+	assert.Len(t, committed, 4, "OnBookChange sees each committed mutation")
+
+	events, err = db.GetBookEvents(testContext(), 1, 1)
+	assert.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, uint64(2), events[0].Seq)
+	}
+	events, err = db.GetBookEvents(otherCtx, 0, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, uint64(1), events[0].Seq)
+	}
+	_, err = db.GetBookEvents(context.Background(), 0, 10)
+	assert.ErrorIs(t, err, ErrNoTenant)
+
+	pruned, err := db.PruneBookEvents(context.Background(), time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), pruned)
+	latest, err = db.LatestBookEventSeq(testContext())
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), latest, "pruning does not reset the sequence")
+}
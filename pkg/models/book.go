@@ -1,75 +1,357 @@
 package models
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// Error classes returned by BookstoreDB; match them with errors.Is.
+var (
+	ErrNotFound      = errors.New("not found")
+	ErrMissingFields = errors.New("missing required fields")
+)
+
 type BookstoreDB interface {
-	CreateBook(b *Book) error
-	GetAllBooks() ([]Book, error)
-	GetBookById(id int64) (*Book, error)
-	DeleteBook(id int64) (*Book, error)
+	CreateBook(ctx context.Context, b *Book) error
+	GetAllBooks(ctx context.Context) ([]Book, error)
+	GetBookById(ctx context.Context, id int64) (*Book, error)
+	UpdateBook(ctx context.Context, b *Book) error
+	DeleteBook(ctx context.Context, id int64) (*Book, error)
+	CountBooks(ctx context.Context) (int64, error)
+	FindBooks(ctx context.Context, filter BookFilter) ([]Book, int64, error)
+	GetBooksByAuthors(ctx context.Context, authors []string) (map[string][]Book, error)
+	EachBook(ctx context.Context, batchSize int, fn func(batch []Book) error) error
+}
+
+// BookFilter narrows FindBooks. Empty fields match every book; a zero Limit
+// returns all matches.
+type BookFilter struct {
+	NameContains string
+	Author       string
+	Publication  string
+	Limit        int
+	Offset       int
 }
 
 type Book struct {
-	ID          uint      `gorm:"primarykey" json:"ID"`
-	CreatedAt   time.Time `json:"-"`
-	UpdatedAt   time.Time `json:"-"`
-	DeletedAt   time.Time `gorm:"index" json:"-"`
-	Name        string    `gorm:"not null" json:"name"`
-	Author      string    `gorm:"not null" json:"author"`
-	Publication string    `gorm:"not null" json:"publication"`
+	ID          uint      `gorm:"primarykey" json:"ID" xml:"id"`
+	TenantID    uint      `gorm:"not null;index" json:"-" xml:"-"`
+	CreatedAt   time.Time `json:"-" xml:"-"`
+	UpdatedAt   time.Time `json:"-" xml:"-"`
+	DeletedAt   time.Time `gorm:"index" json:"-" xml:"-"`
+	Name        string    `gorm:"not null" json:"name" xml:"name"`
+	Author      string    `gorm:"not null" json:"author" xml:"author"`
+	Publication string    `gorm:"not null" json:"publication" xml:"publication"`
 }
 
 type DBModel struct {
 	DB *gorm.DB
+	// QueryTimeout bounds each query; zero leaves only the caller's context.
+	QueryTimeout time.Duration
+	// Replicas, if set, serve book reads, except those following a write in
+	// a context from TrackWrites.
+	Replicas *ReplicaSet
+	// OnBookChange, if set, is called with the event of each book mutation
+	// once it is committed.
+	OnBookChange func(event BookEvent)
+}
+
+// query runs fn on the primary.
+func (db *DBModel) query(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	return db.queryOn(ctx, db.DB, fn)
+}
+
+// write runs fn on the primary, scoped to ctx's tenant, and sends later reads
+// in ctx there too.
+func (db *DBModel) write(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	markWrite(ctx)
+	return db.scopedOn(ctx, db.DB, fn)
+}
+
+// read runs fn scoped to ctx's tenant, on a replica when one may serve ctx.
+func (db *DBModel) read(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	return db.scopedOn(ctx, db.reader(ctx), fn)
+}
+
+// scopedOn runs fn on a session of conn that only sees the rows of ctx's
+// tenant. Without a tenant nothing runs, so a missing WithTenant fails closed.
+func (db *DBModel) scopedOn(ctx context.Context, conn *gorm.DB, fn func(tx *gorm.DB) error) error {
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return ErrNoTenant
+	}
+	return db.queryOn(ctx, conn, func(tx *gorm.DB) error {
+		return fn(tx.Where("tenant_id = ?", tenantID).Session(&gorm.Session{}))
+	})
+}
+
+func (db *DBModel) reader(ctx context.Context) *gorm.DB {
+	if db.Replicas == nil || wroteIn(ctx) {
+		return db.DB
+	}
+	if replica := db.Replicas.Pick(); replica != nil {
+		return replica
+	}
+	return db.DB
 }
 
-func (db *DBModel) CreateBook(b *Book) error {
+// queryOn runs fn on a session of conn bound to ctx and QueryTimeout. Drivers
+// report interrupted queries in their own words, so if the context ended the
+// error is made to match context.Canceled or context.DeadlineExceeded.
+func (db *DBModel) queryOn(ctx context.Context, conn *gorm.DB, fn func(tx *gorm.DB) error) error {
+	if db.QueryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, db.QueryTimeout)
+		defer cancel()
+	}
+
+	err := fn(conn.WithContext(ctx))
+	if err != nil && ctx.Err() != nil && !errors.Is(err, ctx.Err()) {
+		return fmt.Errorf("%w: %v", ctx.Err(), err)
+	}
+	return err
+}
+
+func (db *DBModel) CreateBook(ctx context.Context, b *Book) error {
 	if b.Author == "" || b.Name == "" || b.Publication == "" {
-		return errors.New("missing required fields")
+		return ErrMissingFields
 	}
-	if result := db.DB.Create(b); result.Error != nil {
-		return result.Error
+	b.TenantID, _ = TenantFromContext(ctx)
+	var event *BookEvent
+	err := db.write(ctx, func(tx *gorm.DB) error {
+		return tx.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(b).Error; err != nil {
+				return err
+			}
+			var err error
+			event, err = emit(tx, EventBookCreated, b)
+			return err
+		})
+	})
+	if err != nil {
+		return err
 	}
+	db.changed(event)
 	return nil
 }
 
-func (db *DBModel) GetAllBooks() ([]Book, error) {
+func (db *DBModel) GetAllBooks(ctx context.Context) ([]Book, error) {
 	var books []Book
-	if result := db.DB.Find(&books); result.Error != nil {
-		return nil, result.Error
+	err := db.read(ctx, func(tx *gorm.DB) error {
+		return tx.Find(&books).Error
+	})
+	if err != nil {
+		return nil, err
 	}
 	return books, nil
 }
 
-func (db *DBModel) GetBookById(id int64) (*Book, error) {
+func (db *DBModel) GetBookById(ctx context.Context, id int64) (*Book, error) {
 	var book Book
-
-	if result := db.DB.First(&book, id); result.Error != nil {
-		if result.Error == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("book with ID %d not found", id)
+	err := db.read(ctx, func(tx *gorm.DB) error {
+		return tx.First(&book, id).Error
+	})
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("book with ID %d %w", id, ErrNotFound)
 		}
-		return nil, result.Error
+		return nil, err
 	}
 	return &book, nil
 }
 
-func (db *DBModel) DeleteBook(id int64) (*Book, error) {
+// UpdateBook sets the non-empty Name, Author and Publication of b on book
+// b.ID and fills b with the result. The book is changed and read back in one
+// transaction on the primary, so fields left empty keep their committed
+// values however stale the caller's copy of the book is.
+func (db *DBModel) UpdateBook(ctx context.Context, b *Book) error {
+	changes := map[string]any{}
+	if b.Name != "" {
+		changes["name"] = b.Name
+	}
+	if b.Author != "" {
+		changes["author"] = b.Author
+	}
+	if b.Publication != "" {
+		changes["publication"] = b.Publication
+	}
+	if len(changes) == 0 {
+		return ErrMissingFields
+	}
+
 	var book Book
-	if result := db.DB.First(&book, id); result.Error != nil {
-		if result.Error == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("book with ID %d not found", id)
-		}
-		return nil, result.Error
+	var event *BookEvent
+	err := db.write(ctx, func(tx *gorm.DB) error {
+		return tx.Transaction(func(tx *gorm.DB) error {
+			// The update locks the row, so the read after it sees the book
+			// as this transaction leaves it.
+			if err := tx.Model(&Book{ID: b.ID}).Updates(changes).Error; err != nil {
+				return err
+			}
+			if err := tx.First(&book, b.ID).Error; err != nil {
+				return err
+			}
+			var err error
+			event, err = emit(tx, EventBookUpdated, &book)
+			return err
+		})
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("book with ID %d %w", b.ID, ErrNotFound)
+	}
+	if err != nil {
+		return err
 	}
+	*b = book
+	db.changed(event)
+	return nil
+}
 
-	if result := db.DB.Delete(&book); result.Error != nil {
-		return nil, result.Error
+func (db *DBModel) DeleteBook(ctx context.Context, id int64) (*Book, error) {
+	var book Book
+	var event *BookEvent
+	err := db.write(ctx, func(tx *gorm.DB) error {
+		return tx.Transaction(func(tx *gorm.DB) error {
+			if err := tx.First(&book, id).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&book).Error; err != nil {
+				return err
+			}
+			var err error
+			event, err = emit(tx, EventBookDeleted, &book)
+			return err
+		})
+	})
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("book with ID %d %w", id, ErrNotFound)
+		}
+		return nil, err
 	}
+	db.changed(event)
 	return &book, nil
 }
+
+func (db *DBModel) CountBooks(ctx context.Context) (int64, error) {
+	var count int64
+	err := db.read(ctx, func(tx *gorm.DB) error {
+		return tx.Model(&Book{}).Count(&count).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// CountBooksAllTenants counts the books of every tenant, for metrics. It is
+// the one book query that is not scoped to a tenant.
+func (db *DBModel) CountBooksAllTenants(ctx context.Context) (int64, error) {
+	var count int64
+	err := db.query(ctx, func(tx *gorm.DB) error {
+		return tx.Model(&Book{}).Count(&count).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// FindBooks returns one page of the books matching filter, ordered by ID,
+// and the total number of matches.
+func (db *DBModel) FindBooks(ctx context.Context, filter BookFilter) ([]Book, int64, error) {
+	var books []Book
+	var total int64
+	err := db.read(ctx, func(tx *gorm.DB) error {
+		query := tx.Model(&Book{})
+		if filter.NameContains != "" {
+			query = query.Where("name LIKE ? ESCAPE '!'", "%"+escapeLike(filter.NameContains)+"%")
+		}
+		if filter.Author != "" {
+			query = query.Where("author = ?", filter.Author)
+		}
+		if filter.Publication != "" {
+			query = query.Where("publication = ?", filter.Publication)
+		}
+
+		if err := query.Count(&total).Error; err != nil {
+			return err
+		}
+
+		page := query.Order("id")
+		if filter.Limit > 0 {
+			page = page.Limit(filter.Limit)
+		}
+		if filter.Offset > 0 {
+			page = page.Offset(filter.Offset)
+		}
+		return page.Find(&books).Error
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return books, total, nil
+}
+
+// GetBooksByAuthors loads the books of several authors in one query.
+func (db *DBModel) GetBooksByAuthors(ctx context.Context, authors []string) (map[string][]Book, error) {
+	byAuthor := make(map[string][]Book, len(authors))
+	if len(authors) == 0 {
+		return byAuthor, nil
+	}
+
+	var books []Book
+	err := db.read(ctx, func(tx *gorm.DB) error {
+		return tx.Where("author IN ?", authors).Order("id").Find(&books).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range books {
+		byAuthor[b.Author] = append(byAuthor[b.Author], b)
+	}
+	return byAuthor, nil
+}
+
+// EachBook calls fn with every book, batchSize books at a time in ID order,
+// so callers can walk the catalogue without loading all of it. fn must not
+// keep batch, which is reused. An error from fn stops the walk.
+//
+// Each batch is a separate keyset query with its own QueryTimeout, so a long
+// walk is bounded by ctx rather than by the per-query deadline. All batches
+// come from the same replica.
+func (db *DBModel) EachBook(ctx context.Context, batchSize int, fn func(batch []Book) error) error {
+	conn := db.reader(ctx)
+	var batch []Book
+	var lastID uint
+	for {
+		batch = batch[:0]
+		err := db.scopedOn(ctx, conn, func(tx *gorm.DB) error {
+			return tx.Where("id > ?", lastID).Order("id").Limit(batchSize).Find(&batch).Error
+		})
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+		if len(batch) < batchSize {
+			return nil
+		}
+		lastID = batch[len(batch)-1].ID
+	}
+}
+
+// escapeLike escapes LIKE wildcards with '!', which unlike backslash means
+// the same in MySQL and SQLite string literals.
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
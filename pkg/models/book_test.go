@@ -1,9 +1,15 @@
 package models
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"testing"
+	"time"
 
+	"github.com/mg4603/go-bookstore-management-system/pkg/migrations"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -15,12 +21,23 @@ func setup() (*gorm.DB, error) {
 		return nil, err
 	}
 
-	if err = db.AutoMigrate(&Book{}); err != nil {
+	migrator, err := migrations.New(db, slog.New(slog.NewTextHandler(io.Discard, nil)), migrations.All)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
 		return nil, err
 	}
 	return db, nil
 }
 
+// defaultTenantID is the tenant the migrations create.
+const defaultTenantID uint = 1
+
+func testContext() context.Context {
+	return WithTenant(context.Background(), defaultTenantID)
+}
+
 func TestCreateBook(t *testing.T) {
 
 	mockDB, err := setup()
@@ -59,7 +76,7 @@ func TestCreateBook(t *testing.T) {
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			err := db.CreateBook(tc.book)
+			err := db.CreateBook(testContext(), tc.book)
 			if tc.expectedError != "" {
 				assert.Error(t, err, tc.expectedError)
 			} else {
@@ -120,11 +137,11 @@ func TestGetAllBooks(t *testing.T) {
 			mockDB.Exec("DELETE FROM books")
 
 			for _, book := range tc.setupBooks {
-				err := db.CreateBook(&book)
+				err := db.CreateBook(testContext(), &book)
 				assert.NoError(t, err, "failed to insert setup data: %w", err)
 			}
 
-			books, err := db.GetAllBooks()
+			books, err := db.GetAllBooks(testContext())
 			assert.NoError(t, err, "error retrieving books: %w", err)
 			assert.Equal(t, len(books), tc.expectedLength, "incorrect nmber of books returned")
 
@@ -155,7 +172,7 @@ func TestGetBookById(t *testing.T) {
 	}
 
 	for _, book := range seedBooks {
-		err := db.CreateBook(&book)
+		err := db.CreateBook(testContext(), &book)
 		assert.NoError(t, err, "failed to seed database")
 	}
 
@@ -187,7 +204,7 @@ func TestGetBookById(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			book, err := db.GetBookById(tc.bookID)
+			book, err := db.GetBookById(testContext(), tc.bookID)
 
 			if tc.expectedError != nil {
 				assert.Error(t, err, "expected error got none")
@@ -226,7 +243,7 @@ func TestDeleteBook(t *testing.T) {
 	}
 
 	for _, book := range seedBooks {
-		err := db.CreateBook(&book)
+		err := db.CreateBook(testContext(), &book)
 		assert.NoError(t, err, "failed to seed database")
 	}
 
@@ -258,7 +275,7 @@ func TestDeleteBook(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			deletedBook, err := db.DeleteBook(tc.bookID)
+			deletedBook, err := db.DeleteBook(testContext(), tc.bookID)
 
 			if tc.expectedError != nil {
 				assert.Error(t, err, "expected error but got none")
@@ -278,3 +295,284 @@ func TestDeleteBook(t *testing.T) {
 		})
 	}
 }
+
+func TestCountBooks(t *testing.T) {
+	mockDB, err := setup()
+	assert.NoError(t, err, "failed to setup test database")
+	db := &DBModel{DB: mockDB}
+
+	defer func() {
+		sqlDB, _ := mockDB.DB()
+		if sqlDB != nil {
+			sqlDB.Close()
+		}
+	}()
+
+	count, err := db.CountBooks(testContext())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	seedBooks := []Book{
+		{Name: "Name 1", Author: "Author 1", Publication: "Publication 1"},
+		{Name: "Name 2", Author: "Author 2", Publication: "Publication 2"},
+	}
+	for _, book := range seedBooks {
+		err := db.CreateBook(testContext(), &book)
+		assert.NoError(t, err, "failed to seed database")
+	}
+
+	count, err = db.CountBooks(testContext())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestUpdateBook(t *testing.T) {
+	mockDB, err := setup()
+	assert.NoError(t, err, "failed to setup test database")
+	db := &DBModel{DB: mockDB}
+
+	defer func() {
+		sqlDB, _ := mockDB.DB()
+		if sqlDB != nil {
+			sqlDB.Close()
+		}
+	}()
+
+	book := &Book{Name: "Name 1", Author: "Author 1", Publication: "Publication 1"}
+	assert.NoError(t, db.CreateBook(testContext(), book), "failed to seed database")
+
+	tests := []struct {
+		name          string
+		update        Book
+		expected      Book
+		expectedError string
+	}{
+		{
+			name:     "Valid update",
+			update:   Book{ID: book.ID, Name: "Name 2", Author: "Author 2", Publication: "Publication 2"},
+			expected: Book{Name: "Name 2", Author: "Author 2", Publication: "Publication 2"},
+		},
+		{
+			name:     "Partial update",
+			update:   Book{ID: book.ID, Name: "Name 3", Author: "Author 3"},
+			expected: Book{Name: "Name 3", Author: "Author 3", Publication: "Publication 2"},
+		},
+		{
+			name:          "No fields",
+			update:        Book{ID: book.ID},
+			expectedError: "missing required fields",
+		},
+		{
+			name:          "Not found",
+			update:        Book{ID: 99, Name: "Name 4"},
+			expectedError: "book with ID 99 not found",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := db.UpdateBook(testContext(), &tc.update)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected.Publication, tc.update.Publication, "the updated book is filled in")
+
+			stored, err := db.GetBookById(testContext(), int64(book.ID))
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected.Name, stored.Name)
+			assert.Equal(t, tc.expected.Author, stored.Author)
+			assert.Equal(t, tc.expected.Publication, stored.Publication)
+		})
+	}
+}
+
+func TestErrorClasses(t *testing.T) {
+	mockDB, err := setup()
+	assert.NoError(t, err, "failed to setup test database")
+	db := &DBModel{DB: mockDB}
+
+	defer func() {
+		sqlDB, _ := mockDB.DB()
+		if sqlDB != nil {
+			sqlDB.Close()
+		}
+	}()
+
+	_, err = db.GetBookById(testContext(), 7)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.EqualError(t, err, "book with ID 7 not found")
+
+	_, err = db.DeleteBook(testContext(), 7)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.ErrorIs(t, db.CreateBook(testContext(), &Book{Name: "Name 1"}), ErrMissingFields)
+	assert.ErrorIs(t, db.UpdateBook(testContext(), &Book{ID: 1}), ErrMissingFields)
+}
+
+func TestFindBooks(t *testing.T) {
+	mockDB, err := setup()
+	assert.NoError(t, err, "failed to setup test database")
+	db := &DBModel{DB: mockDB}
+
+	defer func() {
+		sqlDB, _ := mockDB.DB()
+		if sqlDB != nil {
+			sqlDB.Close()
+		}
+	}()
+
+	seedBooks := []Book{
+		{Name: "Go in Action", Author: "Author 1", Publication: "Publication 1"},
+		{Name: "100% Go", Author: "Author 2", Publication: "Publication 1"},
+		{Name: "Learning Go", Author: "Author 1", Publication: "Publication 2"},
+		{Name: "Rust", Author: "Author 3", Publication: "Publication 2"},
+	}
+	for _, book := range seedBooks {
+		err := db.CreateBook(testContext(), &book)
+		assert.NoError(t, err, "failed to seed database")
+	}
+
+	tests := []struct {
+		name          string
+		filter        BookFilter
+		expectedNames []string
+		expectedTotal int64
+	}{
+		{name: "No filter", filter: BookFilter{}, expectedNames: []string{"Go in Action", "100% Go", "Learning Go", "Rust"}, expectedTotal: 4},
+		{name: "Name contains", filter: BookFilter{NameContains: "Go"}, expectedNames: []string{"Go in Action", "100% Go", "Learning Go"}, expectedTotal: 3},
+		{name: "Wildcards are literal", filter: BookFilter{NameContains: "%"}, expectedNames: []string{"100% Go"}, expectedTotal: 1},
+		{name: "Author and publication", filter: BookFilter{Author: "Author 1", Publication: "Publication 2"}, expectedNames: []string{"Learning Go"}, expectedTotal: 1},
+		{name: "Paged", filter: BookFilter{Limit: 2, Offset: 1}, expectedNames: []string{"100% Go", "Learning Go"}, expectedTotal: 4},
+		{name: "Past the end", filter: BookFilter{Limit: 2, Offset: 10}, expectedNames: nil, expectedTotal: 4},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			books, total, err := db.FindBooks(testContext(), tc.filter)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedTotal, total)
+
+			var names []string
+			for _, b := range books {
+				names = append(names, b.Name)
+			}
+			assert.Equal(t, tc.expectedNames, names)
+		})
+	}
+}
+
+func TestGetBooksByAuthors(t *testing.T) {
+	mockDB, err := setup()
+	assert.NoError(t, err, "failed to setup test database")
+	db := &DBModel{DB: mockDB}
+
+	defer func() {
+		sqlDB, _ := mockDB.DB()
+		if sqlDB != nil {
+			sqlDB.Close()
+		}
+	}()
+
+	for _, book := range []Book{
+		{Name: "Name 1", Author: "Author 1", Publication: "Publication 1"},
+		{Name: "Name 2", Author: "Author 2", Publication: "Publication 1"},
+		{Name: "Name 3", Author: "Author 1", Publication: "Publication 1"},
+	} {
+		err := db.CreateBook(testContext(), &book)
+		assert.NoError(t, err, "failed to seed database")
+	}
+
+	byAuthor, err := db.GetBooksByAuthors(testContext(), []string{"Author 1", "Author 9"})
+	assert.NoError(t, err)
+	assert.Len(t, byAuthor, 1)
+	assert.Len(t, byAuthor["Author 1"], 2)
+	assert.Equal(t, "Name 3", byAuthor["Author 1"][1].Name)
+
+	byAuthor, err = db.GetBooksByAuthors(testContext(), nil)
+	assert.NoError(t, err)
+	assert.Empty(t, byAuthor)
+}
+
+func TestEachBook(t *testing.T) {
+	mockDB, err := setup()
+	assert.NoError(t, err, "failed to setup test database")
+	db := &DBModel{DB: mockDB}
+
+	defer func() {
+		sqlDB, _ := mockDB.DB()
+		if sqlDB != nil {
+			sqlDB.Close()
+		}
+	}()
+
+	for i := 1; i <= 5; i++ {
+		err := db.CreateBook(testContext(), &Book{Name: fmt.Sprintf("Name %d", i), Author: "Author", Publication: "Publication"})
+		assert.NoError(t, err, "failed to seed database")
+	}
+
+	var batches [][]string
+	err = db.EachBook(testContext(), 2, func(batch []Book) error {
+		var names []string
+		for _, b := range batch {
+			names = append(names, b.Name)
+		}
+		batches = append(batches, names)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"Name 1", "Name 2"}, {"Name 3", "Name 4"}, {"Name 5"}}, batches)
+
+	stop := errors.New("stop")
+	calls := 0
+	err = db.EachBook(testContext(), 2, func([]Book) error {
+		calls++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, calls)
+}
+
+func TestQueryContext(t *testing.T) {
+	mockDB, err := setup()
+	assert.NoError(t, err, "failed to setup test database")
+
+	defer func() {
+		sqlDB, _ := mockDB.DB()
+		if sqlDB != nil {
+			sqlDB.Close()
+		}
+	}()
+
+	cancelled, cancel := context.WithCancel(testContext())
+	cancel()
+
+	tests := []struct {
+		name          string
+		db            *DBModel
+		ctx           context.Context
+		expectedError error
+	}{
+		{name: "No deadline", db: &DBModel{DB: mockDB}, ctx: testContext()},
+		{name: "Within the query timeout", db: &DBModel{DB: mockDB, QueryTimeout: time.Minute}, ctx: testContext()},
+		{name: "Query timeout passed", db: &DBModel{DB: mockDB, QueryTimeout: time.Nanosecond}, ctx: testContext(), expectedError: context.DeadlineExceeded},
+		{name: "Caller cancelled", db: &DBModel{DB: mockDB, QueryTimeout: time.Minute}, ctx: cancelled, expectedError: context.Canceled},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.db.GetAllBooks(tc.ctx)
+			if tc.expectedError == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tc.expectedError)
+
+			_, err = tc.db.GetBookById(tc.ctx, 1)
+			assert.ErrorIs(t, err, tc.expectedError)
+			assert.NotErrorIs(t, err, ErrNotFound)
+
+			err = tc.db.EachBook(tc.ctx, 10, func([]Book) error { return nil })
+			assert.ErrorIs(t, err, tc.expectedError)
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ReplicaSet spreads reads over the healthy replicas in turn. Replicas start
+// healthy; Watch takes failing ones out of rotation until they recover.
+type ReplicaSet struct {
+	replicas []*replica
+	next     atomic.Uint64
+}
+
+type replica struct {
+	name    string
+	db      *gorm.DB
+	healthy atomic.Bool
+}
+
+// NewReplicaSet names each replica for logs by its position in dbs.
+func NewReplicaSet(dbs []*gorm.DB) *ReplicaSet {
+	s := &ReplicaSet{}
+	for i, db := range dbs {
+		r := &replica{name: "replica-" + strconv.Itoa(i), db: db}
+		r.healthy.Store(true)
+		s.replicas = append(s.replicas, r)
+	}
+	return s
+}
+
+// Pick returns the next healthy replica, or nil if there is none.
+func (s *ReplicaSet) Pick() *gorm.DB {
+	n := uint64(len(s.replicas))
+	for i := uint64(0); i < n; i++ {
+		r := s.replicas[(s.next.Add(1)-1)%n]
+		if r.healthy.Load() {
+			return r.db
+		}
+	}
+	return nil
+}
+
+// Check pings every replica, each bounded by timeout, and updates its health.
+func (s *ReplicaSet) Check(ctx context.Context, timeout time.Duration, logger *slog.Logger) {
+	for _, r := range s.replicas {
+		err := ping(ctx, r.db, timeout)
+		if healthy := err == nil; r.healthy.Swap(healthy) != healthy {
+			if healthy {
+				logger.Info("database replica recovered", "replica", r.name)
+			} else {
+				logger.Warn("database replica unhealthy; reading from the others", "replica", r.name, "error", err)
+			}
+		}
+	}
+}
+
+// Watch runs Check now and then every interval until ctx is done.
+func (s *ReplicaSet) Watch(ctx context.Context, interval, timeout time.Duration, logger *slog.Logger) {
+	s.Check(ctx, timeout, logger)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Check(ctx, timeout, logger)
+		}
+	}
+}
+
+func ping(ctx context.Context, db *gorm.DB, timeout time.Duration) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return sqlDB.PingContext(ctx)
+}
+
+type writeTrackerKey struct{}
+
+// TrackWrites returns a context in which reads that follow a write go to the
+// primary, so a request sees its own writes despite replication lag. Reads
+// that feed a write need no tracking: UpdateBook reads the book it changes on
+// the primary, in its own transaction.
+func TrackWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, writeTrackerKey{}, new(atomic.Bool))
+}
+
+// ReadYourWrites applies TrackWrites to each request.
+func ReadYourWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(TrackWrites(r.Context())))
+	})
+}
+
+func markWrite(ctx context.Context) {
+	if wrote, ok := ctx.Value(writeTrackerKey{}).(*atomic.Bool); ok {
+		wrote.Store(true)
+	}
+}
+
+func wroteIn(ctx context.Context) bool {
+	wrote, ok := ctx.Value(writeTrackerKey{}).(*atomic.Bool)
+	return ok && wrote.Load()
+}
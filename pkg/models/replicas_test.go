@@ -0,0 +1,118 @@
+package models
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// setupWithBook returns a database whose only book is named name.
+func setupWithBook(t *testing.T, name string) *gorm.DB {
+	t.Helper()
+	db, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, _ := db.DB(); sqlDB != nil {
+			sqlDB.Close()
+		}
+	})
+	if err := db.Create(&Book{TenantID: defaultTenantID, Name: name, Author: "Author", Publication: "Publication"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestReplicaSetPick(t *testing.T) {
+	a, b, c := setupWithBook(t, "a"), setupWithBook(t, "b"), setupWithBook(t, "c")
+	replicas := NewReplicaSet([]*gorm.DB{a, b, c})
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	assert.Equal(t, []*gorm.DB{a, b, c, a}, []*gorm.DB{replicas.Pick(), replicas.Pick(), replicas.Pick(), replicas.Pick()})
+
+	sqlDB, _ := b.DB()
+	sqlDB.Close()
+	replicas.Check(context.Background(), time.Second, logger)
+	assert.Equal(t, []*gorm.DB{c, a, c}, []*gorm.DB{replicas.Pick(), replicas.Pick(), replicas.Pick()})
+
+	for _, db := range []*gorm.DB{a, c} {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	}
+	replicas.Check(context.Background(), time.Second, logger)
+	assert.Nil(t, replicas.Pick())
+}
+
+func TestDBModelReadRouting(t *testing.T) {
+	primary := setupWithBook(t, "on primary")
+	replica := setupWithBook(t, "on replica")
+	db := &DBModel{DB: primary, Replicas: NewReplicaSet([]*gorm.DB{replica})}
+
+	nameOf := func(ctx context.Context) string {
+		book, err := db.GetBookById(ctx, 1)
+		if !assert.NoError(t, err) {
+			return ""
+		}
+		return book.Name
+	}
+
+	t.Run("Reads use the replica", func(t *testing.T) {
+		assert.Equal(t, "on replica", nameOf(testContext()))
+
+		books, err := db.GetAllBooks(testContext())
+		assert.NoError(t, err)
+		assert.Equal(t, "on replica", books[0].Name)
+
+		found, _, err := db.FindBooks(testContext(), BookFilter{})
+		assert.NoError(t, err)
+		assert.Equal(t, "on replica", found[0].Name)
+
+		err = db.EachBook(testContext(), 10, func(batch []Book) error {
+			assert.Equal(t, "on replica", batch[0].Name)
+			return nil
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Reads follow writes in a tracked context", func(t *testing.T) {
+		ctx := TrackWrites(testContext())
+		assert.Equal(t, "on replica", nameOf(ctx))
+
+		assert.NoError(t, db.UpdateBook(ctx, &Book{ID: 1, Name: "updated", Author: "Author", Publication: "Publication"}))
+		assert.Equal(t, "updated", nameOf(ctx))
+		assert.Equal(t, "on replica", nameOf(testContext()))
+	})
+
+	t.Run("Updates do not read from a lagging replica", func(t *testing.T) {
+		book := &Book{ID: 1, Publication: "Publication 2"}
+		assert.NoError(t, db.UpdateBook(testContext(), book))
+		assert.Equal(t, "updated", book.Name, "fields left empty keep the primary's values")
+	})
+
+	t.Run("Middleware tracks each request", func(t *testing.T) {
+		var names []string
+		handler := ReadYourWrites(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			names = append(names, nameOf(r.Context()))
+			assert.NoError(t, db.CreateBook(r.Context(), &Book{Name: "new", Author: "Author", Publication: "Publication"}))
+			names = append(names, nameOf(r.Context()))
+		}))
+
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/books/", nil).WithContext(testContext()))
+		assert.Equal(t, []string{"on replica", "updated"}, names)
+	})
+
+	t.Run("Primary serves reads when no replica is healthy", func(t *testing.T) {
+		sqlDB, _ := replica.DB()
+		sqlDB.Close()
+		db.Replicas.Check(context.Background(), time.Second, slog.New(slog.NewTextHandler(io.Discard, nil)))
+		assert.Equal(t, "updated", nameOf(testContext()))
+	})
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrNoTenant is returned by tenant-scoped queries whose context names no
+// tenant; see WithTenant.
+var ErrNoTenant = errors.New("no tenant")
+
+// ErrInvalidSlug is returned when creating a tenant whose slug is not a
+// lower-case DNS label, as it may be used as a subdomain.
+var ErrInvalidSlug = errors.New("tenant slug must be a lower-case DNS label")
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+type TenantStore interface {
+	CreateTenant(ctx context.Context, slug, name string) (*Tenant, error)
+	GetTenantBySlug(ctx context.Context, slug string) (*Tenant, error)
+	GetAllTenants(ctx context.Context) ([]Tenant, error)
+}
+
+// Tenant is one store. Books and API keys belong to exactly one tenant.
+type Tenant struct {
+	ID        uint      `gorm:"primarykey" json:"ID"`
+	CreatedAt time.Time `json:"createdAt"`
+	Slug      string    `gorm:"not null;uniqueIndex;size:63" json:"slug"`
+	Name      string    `gorm:"not null" json:"name"`
+}
+
+type tenantKey struct{}
+
+// WithTenant scopes the BookstoreDB and API key queries run with the returned
+// context to tenantID.
+func WithTenant(ctx context.Context, tenantID uint) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+func TenantFromContext(ctx context.Context) (uint, bool) {
+	tenantID, ok := ctx.Value(tenantKey{}).(uint)
+	return tenantID, ok
+}
+
+func (db *DBModel) CreateTenant(ctx context.Context, slug, name string) (*Tenant, error) {
+	if slug == "" || name == "" {
+		return nil, ErrMissingFields
+	}
+	if !slugPattern.MatchString(slug) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidSlug, slug)
+	}
+
+	tenant := &Tenant{Slug: slug, Name: name}
+	err := db.query(ctx, func(tx *gorm.DB) error {
+		return tx.Create(tenant).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tenant, nil
+}
+
+func (db *DBModel) GetTenantBySlug(ctx context.Context, slug string) (*Tenant, error) {
+	var tenant Tenant
+	err := db.query(ctx, func(tx *gorm.DB) error {
+		return tx.Where("slug = ?", slug).First(&tenant).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("tenant %q %w", slug, ErrNotFound)
+		}
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+func (db *DBModel) GetAllTenants(ctx context.Context) ([]Tenant, error) {
+	var tenants []Tenant
+	err := db.query(ctx, func(tx *gorm.DB) error {
+		return tx.Order("id").Find(&tenants).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tenants, nil
+}
@@ -0,0 +1,169 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateTenant(t *testing.T) {
+	mockDB, err := setup()
+	assert.NoError(t, err, "failed to setup test database")
+	db := &DBModel{DB: mockDB}
+	defer func() {
+		sqlDB, _ := mockDB.DB()
+		if sqlDB != nil {
+			sqlDB.Close()
+		}
+	}()
+
+	tests := []struct {
+		name          string
+		slug          string
+		tenantName    string
+		expectedError error
+	}{
+		{name: "Valid tenant", slug: "acme", tenantName: "Acme"},
+		{name: "Missing name", slug: "globex", expectedError: ErrMissingFields},
+		{name: "Upper case slug", slug: "Globex", tenantName: "Globex", expectedError: ErrInvalidSlug},
+		{name: "Slug with a dot", slug: "globex.eu", tenantName: "Globex", expectedError: ErrInvalidSlug},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tenant, err := db.CreateTenant(context.Background(), tc.slug, tc.tenantName)
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+
+			found, err := db.GetTenantBySlug(context.Background(), tc.slug)
+			assert.NoError(t, err)
+			assert.Equal(t, tenant.ID, found.ID)
+		})
+	}
+
+	_, err = db.CreateTenant(context.Background(), "acme", "Other Acme")
+	assert.Error(t, err, "slugs must be unique")
+	_, err = db.GetTenantBySlug(context.Background(), "initech")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	tenants, err := db.GetAllTenants(context.Background())
+	assert.NoError(t, err)
+	var slugs []string
+	for _, tenant := range tenants {
+		slugs = append(slugs, tenant.Slug)
+	}
+	assert.Equal(t, []string{"default", "acme"}, slugs)
+}
+
+func TestTenantIsolation(t *testing.T) {
+	mockDB, err := setup()
+	assert.NoError(t, err, "failed to setup test database")
+	db := &DBModel{DB: mockDB}
+	defer func() {
+		sqlDB, _ := mockDB.DB()
+		if sqlDB != nil {
+			sqlDB.Close()
+		}
+	}()
+
+	other, err := db.CreateTenant(context.Background(), "other", "Other")
+	assert.NoError(t, err)
+	mine, theirs := testContext(), WithTenant(context.Background(), other.ID)
+
+	myBook := &Book{Name: "Mine", Author: "Author", Publication: "Publication"}
+	assert.NoError(t, db.CreateBook(mine, myBook))
+	theirBook := &Book{Name: "Theirs", Author: "Author", Publication: "Publication"}
+	assert.NoError(t, db.CreateBook(theirs, theirBook))
+	theirID := int64(theirBook.ID)
+
+	t.Run("Reads only see the tenant's books", func(t *testing.T) {
+		books, err := db.GetAllBooks(mine)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Mine"}, names(books))
+
+		_, err = db.GetBookById(mine, theirID)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		count, err := db.CountBooks(mine)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+
+		found, total, err := db.FindBooks(mine, BookFilter{Author: "Author"})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, []string{"Mine"}, names(found))
+
+		byAuthor, err := db.GetBooksByAuthors(mine, []string{"Author"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Mine"}, names(byAuthor["Author"]))
+
+		var walked []string
+		assert.NoError(t, db.EachBook(mine, 10, func(batch []Book) error {
+			walked = append(walked, names(batch)...)
+			return nil
+		}))
+		assert.Equal(t, []string{"Mine"}, walked)
+
+		all, err := db.CountBooksAllTenants(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), all)
+	})
+
+	t.Run("Writes cannot reach another tenant's books", func(t *testing.T) {
+		err := db.UpdateBook(mine, &Book{ID: theirBook.ID, Name: "Hijacked", Author: "Author", Publication: "Publication"})
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		_, err = db.DeleteBook(mine, theirID)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		stored, err := db.GetBookById(theirs, theirID)
+		assert.NoError(t, err)
+		assert.Equal(t, "Theirs", stored.Name)
+		assert.Equal(t, other.ID, stored.TenantID)
+
+		count, err := db.CountBooksAllTenants(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), count, "a rejected update must not insert a copy")
+	})
+
+	t.Run("A book keeps its tenant on update", func(t *testing.T) {
+		myBook.TenantID = other.ID
+		myBook.Name = "Renamed"
+		assert.NoError(t, db.UpdateBook(mine, myBook))
+
+		stored, err := db.GetBookById(mine, int64(myBook.ID))
+		assert.NoError(t, err)
+		assert.Equal(t, "Renamed", stored.Name)
+		assert.Equal(t, defaultTenantID, stored.TenantID)
+	})
+
+	t.Run("Queries without a tenant fail", func(t *testing.T) {
+		ctx := context.Background()
+		assert.ErrorIs(t, db.CreateBook(ctx, &Book{Name: "Name", Author: "Author", Publication: "Publication"}), ErrNoTenant)
+		_, err := db.GetAllBooks(ctx)
+		assert.ErrorIs(t, err, ErrNoTenant)
+		_, err = db.GetBookById(ctx, theirID)
+		assert.ErrorIs(t, err, ErrNoTenant)
+		assert.ErrorIs(t, db.UpdateBook(ctx, theirBook), ErrNoTenant)
+		_, err = db.DeleteBook(ctx, theirID)
+		assert.ErrorIs(t, err, ErrNoTenant)
+		_, err = db.CountBooks(ctx)
+		assert.ErrorIs(t, err, ErrNoTenant)
+		_, _, err = db.FindBooks(ctx, BookFilter{})
+		assert.ErrorIs(t, err, ErrNoTenant)
+		_, err = db.GetBooksByAuthors(ctx, []string{"Author"})
+		assert.ErrorIs(t, err, ErrNoTenant)
+		assert.ErrorIs(t, db.EachBook(ctx, 10, func([]Book) error { return nil }), ErrNoTenant)
+	})
+}
+
+func names(books []Book) []string {
+	var names []string
+	for _, b := range books {
+		names = append(names, b.Name)
+	}
+	return names
+}
@@ -0,0 +1,413 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Catalogue change events, recorded in the transaction that makes the change.
+const (
+	EventBookCreated = "book.created"
+	EventBookUpdated = "book.updated"
+	EventBookDeleted = "book.deleted"
+)
+
+// EventTypes lists the events a webhook may subscribe to.
+var EventTypes = []string{EventBookCreated, EventBookUpdated, EventBookDeleted}
+
+const webhookSecretPrefix = "whsec_"
+
+// Delivery statuses.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// ErrInvalidWebhook is returned for a webhook with a bad URL or event type.
+var ErrInvalidWebhook = errors.New("invalid webhook")
+
+// WebhookStore manages the webhooks of the context's tenant.
+type WebhookStore interface {
+	CreateWebhook(ctx context.Context, w *Webhook) error
+	GetWebhooks(ctx context.Context) ([]Webhook, error)
+	GetWebhookById(ctx context.Context, id int64) (*Webhook, error)
+	UpdateWebhook(ctx context.Context, id int64, u WebhookUpdate) (*Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64) (*Webhook, error)
+	GetWebhookDeliveries(ctx context.Context, webhookID int64, limit int) ([]WebhookDelivery, error)
+}
+
+// WebhookOutbox is what the dispatcher uses to turn outbox events into
+// deliveries and attempt them. It works across all tenants.
+type WebhookOutbox interface {
+	DispatchEvents(ctx context.Context, now time.Time, limit int) (int, error)
+	ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]PendingDelivery, error)
+	RecordDelivery(ctx context.Context, d *WebhookDelivery) error
+	PruneDeliveries(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Webhook subscribes URL to some of EventTypes. Secret signs each delivery;
+// like an API key it is generated on creation and shown only then.
+type Webhook struct {
+	ID        uint      `gorm:"primarykey" json:"ID"`
+	TenantID  uint      `gorm:"not null;index" json:"-"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	URL       string    `gorm:"not null" json:"url"`
+	Events    []string  `gorm:"not null;serializer:json" json:"events"`
+	Secret    string    `gorm:"not null" json:"-"`
+	Active    bool      `gorm:"not null" json:"active"`
+}
+
+// WebhookUpdate holds the webhook fields to change. An empty URL and nil
+// Events and Active keep the stored values.
+type WebhookUpdate struct {
+	URL    string
+	Events []string
+	Active *bool
+}
+
+// OutboxEvent is a change waiting to be fanned out to webhooks. Payload is
+// the book as JSON.
+type OutboxEvent struct {
+	ID           uint `gorm:"primarykey"`
+	TenantID     uint `gorm:"not null"`
+	CreatedAt    time.Time
+	Type         string `gorm:"not null"`
+	Payload      []byte `gorm:"not null"`
+	DispatchedAt *time.Time
+}
+
+// WebhookDelivery is one event sent to one webhook, and the log of trying.
+type WebhookDelivery struct {
+	ID             uint      `gorm:"primarykey" json:"ID"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	WebhookID      uint      `gorm:"not null" json:"webhookID"`
+	EventID        uint      `gorm:"not null" json:"eventID"`
+	EventType      string    `gorm:"not null" json:"eventType"`
+	Status         string    `gorm:"not null" json:"status"`
+	NextAttemptAt  time.Time `gorm:"not null" json:"nextAttemptAt"`
+	Attempts       int       `gorm:"not null" json:"attempts"`
+	ResponseStatus int       `json:"responseStatus,omitempty"`
+	LastError      string    `json:"lastError,omitempty"`
+}
+
+// PendingDelivery is a claimed delivery with what is needed to send it.
+type PendingDelivery struct {
+	Delivery   WebhookDelivery
+	URL        string
+	Secret     string
+	Payload    []byte
+	OccurredAt time.Time
+}
+
+// writeOutbox is a change hook that queues event for the webhooks.
+func writeOutbox(tx *gorm.DB, event *BookEvent) error {
+	return tx.Create(&OutboxEvent{TenantID: event.TenantID, Type: event.Type, Payload: event.Payload}).Error
+}
+
+func validateWebhook(w *Webhook) error {
+	if w.URL == "" || len(w.Events) == 0 {
+		return ErrMissingFields
+	}
+	// Whether the host is public is checked as each delivery connects, since
+	// what a name resolves to can change after it is saved.
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidWebhook)
+	}
+	for _, event := range w.Events {
+		if !slices.Contains(EventTypes, event) {
+			return fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, event)
+		}
+	}
+	return nil
+}
+
+func (db *DBModel) CreateWebhook(ctx context.Context, w *Webhook) error {
+	if err := validateWebhook(w); err != nil {
+		return err
+	}
+
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return fmt.Errorf("error generating webhook secret: %w", err)
+	}
+	w.ID = 0
+	w.Secret = webhookSecretPrefix + hex.EncodeToString(secret)
+	w.TenantID, _ = TenantFromContext(ctx)
+	return db.write(ctx, func(tx *gorm.DB) error {
+		return tx.Create(w).Error
+	})
+}
+
+// GetWebhooks and GetWebhookById read from the primary: a subscription that
+// was just created should be listed.
+func (db *DBModel) GetWebhooks(ctx context.Context) ([]Webhook, error) {
+	var webhooks []Webhook
+	err := db.scopedOn(ctx, db.DB, func(tx *gorm.DB) error {
+		return tx.Order("id").Find(&webhooks).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (db *DBModel) GetWebhookById(ctx context.Context, id int64) (*Webhook, error) {
+	var webhook Webhook
+	err := db.scopedOn(ctx, db.DB, func(tx *gorm.DB) error {
+		return tx.First(&webhook, id).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("webhook with ID %d %w", id, ErrNotFound)
+		}
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// UpdateWebhook applies the fields u sets to webhook id in one transaction,
+// so concurrent updates of different fields both take effect, and returns
+// the stored webhook. The secret is kept.
+func (db *DBModel) UpdateWebhook(ctx context.Context, id int64, u WebhookUpdate) (*Webhook, error) {
+	changes := Webhook{URL: u.URL, Events: u.Events}
+	var columns []string
+	if u.URL != "" {
+		columns = append(columns, "url")
+	}
+	if u.Events != nil {
+		columns = append(columns, "events")
+	}
+	if u.Active != nil {
+		changes.Active = *u.Active
+		columns = append(columns, "active")
+	}
+
+	var webhook Webhook
+	err := db.write(ctx, func(tx *gorm.DB) error {
+		return tx.Transaction(func(tx *gorm.DB) error {
+			if len(columns) > 0 {
+				if err := tx.Model(&Webhook{}).Where("id = ?", id).Select(columns).Updates(&changes).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.First(&webhook, id).Error; err != nil {
+				return err
+			}
+			// Validated as the update leaves it, which rolls back if invalid.
+			return validateWebhook(&webhook)
+		})
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("webhook with ID %d %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// DeleteWebhook deletes a webhook and its delivery log.
+func (db *DBModel) DeleteWebhook(ctx context.Context, id int64) (*Webhook, error) {
+	var webhook Webhook
+	err := db.write(ctx, func(tx *gorm.DB) error {
+		return tx.Transaction(func(tx *gorm.DB) error {
+			if err := tx.First(&webhook, id).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&webhook).Error; err != nil {
+				return err
+			}
+			return unscoped(tx).Where("webhook_id = ?", webhook.ID).Delete(&WebhookDelivery{}).Error
+		})
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("webhook with ID %d %w", id, ErrNotFound)
+		}
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// GetWebhookDeliveries returns the latest deliveries to a webhook of the
+// context's tenant, newest first. A limit of zero returns them all.
+func (db *DBModel) GetWebhookDeliveries(ctx context.Context, webhookID int64, limit int) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+	err := db.scopedOn(ctx, db.DB, func(tx *gorm.DB) error {
+		if err := tx.Select("id").First(&Webhook{}, webhookID).Error; err != nil {
+			return err
+		}
+		query := unscoped(tx).Where("webhook_id = ?", webhookID).Order("id DESC")
+		if limit > 0 {
+			query = query.Limit(limit)
+		}
+		return query.Find(&deliveries).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("webhook with ID %d %w", webhookID, ErrNotFound)
+		}
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// unscoped drops the tenant condition of a scoped session, for tables such
+// as webhook_deliveries that are reached through a row already checked to be
+// the tenant's.
+func unscoped(tx *gorm.DB) *gorm.DB {
+	return tx.Session(&gorm.Session{NewDB: true})
+}
+
+// DispatchEvents fans up to limit outbox events out into a pending delivery
+// for each active webhook subscribed to them, and returns how many events it
+// took. Each event is claimed and fanned out in one transaction, so instances
+// running the dispatcher side by side never deliver an event twice. Events
+// no webhook wants are deleted.
+func (db *DBModel) DispatchEvents(ctx context.Context, now time.Time, limit int) (int, error) {
+	now = now.UTC()
+	var events []OutboxEvent
+	err := db.query(ctx, func(tx *gorm.DB) error {
+		return tx.Where("dispatched_at IS NULL").Order("id").Limit(limit).Find(&events).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	dispatched := 0
+	for _, event := range events {
+		took := false
+		err := db.query(ctx, func(tx *gorm.DB) error {
+			return tx.Transaction(func(tx *gorm.DB) error {
+				var webhooks []Webhook
+				if err := tx.Where("tenant_id = ? AND active = ?", event.TenantID, true).Order("id").Find(&webhooks).Error; err != nil {
+					return err
+				}
+				var deliveries []WebhookDelivery
+				for _, w := range webhooks {
+					if slices.Contains(w.Events, event.Type) {
+						deliveries = append(deliveries, WebhookDelivery{
+							WebhookID:     w.ID,
+							EventID:       event.ID,
+							EventType:     event.Type,
+							Status:        DeliveryPending,
+							NextAttemptAt: now,
+						})
+					}
+				}
+
+				claim := tx.Where("id = ? AND dispatched_at IS NULL", event.ID)
+				if len(deliveries) == 0 {
+					claim = claim.Delete(&OutboxEvent{})
+				} else {
+					claim = claim.Model(&OutboxEvent{}).Update("dispatched_at", now)
+				}
+				if claim.Error != nil || claim.RowsAffected == 0 {
+					// Another dispatcher took the event.
+					return claim.Error
+				}
+				took = true
+				if len(deliveries) == 0 {
+					return nil
+				}
+				return tx.Create(&deliveries).Error
+			})
+		})
+		if err != nil {
+			return dispatched, err
+		}
+		if took {
+			dispatched++
+		}
+	}
+	return dispatched, nil
+}
+
+// ClaimDeliveries leases up to limit pending deliveries that are due by now
+// to the caller: their next attempt is pushed back by lease and their attempt
+// count raised, so if the caller dies before recording the outcome another
+// dispatcher retries once the lease runs out. Deliveries to inactive webhooks
+// wait until they are reactivated.
+func (db *DBModel) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]PendingDelivery, error) {
+	now = now.UTC()
+	var due []WebhookDelivery
+	err := db.query(ctx, func(tx *gorm.DB) error {
+		return tx.Joins("JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id").
+			Where("webhook_deliveries.status = ? AND webhook_deliveries.next_attempt_at <= ? AND webhooks.active = ?", DeliveryPending, now, true).
+			Order("webhook_deliveries.next_attempt_at, webhook_deliveries.id").
+			Limit(limit).Find(&due).Error
+	})
+	if err != nil || len(due) == 0 {
+		return nil, err
+	}
+
+	var claimed []PendingDelivery
+	for _, d := range due {
+		var pending *PendingDelivery
+		err := db.query(ctx, func(tx *gorm.DB) error {
+			// Attempts doubles as a version: only one claim of an attempt wins.
+			result := tx.Model(&WebhookDelivery{}).
+				Where("id = ? AND status = ? AND attempts = ?", d.ID, DeliveryPending, d.Attempts).
+				Updates(map[string]interface{}{"attempts": d.Attempts + 1, "next_attempt_at": now.Add(lease)})
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			d.Attempts++
+			d.NextAttemptAt = now.Add(lease)
+
+			var webhook Webhook
+			if err := tx.First(&webhook, d.WebhookID).Error; err != nil {
+				return err
+			}
+			var event OutboxEvent
+			if err := tx.First(&event, d.EventID).Error; err != nil {
+				return err
+			}
+			pending = &PendingDelivery{Delivery: d, URL: webhook.URL, Secret: webhook.Secret, Payload: event.Payload, OccurredAt: event.CreatedAt}
+			return nil
+		})
+		if err != nil {
+			return claimed, err
+		}
+		if pending != nil {
+			claimed = append(claimed, *pending)
+		}
+	}
+	return claimed, nil
+}
+
+// RecordDelivery stores the outcome of an attempt at d.
+func (db *DBModel) RecordDelivery(ctx context.Context, d *WebhookDelivery) error {
+	d.NextAttemptAt = d.NextAttemptAt.UTC()
+	return db.query(ctx, func(tx *gorm.DB) error {
+		return tx.Model(d).Select("status", "next_attempt_at", "response_status", "last_error").Updates(d).Error
+	})
+}
+
+// PruneDeliveries deletes finished deliveries last updated before before,
+// then the outbox events no delivery refers to any more.
+func (db *DBModel) PruneDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	var pruned int64
+	err := db.query(ctx, func(tx *gorm.DB) error {
+		result := tx.Where("status <> ? AND updated_at < ?", DeliveryPending, before).Delete(&WebhookDelivery{})
+		if result.Error != nil {
+			return result.Error
+		}
+		pruned = result.RowsAffected
+		return tx.Where("dispatched_at IS NOT NULL AND id NOT IN (?)", tx.Model(&WebhookDelivery{}).Select("event_id")).
+			Delete(&OutboxEvent{}).Error
+	})
+	return pruned, err
+}
@@ -0,0 +1,226 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setupWebhooks(t *testing.T) *DBModel {
+	t.Helper()
+	mockDB, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, _ := mockDB.DB(); sqlDB != nil {
+			sqlDB.Close()
+		}
+	})
+	return &DBModel{DB: mockDB}
+}
+
+func TestWebhookCRUD(t *testing.T) {
+	db := setupWebhooks(t)
+
+	tests := []struct {
+		name          string
+		webhook       Webhook
+		expectedError error
+	}{
+		{name: "Valid webhook", webhook: Webhook{URL: "https://example.com/hook", Events: []string{EventBookCreated}, Active: true}},
+		{name: "Missing events", webhook: Webhook{URL: "https://example.com/hook"}, expectedError: ErrMissingFields},
+		{name: "Relative URL", webhook: Webhook{URL: "/hook", Events: []string{EventBookCreated}}, expectedError: ErrInvalidWebhook},
+		{name: "Unsupported scheme", webhook: Webhook{URL: "ftp://example.com/hook", Events: []string{EventBookCreated}}, expectedError: ErrInvalidWebhook},
+		{name: "Unknown event", webhook: Webhook{URL: "https://example.com/hook", Events: []string{"book.read"}}, expectedError: ErrInvalidWebhook},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := db.CreateWebhook(testContext(), &tc.webhook)
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, strings.HasPrefix(tc.webhook.Secret, webhookSecretPrefix))
+			assert.Equal(t, defaultTenantID, tc.webhook.TenantID)
+		})
+	}
+
+	webhook, err := db.GetWebhookById(testContext(), 1)
+	assert.NoError(t, err)
+	secret := webhook.Secret
+
+	updated, err := db.UpdateWebhook(testContext(), 1, WebhookUpdate{URL: "https://example.com/other", Events: []string{EventBookCreated, EventBookDeleted}})
+	assert.NoError(t, err)
+	assert.Equal(t, secret, updated.Secret, "updates keep the secret")
+	assert.True(t, updated.Active, "fields left unset are kept")
+
+	inactive := false
+	updated, err = db.UpdateWebhook(testContext(), 1, WebhookUpdate{Active: &inactive})
+	assert.NoError(t, err)
+	assert.False(t, updated.Active)
+	assert.Equal(t, "https://example.com/other", updated.URL, "an update of one field leaves the others as stored")
+
+	_, err = db.UpdateWebhook(testContext(), 1, WebhookUpdate{Events: []string{}})
+	assert.ErrorIs(t, err, ErrMissingFields)
+	_, err = db.UpdateWebhook(testContext(), 1, WebhookUpdate{URL: "example.com"})
+	assert.ErrorIs(t, err, ErrInvalidWebhook)
+
+	webhooks, err := db.GetWebhooks(testContext())
+	assert.NoError(t, err)
+	assert.Len(t, webhooks, 1)
+	assert.Equal(t, []string{EventBookCreated, EventBookDeleted}, webhooks[0].Events, "invalid updates are rolled back")
+	assert.Equal(t, "https://example.com/other", webhooks[0].URL)
+
+	_, err = db.UpdateWebhook(testContext(), 99, WebhookUpdate{URL: "https://example.com"})
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = db.DeleteWebhook(testContext(), 1)
+	assert.NoError(t, err)
+	_, err = db.GetWebhookById(testContext(), 1)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = db.GetWebhookDeliveries(testContext(), 1, 0)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestWebhooksAreScopedToTenants(t *testing.T) {
+	db := setupWebhooks(t)
+	other, err := db.CreateTenant(context.Background(), "other", "Other")
+	assert.NoError(t, err)
+	otherCtx := WithTenant(context.Background(), other.ID)
+
+	webhook := &Webhook{URL: "https://example.com/hook", Events: []string{EventBookCreated}, Active: true}
+	assert.NoError(t, db.CreateWebhook(testContext(), webhook))
+
+	_, err = db.GetWebhookById(otherCtx, int64(webhook.ID))
+	assert.ErrorIs(t, err, ErrNotFound)
+	webhooks, err := db.GetWebhooks(otherCtx)
+	assert.NoError(t, err)
+	assert.Empty(t, webhooks)
+	_, err = db.DeleteWebhook(otherCtx, int64(webhook.ID))
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = db.GetWebhookDeliveries(otherCtx, int64(webhook.ID), 0)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Another tenant's books are not delivered to the webhook.
+	assert.NoError(t, db.CreateBook(otherCtx, &Book{Name: "Book1", Author: "Author1", Publication: "Publication1"}))
+	dispatched, err := db.DispatchEvents(context.Background(), time.Now(), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, dispatched)
+	claimed, err := db.ClaimDeliveries(context.Background(), time.Now(), time.Minute, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, claimed)
+}
+
+func TestBookChangesAreWrittenToTheOutbox(t *testing.T) {
+	db := setupWebhooks(t)
+	events := func() []OutboxEvent {
+		var events []OutboxEvent
+		assert.NoError(t, db.DB.Order("id").Find(&events).Error)
+		return events
+	}
+
+	book := &Book{Name: "Book1", Author: "Author1", Publication: "Publication1"}
+	assert.NoError(t, db.CreateBook(testContext(), book))
+	book.Name = "Book2"
+	assert.NoError(t, db.UpdateBook(testContext(), book))
+	_, err := db.DeleteBook(testContext(), int64(book.ID))
+	assert.NoError(t, err)
+
+	// Failed changes roll their event back with them.
+	assert.ErrorIs(t, db.UpdateBook(testContext(), &Book{ID: 99, Name: "Book", Author: "Author", Publication: "Publication"}), ErrNotFound)
+	_, err = db.DeleteBook(testContext(), 99)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	var types, names []string
+	for _, event := range events() {
+		assert.Equal(t, defaultTenantID, event.TenantID)
+		var payload Book
+		assert.NoError(t, json.Unmarshal(event.Payload, &payload))
+		assert.Equal(t, book.ID, payload.ID)
+		types = append(types, event.Type)
+		names = append(names, payload.Name)
+	}
+	assert.Equal(t, []string{EventBookCreated, EventBookUpdated, EventBookDeleted}, types)
+	assert.Equal(t, []string{"Book1", "Book2", "Book2"}, names)
+}
+
+func TestDispatchAndClaimDeliveries(t *testing.T) {
+	db := setupWebhooks(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	created := &Webhook{URL: "https://example.com/created", Events: []string{EventBookCreated}, Active: true}
+	all := &Webhook{URL: "https://example.com/all", Events: EventTypes, Active: true}
+	inactive := &Webhook{URL: "https://example.com/inactive", Events: EventTypes}
+	for _, w := range []*Webhook{created, all, inactive} {
+		assert.NoError(t, db.CreateWebhook(testContext(), w))
+	}
+
+	book := &Book{Name: "Book1", Author: "Author1", Publication: "Publication1"}
+	assert.NoError(t, db.CreateBook(testContext(), book))
+	assert.NoError(t, db.UpdateBook(testContext(), book))
+
+	dispatched, err := db.DispatchEvents(ctx, now, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, dispatched)
+	dispatched, err = db.DispatchEvents(ctx, now, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, dispatched, "events are dispatched once")
+
+	claimed, err := db.ClaimDeliveries(ctx, now, time.Minute, 10)
+	assert.NoError(t, err)
+	var urls []string
+	for _, p := range claimed {
+		urls = append(urls, p.URL+" "+p.Delivery.EventType)
+		assert.Equal(t, 1, p.Delivery.Attempts)
+		assert.Contains(t, string(p.Payload), `"name":"Book1"`)
+	}
+	assert.ElementsMatch(t, []string{
+		"https://example.com/created book.created",
+		"https://example.com/all book.created",
+		"https://example.com/all book.updated",
+	}, urls, "inactive webhooks get their deliveries but are not sent them")
+
+	again, err := db.ClaimDeliveries(ctx, now, time.Minute, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, again, "claimed deliveries are leased")
+
+	failed := claimed[0].Delivery
+	failed.Status, failed.LastError = DeliveryFailed, "unexpected status 500"
+	assert.NoError(t, db.RecordDelivery(ctx, &failed))
+	retried := claimed[1].Delivery
+	retried.NextAttemptAt = now
+	assert.NoError(t, db.RecordDelivery(ctx, &retried))
+
+	again, err = db.ClaimDeliveries(ctx, now, time.Minute, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, again, 1) {
+		assert.Equal(t, retried.ID, again[0].Delivery.ID)
+		assert.Equal(t, 2, again[0].Delivery.Attempts)
+	}
+
+	deliveries, err := db.GetWebhookDeliveries(testContext(), int64(failed.WebhookID), 1)
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 1)
+
+	pruned, err := db.PruneDeliveries(ctx, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), pruned, "only finished deliveries are pruned")
+}
+
+func TestDispatchDropsEventsNobodyWants(t *testing.T) {
+	db := setupWebhooks(t)
+	assert.NoError(t, db.CreateBook(testContext(), &Book{Name: "Book1", Author: "Author1", Publication: "Publication1"}))
+
+	dispatched, err := db.DispatchEvents(context.Background(), time.Now(), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, dispatched)
+	var count int64
+	assert.NoError(t, db.DB.Model(&OutboxEvent{}).Count(&count).Error)
+	assert.Equal(t, int64(0), count)
+}
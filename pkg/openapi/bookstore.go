@@ -0,0 +1,436 @@
+package openapi
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mg4603/go-bookstore-management-system/pkg/controllers"
+	"github.com/mg4603/go-bookstore-management-system/pkg/models"
+	"github.com/mg4603/go-bookstore-management-system/pkg/utils"
+)
+
+// Bookstore describes the routes registered by the routes package. The route
+// tests fail if a registered route is missing here.
+func Bookstore() *Document {
+	bookFields := []string{"name", "author", "publication"}
+	book := SchemaFor(models.Book{})
+	book.Required = append([]string{"ID"}, bookFields...)
+
+	closed := false
+	minLength := 1
+	bookInput := book.Without("ID")
+	bookInput.Required = bookFields
+	bookInput.AdditionalProperties = &closed
+	bookUpdate := book.Without("ID")
+	bookUpdate.Required = nil
+	bookUpdate.AdditionalProperties = &closed
+	// An update must change at least one field.
+	bookUpdate.MinProperties = &minLength
+	for _, name := range bookFields {
+		bookInput.Properties[name] = &Schema{Type: "string", MinLength: &minLength}
+		bookUpdate.Properties[name] = &Schema{Type: "string", MinLength: &minLength}
+	}
+
+	graphQLRequest := &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"query": {Type: "string", MinLength: &minLength},
+			// Clients commonly send these as null, so they are left untyped.
+			"operationName": {Description: "Operation to run when the query defines several."},
+			"variables":     {Description: "Values for the query's variables."},
+			"extensions":    {Description: "Ignored."},
+		},
+		Required:             []string{"query"},
+		AdditionalProperties: &closed,
+	}
+
+	webhook := SchemaFor(models.Webhook{})
+	webhook.Properties["events"].Items.Enum = models.EventTypes
+	webhook.Required = []string{"ID", "url", "events", "active"}
+	createdWebhook := webhook.Without()
+	createdWebhook.Properties["secret"] = &Schema{
+		Type: "string",
+		Description: "Key for the X-Bookstore-Signature header of each delivery, t=<unix seconds>,v1=<hex HMAC-SHA256 of \"<t>.<body>\">. " +
+			"It is only ever returned here.",
+	}
+	createdWebhook.Required = append(webhook.Required, "secret")
+	webhookUpdate := webhook.Without("ID", "createdAt", "updatedAt")
+	webhookUpdate.AdditionalProperties = &closed
+	webhookInput := webhookUpdate.Without()
+	webhookInput.Required = []string{"url", "events"}
+	webhookDelivery := SchemaFor(models.WebhookDelivery{})
+	webhookDelivery.Properties["status"].Enum = []string{models.DeliveryPending, models.DeliverySucceeded, models.DeliveryFailed}
+
+	bookID := Parameter{
+		Name:        "id",
+		In:          "path",
+		Description: "Book ID.",
+		Required:    true,
+		Schema:      &Schema{Type: "integer", Format: "int64"},
+	}
+
+	webhookID := Parameter{
+		Name:        "id",
+		In:          "path",
+		Description: "Webhook ID.",
+		Required:    true,
+		Schema:      &Schema{Type: "integer", Format: "int64"},
+	}
+	zero, one := 0.0, 1.0
+	deliveryLimit := Parameter{
+		Name:        "limit",
+		In:          "query",
+		Description: "Most deliveries to return; defaults to 50.",
+		Schema:      &Schema{Type: "integer", Minimum: &one},
+	}
+
+	doc := &Document{
+		OpenAPI: Version,
+		Info: Info{
+			Title:       "Bookstore API",
+			Description: "Manage the bookstore catalogue. Book routes negotiate the response format from the Accept header.",
+			Version:     "1.0.0",
+		},
+		Paths: map[string]PathItem{
+			"/books/": {
+				"get": {
+					OperationID: "listBooks",
+					Summary:     "List all books",
+					Tags:        []string{"books"},
+					Responses: map[string]Response{
+						"200": streamedResponse("The catalogue, streamed in batches.", &Schema{Type: "array", Items: Ref("Book")}),
+						"406": errorResponse(http.StatusNotAcceptable),
+						"429": errorResponse(http.StatusTooManyRequests),
+						"500": errorResponse(http.StatusInternalServerError),
+						"504": errorResponse(http.StatusGatewayTimeout),
+					},
+				},
+				"post": {
+					OperationID: "createBook",
+					Summary:     "Add a book",
+					Tags:        []string{"books"},
+					RequestBody: bookRequest(Ref("BookInput")),
+					Responses: map[string]Response{
+						"201": bookResponse("The created book.", Ref("Book"), false),
+						"400": errorResponse(http.StatusBadRequest),
+						"406": errorResponse(http.StatusNotAcceptable),
+						"413": errorResponse(http.StatusRequestEntityTooLarge),
+						"415": errorResponse(http.StatusUnsupportedMediaType),
+						"429": errorResponse(http.StatusTooManyRequests),
+						"500": errorResponse(http.StatusInternalServerError),
+						"504": errorResponse(http.StatusGatewayTimeout),
+					},
+				},
+			},
+			"/books/events": {
+				"get": {
+					OperationID: "streamBookEvents",
+					Summary:     "Stream book created, updated and deleted events as Server-Sent Events",
+					Tags:        []string{"books"},
+					Parameters: []Parameter{{
+						Name:        "Last-Event-ID",
+						In:          "header",
+						Description: "Sequence number of the last event received; the stream resumes after it. Without it the stream starts with the next change. If events after it have been pruned, or it is ahead of the latest event, the request fails with 410.",
+						Schema:      &Schema{Type: "integer", Format: "int64", Minimum: &zero},
+					}},
+					Responses: map[string]Response{
+						"200": {
+							Description: "An endless text/event-stream. Each event's id is its sequence number, its event field the change type (book.created, book.updated or book.deleted), and its data the book as JSON. A stream that falls behind the retained events is sent a reset event and closed; reload the books and reconnect without Last-Event-ID.",
+							Headers:     requestIDHeader(),
+							Content:     map[string]MediaType{"text/event-stream": {Schema: &Schema{Type: "string"}}},
+						},
+						"410": errorResponse(http.StatusGone),
+						"429": errorResponse(http.StatusTooManyRequests),
+						"500": errorResponse(http.StatusInternalServerError),
+						"504": errorResponse(http.StatusGatewayTimeout),
+					},
+				},
+			},
+			"/books/{id}": {
+				"get": {
+					OperationID: "getBook",
+					Summary:     "Get a book",
+					Tags:        []string{"books"},
+					Parameters:  []Parameter{bookID},
+					Responses: map[string]Response{
+						"200": bookResponse("The book.", Ref("Book"), false),
+						"400": errorResponse(http.StatusBadRequest),
+						"404": errorResponse(http.StatusNotFound),
+						"406": errorResponse(http.StatusNotAcceptable),
+						"429": errorResponse(http.StatusTooManyRequests),
+						"500": errorResponse(http.StatusInternalServerError),
+						"504": errorResponse(http.StatusGatewayTimeout),
+					},
+				},
+				"put": {
+					OperationID: "updateBook",
+					Summary:     "Update a book; omitted or empty fields are left unchanged",
+					Tags:        []string{"books"},
+					Parameters:  []Parameter{bookID},
+					RequestBody: bookRequest(Ref("BookUpdate")),
+					Responses: map[string]Response{
+						"200": bookResponse("The updated book.", Ref("Book"), false),
+						"400": errorResponse(http.StatusBadRequest),
+						"404": errorResponse(http.StatusNotFound),
+						"406": errorResponse(http.StatusNotAcceptable),
+						"413": errorResponse(http.StatusRequestEntityTooLarge),
+						"415": errorResponse(http.StatusUnsupportedMediaType),
+						"429": errorResponse(http.StatusTooManyRequests),
+						"500": errorResponse(http.StatusInternalServerError),
+						"504": errorResponse(http.StatusGatewayTimeout),
+					},
+				},
+				"delete": {
+					OperationID: "deleteBook",
+					Summary:     "Delete a book",
+					Tags:        []string{"books"},
+					Parameters:  []Parameter{bookID},
+					Responses: map[string]Response{
+						"200": bookResponse("The deleted book.", Ref("Book"), false),
+						"400": errorResponse(http.StatusBadRequest),
+						"404": errorResponse(http.StatusNotFound),
+						"406": errorResponse(http.StatusNotAcceptable),
+						"429": errorResponse(http.StatusTooManyRequests),
+						"500": errorResponse(http.StatusInternalServerError),
+						"504": errorResponse(http.StatusGatewayTimeout),
+					},
+				},
+			},
+			"/graphql": {
+				"post": {
+					OperationID: "graphql",
+					Summary:     "Run a GraphQL query or mutation against the catalogue",
+					Tags:        []string{"books"},
+					RequestBody: &RequestBody{
+						Required: true,
+						Content:  map[string]MediaType{"application/json": {Schema: Ref("GraphQLRequest")}},
+					},
+					Responses: map[string]Response{
+						"200": jsonResponse("The GraphQL result; field errors are reported in its errors list.", &Schema{Type: "object"}),
+						"400": errorResponse(http.StatusBadRequest),
+						"413": errorResponse(http.StatusRequestEntityTooLarge),
+						"415": errorResponse(http.StatusUnsupportedMediaType),
+						"429": errorResponse(http.StatusTooManyRequests),
+					},
+				},
+			},
+			"/webhooks/": {
+				"get": {
+					OperationID: "listWebhooks",
+					Summary:     "List webhook subscriptions",
+					Tags:        []string{"webhooks"},
+					Responses: webhookResponses("200",
+						bookResponse("The webhooks.", &Schema{Type: "array", Items: Ref("Webhook")}, true),
+					),
+				},
+				"post": {
+					OperationID: "createWebhook",
+					Summary:     "Subscribe a URL to catalogue change events; deliveries are only made to public addresses",
+					Tags:        []string{"webhooks"},
+					RequestBody: bookRequest(Ref("WebhookInput")),
+					Responses: webhookResponses("201",
+						bookResponse("The created webhook, with its secret.", Ref("CreatedWebhook"), false),
+						http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType,
+					),
+				},
+			},
+			"/webhooks/{id}": {
+				"get": {
+					OperationID: "getWebhook",
+					Summary:     "Get a webhook subscription",
+					Tags:        []string{"webhooks"},
+					Parameters:  []Parameter{webhookID},
+					Responses:   webhookResponses("200", bookResponse("The webhook.", Ref("Webhook"), false)),
+				},
+				"put": {
+					OperationID: "updateWebhook",
+					Summary:     "Update a webhook subscription; omitted fields are left unchanged",
+					Tags:        []string{"webhooks"},
+					Parameters:  []Parameter{webhookID},
+					RequestBody: bookRequest(Ref("WebhookUpdate")),
+					Responses: webhookResponses("200",
+						bookResponse("The updated webhook.", Ref("Webhook"), false),
+						http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType,
+					),
+				},
+				"delete": {
+					OperationID: "deleteWebhook",
+					Summary:     "Delete a webhook subscription and its delivery log",
+					Tags:        []string{"webhooks"},
+					Parameters:  []Parameter{webhookID},
+					Responses:   webhookResponses("200", bookResponse("The deleted webhook.", Ref("Webhook"), false)),
+				},
+			},
+			"/webhooks/{id}/deliveries": {
+				"get": {
+					OperationID: "listWebhookDeliveries",
+					Summary:     "List a webhook's deliveries, newest first",
+					Tags:        []string{"webhooks"},
+					Parameters:  []Parameter{webhookID, deliveryLimit},
+					Responses: webhookResponses("200",
+						bookResponse("The delivery log.", &Schema{Type: "array", Items: Ref("WebhookDelivery")}, true),
+					),
+				},
+			},
+			"/healthz": {
+				"get": {
+					OperationID: "healthz",
+					Summary:     "Liveness probe",
+					Tags:        []string{"operations"},
+					Responses: map[string]Response{
+						"200": jsonResponse("The process is alive.", Ref("HealthResponse")),
+					},
+				},
+			},
+			"/readyz": {
+				"get": {
+					OperationID: "readyz",
+					Summary:     "Readiness probe",
+					Tags:        []string{"operations"},
+					Responses: map[string]Response{
+						"200": jsonResponse("Ready to serve traffic.", Ref("HealthResponse")),
+						"503": jsonResponse("Not ready; see the failing checks.", Ref("HealthResponse")),
+					},
+				},
+			},
+			"/metrics": {
+				"get": {
+					OperationID: "metrics",
+					Summary:     "Prometheus metrics",
+					Tags:        []string{"operations"},
+					Responses: map[string]Response{
+						"200": {
+							Description: "Metrics in the Prometheus text exposition format.",
+							Content:     map[string]MediaType{"text/plain; version=0.0.4": {Schema: &Schema{Type: "string"}}},
+						},
+					},
+				},
+			},
+			"/openapi.json": {
+				"get": {
+					OperationID: "openapi",
+					Summary:     "This document",
+					Tags:        []string{"operations"},
+					Responses: map[string]Response{
+						"200": jsonResponse("The OpenAPI document.", &Schema{Type: "object"}),
+					},
+				},
+			},
+		},
+		Components: Components{
+			Schemas: map[string]*Schema{
+				"Book":            book,
+				"BookInput":       bookInput,
+				"BookUpdate":      bookUpdate,
+				"CreatedWebhook":  createdWebhook,
+				"ErrorResponse":   SchemaFor(utils.ErrorResponse{}),
+				"GraphQLRequest":  graphQLRequest,
+				"HealthResponse":  SchemaFor(controllers.HealthResponse{}),
+				"Webhook":         webhook,
+				"WebhookDelivery": webhookDelivery,
+				"WebhookInput":    webhookInput,
+				"WebhookUpdate":   webhookUpdate,
+			},
+		},
+	}
+
+	// The catalogue and its webhooks are per tenant. A request gets its API
+	// key's tenant, or without a key the server's default tenant.
+	tenant := Parameter{
+		Name:        "X-Tenant",
+		In:          "header",
+		Description: "Slug of the tenant whose catalogue to use. Any tenant but the default requires that tenant's API key as a bearer token.",
+		Schema:      &Schema{Type: "string"},
+	}
+	for path, item := range doc.Paths {
+		if !strings.HasPrefix(path, "/books/") && !strings.HasPrefix(path, "/webhooks/") && path != "/graphql" {
+			continue
+		}
+		for _, op := range item {
+			op.Parameters = append(op.Parameters, tenant)
+			op.Responses["400"] = errorResponse(http.StatusBadRequest)
+			op.Responses["401"] = errorResponse(http.StatusUnauthorized)
+			op.Responses["403"] = errorResponse(http.StatusForbidden)
+			op.Responses["404"] = errorResponse(http.StatusNotFound)
+		}
+	}
+	return doc
+}
+
+// webhookResponses returns ok under status and the errors every webhook
+// route may answer with, plus those in extra.
+func webhookResponses(status string, ok Response, extra ...int) map[string]Response {
+	responses := map[string]Response{status: ok}
+	for _, statusCode := range append([]int{http.StatusBadRequest, http.StatusNotFound, http.StatusNotAcceptable, http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusGatewayTimeout}, extra...) {
+		responses[strconv.Itoa(statusCode)] = errorResponse(statusCode)
+	}
+	return responses
+}
+
+func bookRequest(schema *Schema) *RequestBody {
+	content := map[string]MediaType{}
+	for _, mediaType := range utils.MediaTypes() {
+		if f, _ := utils.LookupFormat(mediaType); f.Decode != nil {
+			content[mediaType] = MediaType{Schema: schema}
+		}
+	}
+	return &RequestBody{Required: true, Content: content}
+}
+
+func bookResponse(description string, schema *Schema, collection bool) Response {
+	return Response{Description: description, Headers: requestIDHeader(), Content: negotiated(schema, collection)}
+}
+
+// streamedResponse is bookResponse for a collection written with
+// utils.StreamResponse, which only offers formats that can be streamed.
+func streamedResponse(description string, schema *Schema) Response {
+	response := bookResponse(description, schema, true)
+	for mediaType := range response.Content {
+		if format, _ := utils.LookupFormat(mediaType); format.Stream == nil {
+			delete(response.Content, mediaType)
+		}
+	}
+	return response
+}
+
+// Errors are written in the negotiated format too, except CSV, which only
+// encodes collections.
+func errorResponse(statusCode int) Response {
+	headers := requestIDHeader()
+	if statusCode == http.StatusTooManyRequests {
+		headers["Retry-After"] = Header{Description: "Seconds until the request may be retried.", Schema: &Schema{Type: "integer"}}
+	}
+	return Response{
+		Description: strconv.Itoa(statusCode) + " " + http.StatusText(statusCode),
+		Headers:     headers,
+		Content:     negotiated(Ref("ErrorResponse"), false),
+	}
+}
+
+// Each line of an NDJSON collection is one element.
+func negotiated(schema *Schema, collection bool) map[string]MediaType {
+	content := map[string]MediaType{}
+	for _, mediaType := range utils.MediaTypes() {
+		format, _ := utils.LookupFormat(mediaType)
+		switch {
+		case format.CollectionsOnly && !collection:
+			continue
+		case mediaType == "application/x-ndjson" && collection:
+			content[mediaType] = MediaType{Schema: schema.Items}
+		default:
+			content[mediaType] = MediaType{Schema: schema}
+		}
+	}
+	return content
+}
+
+func jsonResponse(description string, schema *Schema) Response {
+	return Response{Description: description, Content: map[string]MediaType{"application/json": {Schema: schema}}}
+}
+
+func requestIDHeader() map[string]Header {
+	return map[string]Header{
+		utils.RequestIDHeader: {Description: "Request ID, echoed from the request when valid.", Schema: &Schema{Type: "string"}},
+	}
+}
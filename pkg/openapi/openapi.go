@@ -0,0 +1,171 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const Version = "3.1.0"
+
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// PathItem maps lower-case HTTP methods to operations.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string              `json:"operationId"`
+	Summary     string              `json:"summary,omitempty"`
+	Tags        []string            `json:"tags,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// Schema is the subset of JSON Schema used by this API.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MinProperties        *int               `json:"minProperties,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+}
+
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// Operation returns the operation documented for a route template and method,
+// or nil.
+func (d *Document) Operation(path, method string) *Operation {
+	return d.Paths[path][strings.ToLower(method)]
+}
+
+func (d *Document) Handler() http.Handler {
+	body, err := json.Marshal(d)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// SchemaFor derives an object schema from a struct's exported fields and json
+// tags, so documented models follow the Go types they describe.
+func SchemaFor(v interface{}) *Schema {
+	return schemaForType(reflect.TypeOf(v))
+}
+
+func schemaForType(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.String:
+		return &Schema{Type: "string"}
+	case t.Kind() == reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uintptr:
+		zero := 0.0
+		return &Schema{Type: "integer", Format: "int64", Minimum: &zero}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return &Schema{Type: "number"}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return &Schema{Type: "array", Items: schemaForType(t.Elem())}
+	case t.Kind() == reflect.Map:
+		return &Schema{Type: "object"}
+	case t.Kind() == reflect.Struct:
+		schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			schema.Properties[name] = schemaForType(field.Type)
+		}
+		return schema
+	}
+	return &Schema{}
+}
+
+// Without returns a copy of an object schema without the named properties.
+func (s *Schema) Without(names ...string) *Schema {
+	copied := *s
+	copied.Properties = make(map[string]*Schema, len(s.Properties))
+	for name, property := range s.Properties {
+		copied.Properties[name] = property
+	}
+	for _, name := range names {
+		delete(copied.Properties, name)
+	}
+	return &copied
+}
@@ -0,0 +1,96 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type schemaRecord struct {
+	ID      uint      `json:"ID"`
+	Name    string    `json:"name,omitempty"`
+	Hidden  string    `json:"-"`
+	Tags    []string  `json:"tags"`
+	Created time.Time `json:"created"`
+	Ratio   float64
+	private string
+}
+
+func TestSchemaFor(t *testing.T) {
+	schema := SchemaFor(&schemaRecord{})
+
+	assert.Equal(t, "object", schema.Type)
+	assert.ElementsMatch(t, []string{"ID", "name", "tags", "created", "Ratio"}, keys(schema.Properties))
+	assert.Equal(t, "integer", schema.Properties["ID"].Type)
+	assert.Equal(t, 0.0, *schema.Properties["ID"].Minimum)
+	assert.Equal(t, &Schema{Type: "string"}, schema.Properties["name"])
+	assert.Equal(t, &Schema{Type: "array", Items: &Schema{Type: "string"}}, schema.Properties["tags"])
+	assert.Equal(t, &Schema{Type: "string", Format: "date-time"}, schema.Properties["created"])
+	assert.Equal(t, &Schema{Type: "number"}, schema.Properties["Ratio"])
+
+	without := schema.Without("ID", "tags")
+	assert.ElementsMatch(t, []string{"name", "created", "Ratio"}, keys(without.Properties))
+	assert.Contains(t, schema.Properties, "ID", "Without must not modify the original")
+}
+
+func TestBookstoreDocument(t *testing.T) {
+	doc := Bookstore()
+
+	book := doc.Components.Schemas["Book"]
+	assert.ElementsMatch(t, []string{"ID", "name", "author", "publication"}, keys(book.Properties))
+	assert.ElementsMatch(t, []string{"name", "author", "publication"}, doc.Components.Schemas["BookInput"].Required)
+	assert.Empty(t, doc.Components.Schemas["BookUpdate"].Required)
+
+	list := doc.Operation("/books/", "GET")
+	assert.NotNil(t, list)
+	assert.Contains(t, list.Responses["200"].Content, "text/csv")
+	assert.Equal(t, Ref("Book"), list.Responses["200"].Content["application/x-ndjson"].Schema, "each NDJSON line is one book")
+
+	get := doc.Operation("/books/{id}", "GET")
+	assert.NotNil(t, get)
+	assert.NotContains(t, get.Responses["200"].Content, "text/csv", "CSV only encodes collections")
+
+	create := doc.Operation("/books/", "POST")
+	assert.Contains(t, create.RequestBody.Content, "application/xml")
+	assert.NotContains(t, create.RequestBody.Content, "text/csv", "CSV bodies cannot be decoded")
+
+	assert.Nil(t, doc.Operation("/books/", "PATCH"))
+
+	// Every operation ID must be unique for generated clients.
+	ids := map[string]bool{}
+	for _, item := range doc.Paths {
+		for _, op := range item {
+			assert.False(t, ids[op.OperationID], "duplicate operation ID %s", op.OperationID)
+			ids[op.OperationID] = true
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Bookstore().Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/openapi.json", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var doc struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+	assert.Equal(t, Version, doc.OpenAPI)
+	assert.Contains(t, doc.Paths["/books/{id}"], "delete")
+	assert.Contains(t, rec.Body.String(), `"$ref":"#/components/schemas/Book"`)
+}
+
+func keys(m map[string]*Schema) []string {
+	var ks []string
+	for k := range m {
+		ks = append(ks, k)
+	}
+	return ks
+}
@@ -0,0 +1,289 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/mg4603/go-bookstore-management-system/pkg/utils"
+)
+
+// ValidateRequests checks path parameters, query parameters and bodies of
+// documented operations against the document and answers 400 with every
+// violation found. Routes that are not documented pass through unchecked.
+//
+// Bodies are validated for formats that share JSON's data model (JSON and
+// MessagePack); XML bodies are left to the handlers. Content types the
+// operation does not accept and oversized bodies are also passed on, so the
+// handlers report them with their usual 415 and 413.
+func (d *Document) ValidateRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op := d.currentOperation(r)
+		if op == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		violations := d.validateParameters(op, r)
+		if op.RequestBody != nil {
+			bodyViolations, err := d.validateBody(op.RequestBody, r)
+			if err != nil {
+				utils.HandleError(w, r, utils.ParseErrorStatus(err), fmt.Sprintf("error reading request body: %s", err))
+				return
+			}
+			violations = append(violations, bodyViolations...)
+		}
+
+		if len(violations) > 0 {
+			utils.HandleValidationError(w, r, violations)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (d *Document) currentOperation(r *http.Request) *Operation {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return nil
+	}
+	path, err := route.GetPathTemplate()
+	if err != nil {
+		return nil
+	}
+	return d.Operation(path, r.Method)
+}
+
+func (d *Document) validateParameters(op *Operation, r *http.Request) []utils.Violation {
+	var violations []utils.Violation
+	vars := mux.Vars(r)
+	query := r.URL.Query()
+
+	for _, param := range op.Parameters {
+		var value string
+		var present bool
+		switch param.In {
+		case "path":
+			value, present = vars[param.Name]
+		case "query":
+			present = query.Has(param.Name)
+			value = query.Get(param.Name)
+		default:
+			continue
+		}
+
+		pointer := "/" + escapePointer(param.Name)
+		if !present {
+			if param.Required {
+				violations = append(violations, utils.Violation{In: param.In, Pointer: pointer, Message: "is required"})
+			}
+			continue
+		}
+
+		parsed, err := parseParameter(value, d.resolve(param.Schema))
+		if err != nil {
+			violations = append(violations, utils.Violation{In: param.In, Pointer: pointer, Message: err.Error()})
+			continue
+		}
+		violations = append(violations, d.validate(param.Schema, parsed, param.In, pointer)...)
+	}
+	return violations
+}
+
+// parseParameter converts a raw parameter to the JSON value its schema
+// describes, so it can be validated like a body value.
+func parseParameter(value string, schema *Schema) (interface{}, error) {
+	switch schema.Type {
+	case "integer":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("must be an integer, got %q", value)
+		}
+		return float64(n), nil
+	case "number":
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("must be a number, got %q", value)
+		}
+		return n, nil
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("must be a boolean, got %q", value)
+		}
+		return b, nil
+	}
+	return value, nil
+}
+
+func (d *Document) validateBody(body *RequestBody, r *http.Request) ([]utils.Violation, error) {
+	mediaType := "application/json"
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		parsed, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return nil, nil
+		}
+		mediaType = parsed
+	}
+	content, ok := body.Content[mediaType]
+	format, registered := utils.LookupFormat(mediaType)
+	if !ok || !registered || format.Decode == nil || strings.HasSuffix(mediaType, "xml") {
+		return nil, nil
+	}
+
+	// Read one byte past the limit to tell an oversized body from one that
+	// is exactly at it, then hand the handler an equivalent body.
+	data, err := io.ReadAll(io.LimitReader(r.Body, utils.MaxBodyBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > utils.MaxBodyBytes {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), r.Body), r.Body}
+		return nil, nil
+	}
+	r.Body = io.NopCloser(bytes.NewReader(data))
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		if body.Required {
+			return []utils.Violation{{In: "body", Pointer: "", Message: "request body is required"}}, nil
+		}
+		return nil, nil
+	}
+
+	var value interface{}
+	if err := format.Decode(bytes.NewReader(data), &value); err != nil {
+		return []utils.Violation{{In: "body", Pointer: "", Message: fmt.Sprintf("invalid %s: %s", format.Name, err)}}, nil
+	}
+	return d.validate(content.Schema, value, "body", ""), nil
+}
+
+func (d *Document) resolve(schema *Schema) *Schema {
+	for schema != nil && schema.Ref != "" {
+		schema = d.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	if schema == nil {
+		return &Schema{}
+	}
+	return schema
+}
+
+func (d *Document) validate(schema *Schema, value interface{}, in, pointer string) []utils.Violation {
+	schema = d.resolve(schema)
+	violation := func(format string, args ...interface{}) []utils.Violation {
+		return []utils.Violation{{In: in, Pointer: pointer, Message: fmt.Sprintf(format, args...)}}
+	}
+
+	if schema.Type != "" && !hasType(value, schema.Type) {
+		return violation("must be of type %s, got %s", schema.Type, typeName(value))
+	}
+	if len(schema.Enum) > 0 {
+		s, _ := value.(string)
+		found := false
+		for _, allowed := range schema.Enum {
+			found = found || s == allowed
+		}
+		if !found {
+			return violation("must be one of %s", strings.Join(schema.Enum, ", "))
+		}
+	}
+
+	var violations []utils.Violation
+	switch v := value.(type) {
+	case string:
+		if schema.MinLength != nil && len([]rune(v)) < *schema.MinLength {
+			violations = append(violations, violation("must be at least %d characters long", *schema.MinLength)...)
+		}
+	case float64:
+		if schema.Minimum != nil && v < *schema.Minimum {
+			violations = append(violations, violation("must be at least %v", *schema.Minimum)...)
+		}
+	case []interface{}:
+		for i, item := range v {
+			violations = append(violations, d.validate(schema.Items, item, in, pointer+"/"+strconv.Itoa(i))...)
+		}
+	case map[string]interface{}:
+		if schema.MinProperties != nil && len(v) < *schema.MinProperties {
+			violations = append(violations, violation("must have at least %d properties", *schema.MinProperties)...)
+		}
+		for _, name := range schema.Required {
+			if _, ok := v[name]; !ok {
+				violations = append(violations, utils.Violation{In: in, Pointer: pointer + "/" + escapePointer(name), Message: "is required"})
+			}
+		}
+
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			child := pointer + "/" + escapePointer(name)
+			property, ok := schema.Properties[name]
+			if !ok {
+				if schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+					violations = append(violations, utils.Violation{In: in, Pointer: child, Message: "is not allowed"})
+				}
+				continue
+			}
+			violations = append(violations, d.validate(property, v[name], in, child)...)
+		}
+	}
+	return violations
+}
+
+func hasType(value interface{}, schemaType string) bool {
+	switch schemaType {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	}
+	return true
+}
+
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// escapePointer escapes a reference token as RFC 6901 requires.
+func escapePointer(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
@@ -0,0 +1,269 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mg4603/go-bookstore-management-system/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// echoRouter mirrors the documented book routes with a handler that echoes the
+// body it receives, to show validated bodies reach handlers intact.
+func echoRouter(doc *Document) *mux.Router {
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}
+
+	r := mux.NewRouter()
+	r.Use(doc.ValidateRequests)
+	r.HandleFunc("/books/", echo).Methods("GET", "POST")
+	r.HandleFunc("/books/{id}", echo).Methods("GET", "PUT", "DELETE")
+	r.HandleFunc("/undocumented/{id}", echo).Methods("POST")
+	return r
+}
+
+func TestValidateRequests(t *testing.T) {
+	msgPackBook, err := utils.MarshalMsgPack(map[string]interface{}{"name": "Book1", "author": 7})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name               string
+		method             string
+		url                string
+		contentType        string
+		body               []byte
+		expectedStatus     int
+		expectedViolations []utils.Violation
+	}{
+		{
+			name:           "Valid create",
+			method:         "POST",
+			url:            "/books/",
+			body:           []byte(`{"name":"Book1","author":"Author1","publication":"Publication1"}`),
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Create reports every violation",
+			method:         "POST",
+			url:            "/books/",
+			contentType:    "application/json",
+			body:           []byte(`{"name":"","author":42,"ID":3}`),
+			expectedStatus: http.StatusBadRequest,
+			expectedViolations: []utils.Violation{
+				{In: "body", Pointer: "/publication", Message: "is required"},
+				{In: "body", Pointer: "/ID", Message: "is not allowed"},
+				{In: "body", Pointer: "/author", Message: "must be of type string, got number"},
+				{In: "body", Pointer: "/name", Message: "must be at least 1 characters long"},
+			},
+		},
+		{
+			name:           "Empty create body",
+			method:         "POST",
+			url:            "/books/",
+			expectedStatus: http.StatusBadRequest,
+			expectedViolations: []utils.Violation{
+				{In: "body", Pointer: "", Message: "request body is required"},
+			},
+		},
+		{
+			name:           "Malformed JSON",
+			method:         "POST",
+			url:            "/books/",
+			body:           []byte(`{"name":`),
+			expectedStatus: http.StatusBadRequest,
+			expectedViolations: []utils.Violation{
+				{In: "body", Pointer: "", Message: "invalid JSON: unexpected EOF"},
+			},
+		},
+		{
+			name:           "Body must be an object",
+			method:         "POST",
+			url:            "/books/",
+			body:           []byte(`["Book1"]`),
+			expectedStatus: http.StatusBadRequest,
+			expectedViolations: []utils.Violation{
+				{In: "body", Pointer: "", Message: "must be of type object, got array"},
+			},
+		},
+		{
+			name:           "MessagePack bodies are validated",
+			method:         "POST",
+			url:            "/books/",
+			contentType:    "application/msgpack",
+			body:           msgPackBook,
+			expectedStatus: http.StatusBadRequest,
+			expectedViolations: []utils.Violation{
+				{In: "body", Pointer: "/publication", Message: "is required"},
+				{In: "body", Pointer: "/author", Message: "must be of type string, got number"},
+			},
+		},
+		{
+			name:           "XML bodies are left to the handler",
+			method:         "POST",
+			url:            "/books/",
+			contentType:    "application/xml",
+			body:           []byte(`<book><name>Book1</name></book>`),
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Unsupported media types are left to the handler",
+			method:         "POST",
+			url:            "/books/",
+			contentType:    "text/plain",
+			body:           []byte(`Book1`),
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Partial update",
+			method:         "PUT",
+			url:            "/books/1",
+			body:           []byte(`{"author":"Author2"}`),
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Empty update",
+			method:         "PUT",
+			url:            "/books/1",
+			body:           []byte(`{}`),
+			expectedStatus: http.StatusBadRequest,
+			expectedViolations: []utils.Violation{
+				{In: "body", Pointer: "", Message: "must have at least 1 properties"},
+			},
+		},
+		{
+			name:           "Update with an empty field",
+			method:         "PUT",
+			url:            "/books/1",
+			body:           []byte(`{"name":""}`),
+			expectedStatus: http.StatusBadRequest,
+			expectedViolations: []utils.Violation{
+				{In: "body", Pointer: "/name", Message: "must be at least 1 characters long"},
+			},
+		},
+		{
+			name:           "Update with invalid id and unknown field",
+			method:         "PUT",
+			url:            "/books/abc",
+			body:           []byte(`{"title":"Book2"}`),
+			expectedStatus: http.StatusBadRequest,
+			expectedViolations: []utils.Violation{
+				{In: "path", Pointer: "/id", Message: `must be an integer, got "abc"`},
+				{In: "body", Pointer: "/title", Message: "is not allowed"},
+			},
+		},
+		{
+			name:           "Invalid id on delete",
+			method:         "DELETE",
+			url:            "/books/1.5",
+			expectedStatus: http.StatusBadRequest,
+			expectedViolations: []utils.Violation{
+				{In: "path", Pointer: "/id", Message: `must be an integer, got "1.5"`},
+			},
+		},
+		{
+			name:           "Undocumented routes pass through",
+			method:         "POST",
+			url:            "/undocumented/abc",
+			body:           []byte(`not json`),
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	r := echoRouter(Bookstore())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.url, bytes.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code, rec.Body.String())
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, string(tt.body), rec.Body.String(), "handler should see the original body")
+				return
+			}
+
+			var response utils.ErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+			assert.Equal(t, "request validation failed", response.Message)
+			assert.Equal(t, tt.expectedViolations, response.Violations)
+		})
+	}
+}
+
+func TestValidateRequestsQueryParameters(t *testing.T) {
+	minimum := 1.0
+	doc := Bookstore()
+	doc.Paths["/books/"]["get"].Parameters = []Parameter{
+		{Name: "limit", In: "query", Schema: &Schema{Type: "integer", Minimum: &minimum}},
+		{Name: "sort", In: "query", Required: true, Schema: &Schema{Type: "string", Enum: []string{"name", "author"}}},
+	}
+	r := echoRouter(doc)
+
+	tests := []struct {
+		name               string
+		query              string
+		expectedViolations []utils.Violation
+	}{
+		{name: "Valid", query: "?limit=5&sort=name"},
+		{name: "Optional parameter omitted", query: "?sort=author"},
+		{
+			name:  "Every parameter invalid",
+			query: "?limit=0&sort=price",
+			expectedViolations: []utils.Violation{
+				{In: "query", Pointer: "/limit", Message: "must be at least 1"},
+				{In: "query", Pointer: "/sort", Message: "must be one of name, author"},
+			},
+		},
+		{
+			name:  "Required parameter missing",
+			query: "?limit=ten",
+			expectedViolations: []utils.Violation{
+				{In: "query", Pointer: "/limit", Message: `must be an integer, got "ten"`},
+				{In: "query", Pointer: "/sort", Message: "is required"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest("GET", "/books/"+tt.query, nil))
+
+			if tt.expectedViolations == nil {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				return
+			}
+			var response utils.ErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+			assert.Equal(t, tt.expectedViolations, response.Violations)
+		})
+	}
+}
+
+func TestValidateRequestsOversizedBody(t *testing.T) {
+	previous := utils.MaxBodyBytes
+	utils.MaxBodyBytes = 16
+	defer func() { utils.MaxBodyBytes = previous }()
+
+	body := `{"name":"` + strings.Repeat("a", 32) + `"}`
+	rec := httptest.NewRecorder()
+	echoRouter(Bookstore()).ServeHTTP(rec, httptest.NewRequest("POST", "/books/", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusOK, rec.Code, "oversized bodies are left for the handler to reject")
+	assert.Equal(t, body, rec.Body.String())
+}
+
+func TestEscapePointer(t *testing.T) {
+	assert.Equal(t, "a~1b~0c", escapePointer("a/b~c"))
+}
@@ -0,0 +1,108 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mg4603/go-bookstore-management-system/pkg/models"
+	"github.com/mg4603/go-bookstore-management-system/pkg/utils"
+)
+
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (*models.APIKey, error)
+}
+
+// ClientKeyer identifies the client a request is counted against: its API key
+// when it presents a valid one, otherwise its IP address.
+type ClientKeyer struct {
+	trustedProxies []*net.IPNet
+	keys           APIKeyAuthenticator
+}
+
+// NewClientKeyer trusts X-Forwarded-For only on requests arriving from
+// trustedProxies. keys may be nil to key every request by IP.
+func NewClientKeyer(trustedProxies []*net.IPNet, keys APIKeyAuthenticator) *ClientKeyer {
+	return &ClientKeyer{trustedProxies: trustedProxies, keys: keys}
+}
+
+// Key only uses API keys that authenticate; otherwise a client could get a
+// fresh bucket per request by sending made-up keys.
+func (c *ClientKeyer) Key(r *http.Request) string {
+	if c.keys != nil {
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && token != "" {
+			if apiKey, err := c.keys.AuthenticateAPIKey(r.Context(), token); err == nil {
+				return "key:" + strconv.FormatUint(uint64(apiKey.ID), 10)
+			}
+		}
+	}
+	return "ip:" + c.ClientIP(r)
+}
+
+// ClientIP walks X-Forwarded-For from the nearest hop back and returns the
+// first address that is not a trusted proxy.
+func (c *ClientKeyer) ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !c.trusted(ip) {
+		return host
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			// A malformed entry was not written by a proxy we trust, so
+			// nothing before it can be believed either.
+			return ip.String()
+		}
+		ip = hop
+		if !c.trusted(hop) {
+			break
+		}
+	}
+	return ip.String()
+}
+
+func (c *ClientKeyer) trusted(ip net.IP) bool {
+	for _, network := range c.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTrustedProxies parses a comma-separated list of CIDRs or single IPs.
+func ParseTrustedProxies(s string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, entry := range utils.SplitList(s) {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+			}
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
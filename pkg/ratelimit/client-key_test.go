@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mg4603/go-bookstore-management-system/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubKeys map[string]uint
+
+func (s stubKeys) AuthenticateAPIKey(_ context.Context, key string) (*models.APIKey, error) {
+	if id, ok := s[key]; ok {
+		return &models.APIKey{ID: id}, nil
+	}
+	return nil, models.ErrInvalidAPIKey
+}
+
+func TestClientKey(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8, 192.0.2.1, 2001:db8::/32")
+	assert.NoError(t, err)
+	keyer := NewClientKeyer(proxies, stubKeys{"bks_valid": 7})
+
+	tests := []struct {
+		name          string
+		remoteAddr    string
+		forwardedFor  []string
+		authorization string
+		expectedKey   string
+	}{
+		{name: "Direct client", remoteAddr: "203.0.113.5:5000", expectedKey: "ip:203.0.113.5"},
+		{name: "Untrusted peer cannot spoof", remoteAddr: "203.0.113.5:5000", forwardedFor: []string{"198.51.100.1"}, expectedKey: "ip:203.0.113.5"},
+		{name: "Trusted proxy", remoteAddr: "10.1.2.3:5000", forwardedFor: []string{"198.51.100.1"}, expectedKey: "ip:198.51.100.1"},
+		{name: "Chain of trusted proxies", remoteAddr: "10.1.2.3:5000", forwardedFor: []string{"6.6.6.6, 198.51.100.1, 192.0.2.1"}, expectedKey: "ip:198.51.100.1"},
+		{name: "Multiple headers", remoteAddr: "10.1.2.3:5000", forwardedFor: []string{"198.51.100.1", "10.9.9.9"}, expectedKey: "ip:198.51.100.1"},
+		{name: "Only trusted hops", remoteAddr: "10.1.2.3:5000", forwardedFor: []string{"10.4.4.4"}, expectedKey: "ip:10.4.4.4"},
+		{name: "Malformed hop", remoteAddr: "10.1.2.3:5000", forwardedFor: []string{"198.51.100.1, garbage"}, expectedKey: "ip:10.1.2.3"},
+		{name: "IPv6 proxy", remoteAddr: "[2001:db8::1]:5000", forwardedFor: []string{"2001:db9::5"}, expectedKey: "ip:2001:db9::5"},
+		{name: "Valid API key", remoteAddr: "203.0.113.5:5000", authorization: "Bearer bks_valid", expectedKey: "key:7"},
+		{name: "Invalid API key falls back to IP", remoteAddr: "203.0.113.5:5000", authorization: "Bearer bks_made_up", expectedKey: "ip:203.0.113.5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/books/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwardedFor {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			assert.Equal(t, tt.expectedKey, keyer.Key(req))
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	networks, err := ParseTrustedProxies("")
+	assert.NoError(t, err)
+	assert.Empty(t, networks)
+
+	networks, err = ParseTrustedProxies("127.0.0.1,::1")
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1/32", networks[0].String())
+	assert.Equal(t, "::1/128", networks[1].String())
+
+	_, err = ParseTrustedProxies("10.0.0.0/33")
+	assert.ErrorContains(t, err, `invalid trusted proxy "10.0.0.0/33"`)
+	_, err = ParseTrustedProxies("proxy.internal")
+	assert.EqualError(t, err, `invalid trusted proxy "proxy.internal"`)
+}
@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseLimit parses "REQUESTS/PERIOD", e.g. "30/1m". "off" and "" mean
+// unlimited; a count of zero is rejected rather than read as either.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "off" {
+		return Limit{}, nil
+	}
+
+	requests, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: want REQUESTS/PERIOD", s)
+	}
+	n, err := strconv.Atoi(requests)
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: bad request count", s)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: bad period", s)
+	}
+	return Limit{Requests: n, Period: d}, nil
+}
+
+// ParseRouteLimits parses semicolon-separated "METHOD /route=LIMIT" entries,
+// e.g. "POST /books/=30/1m; GET /healthz=off".
+func ParseRouteLimits(s string) (map[string]Limit, error) {
+	limits := map[string]Limit{}
+	for _, entry := range strings.Split(s, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, value, ok := strings.Cut(entry, "=")
+		method, path, hasPath := strings.Cut(strings.TrimSpace(route), " ")
+		if !ok || !hasPath || method == "" || strings.TrimSpace(path) == "" {
+			return nil, fmt.Errorf("invalid route limit %q: want METHOD /route=LIMIT", entry)
+		}
+		limit, err := ParseLimit(value)
+		if err != nil {
+			return nil, err
+		}
+		limits[strings.ToUpper(method)+" "+strings.TrimSpace(path)] = limit
+	}
+	return limits, nil
+}
+
+const (
+	defaultLimit = "300/1m"
+	// Writes are limited more tightly than reads; probes and scrapes are not
+	// limited at all.
+	defaultRouteLimits = "POST /books/=30/1m; PUT /books/{id}=60/1m; DELETE /books/{id}=60/1m; " +
+		"GET /healthz=off; GET /readyz=off; GET /metrics=off"
+)
+
+type Config struct {
+	Default        Limit
+	Routes         map[string]Limit
+	TrustedProxies []*net.IPNet
+}
+
+// ConfigFrom reads RATE_LIMIT, RATE_LIMIT_ROUTES and TRUSTED_PROXIES through
+// lookup. Routes in RATE_LIMIT_ROUTES override the built-in per-route limits.
+func ConfigFrom(lookup func(key string) (string, bool)) (Config, error) {
+	get := func(key string) string { v, _ := lookup(key); return v }
+	var cfg Config
+	var err error
+
+	limit := get("RATE_LIMIT")
+	if limit == "" {
+		limit = defaultLimit
+	}
+	if cfg.Default, err = ParseLimit(limit); err != nil {
+		return Config{}, fmt.Errorf("RATE_LIMIT: %w", err)
+	}
+
+	if cfg.Routes, err = ParseRouteLimits(defaultRouteLimits); err != nil {
+		return Config{}, err
+	}
+	overrides, err := ParseRouteLimits(get("RATE_LIMIT_ROUTES"))
+	if err != nil {
+		return Config{}, fmt.Errorf("RATE_LIMIT_ROUTES: %w", err)
+	}
+	for route, l := range overrides {
+		cfg.Routes[route] = l
+	}
+
+	if cfg.TrustedProxies, err = ParseTrustedProxies(get("TRUSTED_PROXIES")); err != nil {
+		return Config{}, fmt.Errorf("TRUSTED_PROXIES: %w", err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		input         string
+		expected      Limit
+		expectedError string
+	}{
+		{input: "30/1m", expected: Limit{Requests: 30, Period: time.Minute}},
+		{input: " 5/10s ", expected: Limit{Requests: 5, Period: 10 * time.Second}},
+		{input: "off", expected: Limit{}},
+		{input: "", expected: Limit{}},
+		{input: "30", expectedError: `invalid rate limit "30": want REQUESTS/PERIOD`},
+		{input: "x/1m", expectedError: `invalid rate limit "x/1m": bad request count`},
+		{input: "0/1m", expectedError: `invalid rate limit "0/1m": bad request count`},
+		{input: "30/0s", expectedError: `invalid rate limit "30/0s": bad period`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			limit, err := ParseLimit(tt.input)
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, limit)
+		})
+	}
+}
+
+func TestParseRouteLimits(t *testing.T) {
+	limits, err := ParseRouteLimits("post /books/=10/1m; GET /healthz=off;")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]Limit{
+		"POST /books/": {Requests: 10, Period: time.Minute},
+		"GET /healthz": {},
+	}, limits)
+
+	_, err = ParseRouteLimits("/books/=10/1m")
+	assert.EqualError(t, err, `invalid route limit "/books/=10/1m": want METHOD /route=LIMIT`)
+}
+
+func TestConfigFrom(t *testing.T) {
+	env := map[string]string{
+		"RATE_LIMIT_ROUTES": "POST /books/=5/1s; GET /books/=off",
+		"TRUSTED_PROXIES":   "10.0.0.0/8",
+	}
+	lookup := func(key string) (string, bool) { v, ok := env[key]; return v, ok }
+
+	cfg, err := ConfigFrom(lookup)
+	assert.NoError(t, err)
+	assert.Equal(t, Limit{Requests: 300, Period: time.Minute}, cfg.Default)
+	assert.Equal(t, Limit{Requests: 5, Period: time.Second}, cfg.Routes["POST /books/"], "overrides replace defaults")
+	assert.True(t, cfg.Routes["GET /books/"].Unlimited())
+	assert.True(t, cfg.Routes["GET /healthz"].Unlimited(), "built-in limits are kept")
+	assert.Len(t, cfg.TrustedProxies, 1)
+
+	env["RATE_LIMIT"] = "fast"
+	_, err = ConfigFrom(lookup)
+	assert.ErrorContains(t, err, "RATE_LIMIT: invalid rate limit")
+}
@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often MemoryStore drops buckets that have refilled,
+// which are indistinguishable from new ones.
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	limit   Limit
+}
+
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*bucket{}}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		s.sweep(now)
+	}
+
+	capacity := float64(limit.Requests)
+	rate := capacity / limit.Period.Seconds()
+
+	b, ok := s.buckets[key]
+	if !ok || b.limit != limit {
+		b = &bucket{tokens: capacity, updated: now, limit: limit}
+		s.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+elapsed*rate)
+		b.updated = now
+	}
+
+	result := Result{Allowed: b.tokens >= 1}
+	if result.Allowed {
+		b.tokens--
+	} else {
+		result.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.ResetAfter = seconds((capacity - b.tokens) / rate)
+	return result, nil
+}
+
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		refill := float64(b.limit.Requests) / b.limit.Period.Seconds()
+		if b.tokens+now.Sub(b.updated).Seconds()*refill >= float64(b.limit.Requests) {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStoreTokenBucket(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Requests: 3, Period: 3 * time.Second}
+	start := time.Unix(1000, 0)
+
+	tests := []struct {
+		name            string
+		at              time.Duration
+		key             string
+		expectedAllowed bool
+		expectedRemain  int
+		expectedRetry   time.Duration
+		expectedReset   time.Duration
+	}{
+		{name: "First request", at: 0, key: "a", expectedAllowed: true, expectedRemain: 2, expectedReset: time.Second},
+		{name: "Second request", at: 0, key: "a", expectedAllowed: true, expectedRemain: 1, expectedReset: 2 * time.Second},
+		{name: "Burst used up", at: 0, key: "a", expectedAllowed: true, expectedRemain: 0, expectedReset: 3 * time.Second},
+		{name: "Rejected", at: 0, key: "a", expectedAllowed: false, expectedRemain: 0, expectedRetry: time.Second, expectedReset: 3 * time.Second},
+		{name: "Other clients are independent", at: 0, key: "b", expectedAllowed: true, expectedRemain: 2, expectedReset: time.Second},
+		{name: "Partially refilled", at: 500 * time.Millisecond, key: "a", expectedAllowed: false, expectedRemain: 0, expectedRetry: 500 * time.Millisecond, expectedReset: 2500 * time.Millisecond},
+		{name: "One token back", at: time.Second, key: "a", expectedAllowed: true, expectedRemain: 0, expectedReset: 3 * time.Second},
+		{name: "Fully refilled", at: 10 * time.Second, key: "a", expectedAllowed: true, expectedRemain: 2, expectedReset: time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := store.Take(context.Background(), tt.key, limit, start.Add(tt.at))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedAllowed, result.Allowed)
+			assert.Equal(t, tt.expectedRemain, result.Remaining)
+			assert.InDelta(t, tt.expectedRetry, result.RetryAfter, float64(time.Millisecond))
+			assert.InDelta(t, tt.expectedReset, result.ResetAfter, float64(time.Millisecond))
+		})
+	}
+}
+
+func TestMemoryStoreSweepsFullBuckets(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Requests: 2, Period: time.Second}
+	start := time.Unix(1000, 0)
+
+	_, err := store.Take(context.Background(), "a", limit, start)
+	assert.NoError(t, err)
+	_, err = store.Take(context.Background(), "b", Limit{Requests: 1, Period: time.Hour}, start)
+	assert.NoError(t, err)
+	assert.Len(t, store.buckets, 2)
+
+	_, err = store.Take(context.Background(), "c", limit, start.Add(sweepInterval))
+	assert.NoError(t, err)
+	assert.Len(t, store.buckets, 2, "refilled bucket a should be dropped, b is still draining")
+	assert.Contains(t, store.buckets, "b")
+}
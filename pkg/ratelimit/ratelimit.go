@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mg4603/go-bookstore-management-system/pkg/utils"
+)
+
+// Limit allows Requests per Period for each client, as a token bucket holding
+// Requests tokens that refills at Requests/Period. A zero Limit is unlimited.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+func (l Limit) Unlimited() bool {
+	return l.Requests <= 0 || l.Period <= 0
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter is how long until the next request would be allowed.
+	RetryAfter time.Duration
+	// ResetAfter is how long until the bucket is full again.
+	ResetAfter time.Duration
+}
+
+// Store keeps the buckets. MemoryStore works for a single instance; a shared
+// store lets several instances enforce one limit.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+type Limiter struct {
+	store   Store
+	clients *ClientKeyer
+	// Default applies to routes without an entry in Routes, which are keyed
+	// by "METHOD /route/{template}".
+	Default Limit
+	Routes  map[string]Limit
+	now     func() time.Time
+}
+
+func NewLimiter(store Store, clients *ClientKeyer, defaultLimit Limit, routes map[string]Limit) *Limiter {
+	return &Limiter{store: store, clients: clients, Default: defaultLimit, Routes: routes, now: time.Now}
+}
+
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeKey(r)
+		limit, ok := l.Routes[route]
+		if !ok {
+			limit = l.Default
+		}
+		if limit.Unlimited() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		result, err := l.store.Take(r.Context(), route+"|"+l.clients.Key(r), limit, l.now())
+		if err != nil {
+			// Failing open keeps the API up when a shared store is not.
+			utils.LoggerFromContext(r.Context()).Error("rate limit store failed", "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+		if !result.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			utils.HandleError(w, r, http.StatusTooManyRequests, fmt.Sprintf("rate limit of %s exceeded for %s", limit, route))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func routeKey(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			return r.Method + " " + tmpl
+		}
+	}
+	return r.Method + " unmatched"
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mg4603/go-bookstore-management-system/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, Limit, time.Time) (Result, error) {
+	return Result{}, errors.New("store unavailable")
+}
+
+func newRouter(limiter *Limiter) *mux.Router {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) }
+	r := mux.NewRouter()
+	r.Use(limiter.Middleware)
+	r.HandleFunc("/books/", ok).Methods("GET", "POST")
+	r.HandleFunc("/books/{id}", ok).Methods("GET")
+	r.HandleFunc("/healthz", ok).Methods("GET")
+	return r
+}
+
+func TestMiddleware(t *testing.T) {
+	limiter := NewLimiter(NewMemoryStore(), NewClientKeyer(nil, nil), Limit{Requests: 3, Period: time.Minute}, map[string]Limit{
+		"POST /books/": {Requests: 1, Period: time.Minute},
+		"GET /healthz": {},
+	})
+	now := time.Unix(1000, 0)
+	limiter.now = func() time.Time { return now }
+	r := newRouter(limiter)
+
+	do := func(method, url, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("POST", "/books/", "203.0.113.5:1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "60", rec.Header().Get("RateLimit-Reset"))
+
+	rec = do("POST", "/books/", "203.0.113.5:2")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var response utils.ErrorResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "An error occurred. Please try again later.", response.Message)
+
+	assert.Equal(t, http.StatusOK, do("POST", "/books/", "198.51.100.1:1").Code, "other clients have their own bucket")
+	assert.Equal(t, http.StatusOK, do("GET", "/books/", "203.0.113.5:1").Code, "routes have their own bucket")
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, do("GET", "/books/1", "203.0.113.5:1").Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, do("GET", "/books/2", "203.0.113.5:1").Code, "path parameters share the route's bucket")
+
+	for i := 0; i < 10; i++ {
+		rec := do("GET", "/healthz", "203.0.113.5:1")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("RateLimit-Limit"), "unlimited routes send no headers")
+	}
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, http.StatusOK, do("POST", "/books/", "203.0.113.5:2").Code, "tokens refill over the period")
+}
+
+func TestMiddlewareFailsOpen(t *testing.T) {
+	limiter := NewLimiter(failingStore{}, NewClientKeyer(nil, nil), Limit{Requests: 1, Period: time.Minute}, nil)
+	rec := httptest.NewRecorder()
+	newRouter(limiter).ServeHTTP(rec, httptest.NewRequest("GET", "/books/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -9,9 +9,9 @@ import (
 )
 
 func RegisterBookstoreRoutes(r *mux.Router, controllers *controllers.BookstoreController) {
-	r.Handle("/books/", utils.SetJSONContentType(http.HandlerFunc(controllers.CreateBook))).Methods("POST")
-	r.Handle("/books/", utils.SetJSONContentType(http.HandlerFunc(controllers.GetBooks))).Methods("GET")
-	r.Handle("/books/{id}", utils.SetJSONContentType(http.HandlerFunc(controllers.GetBookById))).Methods("GET")
-	r.Handle("/books/{id}", utils.SetJSONContentType(http.HandlerFunc(controllers.DeleteBook))).Methods("DELETE")
-	r.Handle("/books/{id}", utils.SetJSONContentType(http.HandlerFunc(controllers.UpdateBook))).Methods("PUT")
+	r.Handle("/books/", utils.NegotiateRecord(http.HandlerFunc(controllers.CreateBook))).Methods("POST")
+	r.Handle("/books/", utils.NegotiateStream(http.HandlerFunc(controllers.GetBooks))).Methods("GET")
+	r.Handle("/books/{id}", utils.NegotiateRecord(http.HandlerFunc(controllers.GetBookById))).Methods("GET")
+	r.Handle("/books/{id}", utils.NegotiateRecord(http.HandlerFunc(controllers.DeleteBook))).Methods("DELETE")
+	r.Handle("/books/{id}", utils.NegotiateRecord(http.HandlerFunc(controllers.UpdateBook))).Methods("PUT")
 }
@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// RegisterChangeFeedRoutes must run before RegisterBookstoreRoutes, whose
+// /books/{id} would otherwise match /books/events.
+func RegisterChangeFeedRoutes(r *mux.Router, handler http.Handler) {
+	r.Handle("/books/events", handler).Methods("GET")
+}
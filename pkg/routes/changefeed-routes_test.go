@@ -0,0 +1,39 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mg4603/go-bookstore-management-system/pkg/controllers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterChangeFeedRoutes(t *testing.T) {
+	r := mux.NewRouter()
+	RegisterChangeFeedRoutes(r, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("events"))
+	}))
+	RegisterBookstoreRoutes(r, &controllers.BookstoreController{GetBookById: mockGetBookById})
+
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "GET events", method: "GET", url: "/books/events", expectedStatus: http.StatusOK, expectedBody: "events"},
+		{name: "GET book still routes by ID", method: "GET", url: "/books/1", expectedStatus: http.StatusOK, expectedBody: "Book fetched"},
+		{name: "POST events is not supported", method: "POST", url: "/books/events", expectedStatus: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.url, nil))
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Equal(t, tt.expectedBody, rec.Body.String())
+		})
+	}
+}
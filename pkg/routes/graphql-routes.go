@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mg4603/go-bookstore-management-system/pkg/utils"
+)
+
+func RegisterGraphQLRoutes(r *mux.Router, handler http.Handler) {
+	r.Handle("/graphql", utils.SetJSONContentType(handler)).Methods("POST")
+}
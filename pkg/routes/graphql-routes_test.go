@@ -0,0 +1,38 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterGraphQLRoutes(t *testing.T) {
+	r := mux.NewRouter()
+	RegisterGraphQLRoutes(r, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{}}`))
+	}))
+
+	tests := []struct {
+		name           string
+		method         string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "POST query", method: "POST", expectedStatus: http.StatusOK, expectedBody: `{"data":{}}`},
+		{name: "GET is not supported", method: "GET", expectedStatus: http.StatusMethodNotAllowed, expectedBody: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(tt.method, "/graphql", nil))
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Equal(t, tt.expectedBody, rec.Body.String())
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
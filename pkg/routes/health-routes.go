@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mg4603/go-bookstore-management-system/pkg/controllers"
+	"github.com/mg4603/go-bookstore-management-system/pkg/utils"
+)
+
+func RegisterHealthRoutes(r *mux.Router, controller *controllers.HealthController) {
+	r.Handle("/healthz", utils.SetJSONContentType(http.HandlerFunc(controller.Healthz))).Methods("GET")
+	r.Handle("/readyz", utils.SetJSONContentType(http.HandlerFunc(controller.Readyz))).Methods("GET")
+}
@@ -0,0 +1,69 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mg4603/go-bookstore-management-system/pkg/controllers"
+)
+
+func TestRegisterHealthRoutes(t *testing.T) {
+	mockHandlers := &controllers.HealthController{
+		Healthz: func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("alive"))
+		},
+		Readyz: func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("not ready"))
+		},
+	}
+
+	r := mux.NewRouter()
+	RegisterHealthRoutes(r, mockHandlers)
+
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Liveness route",
+			method:         "GET",
+			url:            "/healthz",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "alive",
+		},
+		{
+			name:           "Readiness route",
+			method:         "GET",
+			url:            "/readyz",
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   "not ready",
+		},
+		{
+			name:           "Liveness rejects POST",
+			method:         "POST",
+			url:            "/healthz",
+			expectedStatus: http.StatusMethodNotAllowed,
+			expectedBody:   "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.url, nil)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected Status = %v; got  %v", tt.expectedStatus, rec.Code)
+			}
+			if rec.Body.String() != tt.expectedBody {
+				t.Errorf("Expected body = %v; got %v", tt.expectedBody, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,11 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func RegisterMetricsRoutes(r *mux.Router, handler http.Handler) {
+	r.Handle("/metrics", handler).Methods("GET")
+}
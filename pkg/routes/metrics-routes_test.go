@@ -0,0 +1,35 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterMetricsRoutes(t *testing.T) {
+	r := mux.NewRouter()
+	RegisterMetricsRoutes(r, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("metrics"))
+	}))
+
+	tests := []struct {
+		name           string
+		method         string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "GET metrics", method: "GET", expectedStatus: http.StatusOK, expectedBody: "metrics"},
+		{name: "POST metrics", method: "POST", expectedStatus: http.StatusMethodNotAllowed, expectedBody: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(tt.method, "/metrics", nil))
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Equal(t, tt.expectedBody, rec.Body.String())
+		})
+	}
+}
@@ -0,0 +1,10 @@
+package routes
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/mg4603/go-bookstore-management-system/pkg/openapi"
+)
+
+func RegisterOpenAPIRoutes(r *mux.Router, doc *openapi.Document) {
+	r.Handle("/openapi.json", doc.Handler()).Methods("GET")
+}
@@ -0,0 +1,85 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mg4603/go-bookstore-management-system/pkg/controllers"
+	"github.com/mg4603/go-bookstore-management-system/pkg/openapi"
+	"github.com/stretchr/testify/assert"
+)
+
+// allRoutes registers every route the server exposes.
+func allRoutes(doc *openapi.Document) *mux.Router {
+	noop := func(w http.ResponseWriter, r *http.Request) {}
+	r := mux.NewRouter()
+	RegisterChangeFeedRoutes(r, http.HandlerFunc(noop))
+	RegisterBookstoreRoutes(r, &controllers.BookstoreController{
+		CreateBook:  noop,
+		GetBooks:    noop,
+		GetBookById: noop,
+		UpdateBook:  noop,
+		DeleteBook:  noop,
+	})
+	RegisterWebhookRoutes(r, &controllers.WebhookController{
+		CreateWebhook:        noop,
+		GetWebhooks:          noop,
+		GetWebhookById:       noop,
+		UpdateWebhook:        noop,
+		DeleteWebhook:        noop,
+		GetWebhookDeliveries: noop,
+	})
+	RegisterHealthRoutes(r, &controllers.HealthController{Healthz: noop, Readyz: noop})
+	RegisterMetricsRoutes(r, http.HandlerFunc(noop))
+	RegisterGraphQLRoutes(r, http.HandlerFunc(noop))
+	RegisterOpenAPIRoutes(r, doc)
+	return r
+}
+
+func TestRegisterOpenAPIRoutes(t *testing.T) {
+	r := allRoutes(openapi.Bookstore())
+
+	req := httptest.NewRequest("GET", "/openapi.json", nil)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var doc map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+	assert.Equal(t, "3.1.0", doc["openapi"])
+}
+
+func TestAllRoutesDocumented(t *testing.T) {
+	doc := openapi.Bookstore()
+	registered := map[string]bool{}
+
+	err := allRoutes(doc).Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			t.Errorf("route %s does not restrict its methods", path)
+			return nil
+		}
+		for _, method := range methods {
+			registered[method+" "+path] = true
+			assert.NotNil(t, doc.Operation(path, method), "%s %s is registered but not documented in openapi.Bookstore", method, path)
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+
+	for path, item := range doc.Paths {
+		for method := range item {
+			assert.True(t, registered[strings.ToUpper(method)+" "+path], "%s %s is documented but not registered", method, path)
+		}
+	}
+}
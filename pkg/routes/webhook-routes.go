@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mg4603/go-bookstore-management-system/pkg/controllers"
+	"github.com/mg4603/go-bookstore-management-system/pkg/utils"
+)
+
+func RegisterWebhookRoutes(r *mux.Router, controllers *controllers.WebhookController) {
+	r.Handle("/webhooks/", utils.NegotiateRecord(http.HandlerFunc(controllers.CreateWebhook))).Methods("POST")
+	r.Handle("/webhooks/", utils.NegotiateContentType(http.HandlerFunc(controllers.GetWebhooks))).Methods("GET")
+	r.Handle("/webhooks/{id}", utils.NegotiateRecord(http.HandlerFunc(controllers.GetWebhookById))).Methods("GET")
+	r.Handle("/webhooks/{id}", utils.NegotiateRecord(http.HandlerFunc(controllers.UpdateWebhook))).Methods("PUT")
+	r.Handle("/webhooks/{id}", utils.NegotiateRecord(http.HandlerFunc(controllers.DeleteWebhook))).Methods("DELETE")
+	r.Handle("/webhooks/{id}/deliveries", utils.NegotiateContentType(http.HandlerFunc(controllers.GetWebhookDeliveries))).Methods("GET")
+}
@@ -0,0 +1,50 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mg4603/go-bookstore-management-system/pkg/controllers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterWebhookRoutes(t *testing.T) {
+	respond := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		}
+	}
+	r := mux.NewRouter()
+	RegisterWebhookRoutes(r, &controllers.WebhookController{
+		CreateWebhook:        respond("created"),
+		GetWebhooks:          respond("listed"),
+		GetWebhookById:       respond("fetched"),
+		UpdateWebhook:        respond("updated"),
+		DeleteWebhook:        respond("deleted"),
+		GetWebhookDeliveries: respond("deliveries"),
+	})
+
+	tests := []struct {
+		method       string
+		url          string
+		expectedBody string
+	}{
+		{method: "POST", url: "/webhooks/", expectedBody: "created"},
+		{method: "GET", url: "/webhooks/", expectedBody: "listed"},
+		{method: "GET", url: "/webhooks/1", expectedBody: "fetched"},
+		{method: "PUT", url: "/webhooks/1", expectedBody: "updated"},
+		{method: "DELETE", url: "/webhooks/1", expectedBody: "deleted"},
+		{method: "GET", url: "/webhooks/1/deliveries", expectedBody: "deliveries"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.url, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.url, nil))
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, tt.expectedBody, rec.Body.String())
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		})
+	}
+}
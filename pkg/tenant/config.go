@@ -0,0 +1,10 @@
+package tenant
+
+type Config struct {
+	// Default is the slug of the tenant for requests that name none. When
+	// empty, such requests get no tenant and book queries fail.
+	Default string
+	// BaseDomain, when set, lets a request name tenant acme by its host,
+	// acme.<BaseDomain>.
+	BaseDomain string
+}
@@ -0,0 +1,173 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/mg4603/go-bookstore-management-system/pkg/models"
+	"github.com/mg4603/go-bookstore-management-system/pkg/utils"
+)
+
+// Header names a request's tenant by slug.
+const Header = "X-Tenant"
+
+// Errors from Resolve; StatusFromError maps them to HTTP statuses.
+var (
+	ErrUnknownTenant  = errors.New("unknown tenant")
+	ErrTenantMismatch = errors.New("tenant header and host name different tenants")
+	ErrWrongTenant    = errors.New("API key belongs to another tenant")
+	ErrKeyRequired    = errors.New("an API key is required for tenant")
+)
+
+type Store interface {
+	GetTenantBySlug(ctx context.Context, slug string) (*models.Tenant, error)
+	AuthenticateAPIKey(ctx context.Context, key string) (*models.APIKey, error)
+}
+
+// Request holds what a request says about its tenant.
+type Request struct {
+	// APIKey is the bearer token, if any.
+	APIKey string
+	// Slug is the X-Tenant header.
+	Slug string
+	Host string
+}
+
+// Resolver works out which tenant a request acts for. Slugs that resolve are
+// cached, as tenants are never renamed or deleted.
+type Resolver struct {
+	store Store
+	cfg   Config
+
+	mu    sync.RWMutex
+	slugs map[string]uint
+}
+
+func NewResolver(store Store, cfg Config) *Resolver {
+	return &Resolver{store: store, cfg: cfg, slugs: map[string]uint{}}
+}
+
+// Resolve returns the tenant of req's API key or, without a key, the default
+// tenant. The X-Tenant header or the host's subdomain may also name a
+// tenant; it must be the key's, so a key can never reach another tenant's
+// books, and without a key it must be the default, as naming a tenant proves
+// nothing about the caller. An invalid key is rejected, not ignored. ok is
+// false when there is no tenant at all.
+func (res *Resolver) Resolve(ctx context.Context, req Request) (tenantID uint, ok bool, err error) {
+	slug := req.Slug
+	if sub := res.subdomain(req.Host); sub != "" {
+		if slug != "" && slug != sub {
+			return 0, false, ErrTenantMismatch
+		}
+		slug = sub
+	}
+
+	if req.APIKey == "" {
+		// Refused before the slug is looked up, so callers without a key
+		// cannot tell which tenants exist.
+		if slug != "" && slug != res.cfg.Default {
+			return 0, false, fmt.Errorf("%w %q", ErrKeyRequired, slug)
+		}
+		if res.cfg.Default == "" {
+			return 0, false, nil
+		}
+		if tenantID, err = res.lookup(ctx, res.cfg.Default); err != nil {
+			return 0, false, err
+		}
+		return tenantID, true, nil
+	}
+
+	apiKey, err := res.store.AuthenticateAPIKey(ctx, req.APIKey)
+	if err != nil {
+		return 0, false, err
+	}
+	if slug != "" {
+		named, err := res.lookup(ctx, slug)
+		if err != nil {
+			return 0, false, err
+		}
+		if named != apiKey.TenantID {
+			return 0, false, ErrWrongTenant
+		}
+	}
+	return apiKey.TenantID, true, nil
+}
+
+func (res *Resolver) lookup(ctx context.Context, slug string) (uint, error) {
+	res.mu.RLock()
+	tenantID, ok := res.slugs[slug]
+	res.mu.RUnlock()
+	if ok {
+		return tenantID, nil
+	}
+
+	tenant, err := res.store.GetTenantBySlug(ctx, slug)
+	if errors.Is(err, models.ErrNotFound) {
+		return 0, fmt.Errorf("%w %q", ErrUnknownTenant, slug)
+	}
+	if err != nil {
+		return 0, err
+	}
+	res.mu.Lock()
+	res.slugs[slug] = tenant.ID
+	res.mu.Unlock()
+	return tenant.ID, nil
+}
+
+// subdomain returns the label before BaseDomain in host, or "" if host is not
+// directly under it.
+func (res *Resolver) subdomain(host string) string {
+	if res.cfg.BaseDomain == "" {
+		return ""
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	sub, ok := strings.CutSuffix(strings.ToLower(host), "."+res.cfg.BaseDomain)
+	if !ok || sub == "" || strings.Contains(sub, ".") {
+		return ""
+	}
+	return sub
+}
+
+// Middleware scopes each request's context to its tenant with
+// models.WithTenant.
+func (res *Resolver) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var apiKey string
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			apiKey = token
+		}
+		tenantID, ok, err := res.Resolve(r.Context(), Request{APIKey: apiKey, Slug: r.Header.Get(Header), Host: r.Host})
+		if err != nil {
+			if StatusFromError(err) == http.StatusUnauthorized {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+			}
+			utils.HandleError(w, r, StatusFromError(err), fmt.Sprintf("error resolving tenant: %s", err))
+			return
+		}
+		if ok {
+			r = r.WithContext(models.WithTenant(r.Context(), tenantID))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func StatusFromError(err error) int {
+	switch {
+	case errors.Is(err, models.ErrInvalidAPIKey), errors.Is(err, ErrKeyRequired):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrUnknownTenant):
+		return http.StatusNotFound
+	case errors.Is(err, ErrWrongTenant):
+		return http.StatusForbidden
+	case errors.Is(err, ErrTenantMismatch):
+		return http.StatusBadRequest
+	}
+	return utils.QueryErrorStatus(err)
+}
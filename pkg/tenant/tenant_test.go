@@ -0,0 +1,151 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mg4603/go-bookstore-management-system/pkg/models"
+	"github.com/mg4603/go-bookstore-management-system/pkg/tests"
+	"github.com/stretchr/testify/assert"
+)
+
+// setup returns a database with the default tenant, tenant acme and an API
+// key for each.
+func setup(t *testing.T) (db *models.DBModel, acme *models.Tenant, defaultKey, acmeKey string) {
+	mockDB, err := tests.Setup()
+	assert.NoError(t, err, "failed to setup test database")
+	t.Cleanup(func() {
+		sqlDB, _ := mockDB.DB()
+		if sqlDB != nil {
+			sqlDB.Close()
+		}
+	})
+
+	db = &models.DBModel{DB: mockDB}
+	acme, err = db.CreateTenant(context.Background(), "acme", "Acme")
+	assert.NoError(t, err)
+	defaultKey, _, err = db.CreateAPIKey(tests.Context(), "default")
+	assert.NoError(t, err)
+	acmeKey, _, err = db.CreateAPIKey(models.WithTenant(context.Background(), acme.ID), "acme")
+	assert.NoError(t, err)
+	return db, acme, defaultKey, acmeKey
+}
+
+func TestResolve(t *testing.T) {
+	db, acme, defaultKey, acmeKey := setup(t)
+
+	testCases := []struct {
+		name          string
+		config        Config
+		request       Request
+		expectedID    uint
+		expectedOK    bool
+		expectedError error
+	}{
+		{name: "Default tenant", config: Config{Default: "default"}, expectedID: tests.DefaultTenantID, expectedOK: true},
+		{name: "No default tenant", config: Config{}},
+		{name: "Header without an API key", config: Config{Default: "default"}, request: Request{Slug: "acme"}, expectedError: ErrKeyRequired},
+		{name: "Header naming the default tenant", config: Config{Default: "default"}, request: Request{Slug: "default"}, expectedID: tests.DefaultTenantID, expectedOK: true},
+		{name: "Unknown header", config: Config{Default: "default"}, request: Request{APIKey: acmeKey, Slug: "initech"}, expectedError: ErrUnknownTenant},
+		{name: "Unknown header without an API key", config: Config{Default: "default"}, request: Request{Slug: "initech"}, expectedError: ErrKeyRequired},
+		{name: "Unknown default", config: Config{Default: "initech"}, expectedError: ErrUnknownTenant},
+		{name: "Subdomain", config: Config{BaseDomain: "example.com"}, request: Request{APIKey: acmeKey, Host: "Acme.example.com:9010"}, expectedID: acme.ID, expectedOK: true},
+		{name: "Subdomain without an API key", config: Config{BaseDomain: "example.com"}, request: Request{Host: "acme.example.com"}, expectedError: ErrKeyRequired},
+		{name: "Host outside the base domain", config: Config{BaseDomain: "example.com"}, request: Request{Host: "acme.example.org"}},
+		{name: "Nested subdomain", config: Config{BaseDomain: "example.com"}, request: Request{Host: "www.acme.example.com"}},
+		{name: "Header matching subdomain", config: Config{BaseDomain: "example.com"}, request: Request{APIKey: acmeKey, Slug: "acme", Host: "acme.example.com"}, expectedID: acme.ID, expectedOK: true},
+		{name: "Header contradicting subdomain", config: Config{BaseDomain: "example.com"}, request: Request{Slug: "default", Host: "acme.example.com"}, expectedError: ErrTenantMismatch},
+		{name: "API key", config: Config{Default: "default"}, request: Request{APIKey: acmeKey}, expectedID: acme.ID, expectedOK: true},
+		{name: "API key with its own tenant named", config: Config{}, request: Request{APIKey: acmeKey, Slug: "acme"}, expectedID: acme.ID, expectedOK: true},
+		{name: "API key with another tenant named", config: Config{}, request: Request{APIKey: defaultKey, Slug: "acme"}, expectedError: ErrWrongTenant},
+		{name: "API key under another tenant's subdomain", config: Config{BaseDomain: "example.com"}, request: Request{APIKey: defaultKey, Host: "acme.example.com"}, expectedError: ErrWrongTenant},
+		{name: "Invalid API key", config: Config{Default: "default"}, request: Request{APIKey: "bks_unknown"}, expectedError: models.ErrInvalidAPIKey},
+		{name: "Invalid API key with a tenant named", config: Config{}, request: Request{APIKey: "bks_unknown", Slug: "acme"}, expectedError: models.ErrInvalidAPIKey},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			tenantID, ok, err := NewResolver(db, tt.config).Resolve(context.Background(), tt.request)
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedOK, ok)
+			assert.Equal(t, tt.expectedID, tenantID)
+		})
+	}
+}
+
+// failingKeys fails every API key lookup, as a database that is down does.
+type failingKeys struct {
+	Store
+}
+
+func (failingKeys) AuthenticateAPIKey(context.Context, string) (*models.APIKey, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestResolveStoreError(t *testing.T) {
+	db, _, defaultKey, _ := setup(t)
+
+	_, ok, err := NewResolver(failingKeys{Store: db}, Config{Default: "default"}).Resolve(context.Background(), Request{APIKey: defaultKey})
+	assert.EqualError(t, err, "connection refused", "a failed lookup must not fall back to the default tenant")
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusInternalServerError, StatusFromError(err))
+}
+
+func TestMiddleware(t *testing.T) {
+	db, acme, defaultKey, acmeKey := setup(t)
+	resolver := NewResolver(db, Config{Default: "default", BaseDomain: "example.com"})
+
+	testCases := []struct {
+		name             string
+		host             string
+		headers          map[string]string
+		expectedStatus   int
+		expectedTenantID uint
+	}{
+		{name: "Default tenant", expectedStatus: http.StatusOK, expectedTenantID: tests.DefaultTenantID},
+		{name: "Header", headers: map[string]string{Header: "acme", "Authorization": "Bearer " + acmeKey}, expectedStatus: http.StatusOK, expectedTenantID: acme.ID},
+		{name: "Header without an API key", headers: map[string]string{Header: "acme"}, expectedStatus: http.StatusUnauthorized},
+		{name: "Subdomain", host: "acme.example.com", headers: map[string]string{"Authorization": "Bearer " + acmeKey}, expectedStatus: http.StatusOK, expectedTenantID: acme.ID},
+		{name: "Subdomain without an API key", host: "acme.example.com", expectedStatus: http.StatusUnauthorized},
+		{name: "Invalid API key", headers: map[string]string{"Authorization": "Bearer bks_unknown"}, expectedStatus: http.StatusUnauthorized},
+		{name: "Unknown tenant", headers: map[string]string{Header: "initech", "Authorization": "Bearer " + acmeKey}, expectedStatus: http.StatusNotFound},
+		{name: "Unknown tenant without an API key", headers: map[string]string{Header: "initech"}, expectedStatus: http.StatusUnauthorized},
+		{name: "Conflicting tenants", host: "acme.example.com", headers: map[string]string{Header: "default"}, expectedStatus: http.StatusBadRequest},
+		{name: "Another tenant's API key", host: "acme.example.com", headers: map[string]string{"Authorization": "Bearer " + defaultKey}, expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var tenantID uint
+			handler := resolver.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tenantID, _ = models.TenantFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/books/", nil)
+			if tt.host != "" {
+				req.Host = tt.host
+			}
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Equal(t, tt.expectedTenantID, tenantID)
+			if tt.expectedStatus == http.StatusUnauthorized {
+				assert.Equal(t, "Bearer", rec.Header().Get("WWW-Authenticate"))
+			}
+			if tt.expectedStatus != http.StatusOK {
+				assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
@@ -1,6 +1,12 @@
 package tests
 
 import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/mg4603/go-bookstore-management-system/pkg/migrations"
 	"github.com/mg4603/go-bookstore-management-system/pkg/models"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -12,8 +18,29 @@ func Setup() (*gorm.DB, error) {
 		return nil, err
 	}
 
-	if err := db.AutoMigrate(&models.Book{}); err != nil {
+	migrator, err := migrations.New(db, slog.New(slog.NewTextHandler(io.Discard, nil)), migrations.All)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
 		return nil, err
 	}
 	return db, nil
 }
+
+// DefaultTenantID is the tenant the migrations create, which owns the books
+// of a database from Setup unless a test creates others.
+const DefaultTenantID uint = 1
+
+// Context returns a background context scoped to DefaultTenantID.
+func Context() context.Context {
+	return models.WithTenant(context.Background(), DefaultTenantID)
+}
+
+// WithDefaultTenant scopes each request to DefaultTenantID, as the tenant
+// middleware does for requests that name no tenant.
+func WithDefaultTenant(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(models.WithTenant(r.Context(), DefaultTenantID)))
+	})
+}
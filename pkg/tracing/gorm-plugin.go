@@ -0,0 +1,72 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// GormPlugin creates a client span around every gorm operation, as a child of
+// the span in the statement's context (see gorm.DB.WithContext).
+type GormPlugin struct {
+	tracer trace.Tracer
+}
+
+func NewGormPlugin(tp trace.TracerProvider) *GormPlugin {
+	return &GormPlugin{tracer: tp.Tracer(instrumentationName)}
+}
+
+func (p *GormPlugin) Name() string {
+	return "tracing"
+}
+
+func (p *GormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	registrations := []error{
+		cb.Create().Before("gorm:create").Register("tracing:before_create", p.before("gorm.Create")),
+		cb.Create().After("gorm:create").Register("tracing:after_create", p.after),
+		cb.Query().Before("gorm:query").Register("tracing:before_query", p.before("gorm.Query")),
+		cb.Query().After("gorm:query").Register("tracing:after_query", p.after),
+		cb.Update().Before("gorm:update").Register("tracing:before_update", p.before("gorm.Update")),
+		cb.Update().After("gorm:update").Register("tracing:after_update", p.after),
+		cb.Delete().Before("gorm:delete").Register("tracing:before_delete", p.before("gorm.Delete")),
+		cb.Delete().After("gorm:delete").Register("tracing:after_delete", p.after),
+		cb.Row().Before("gorm:row").Register("tracing:before_row", p.before("gorm.Row")),
+		cb.Row().After("gorm:row").Register("tracing:after_row", p.after),
+		cb.Raw().Before("gorm:raw").Register("tracing:before_raw", p.before("gorm.Raw")),
+		cb.Raw().After("gorm:raw").Register("tracing:after_raw", p.after),
+	}
+	return errors.Join(registrations...)
+}
+
+func (p *GormPlugin) before(spanName string) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		ctx, _ := p.tracer.Start(tx.Statement.Context, spanName,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(semconv.DBSystemKey.String(tx.Dialector.Name())),
+		)
+		tx.Statement.Context = ctx
+	}
+}
+
+func (p *GormPlugin) after(tx *gorm.DB) {
+	span := trace.SpanFromContext(tx.Statement.Context)
+	if !span.IsRecording() {
+		return
+	}
+	defer span.End()
+
+	span.SetAttributes(
+		semconv.DBQueryText(tx.Statement.SQL.String()),
+		semconv.DBCollectionName(tx.Statement.Table),
+		attribute.Int64("db.rows_affected", tx.Statement.RowsAffected),
+	)
+	if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(tx.Error)
+		span.SetStatus(codes.Error, tx.Error.Error())
+	}
+}
@@ -0,0 +1,65 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type tracedRecord struct {
+	ID   uint
+	Name string
+}
+
+func TestGormPlugin(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	defer func() {
+		sqlDB, _ := db.DB()
+		if sqlDB != nil {
+			sqlDB.Close()
+		}
+	}()
+	assert.NoError(t, db.AutoMigrate(&tracedRecord{}))
+	assert.NoError(t, db.Use(NewGormPlugin(tp)))
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "request")
+	tx := db.WithContext(ctx)
+	assert.NoError(t, tx.Create(&tracedRecord{Name: "a"}).Error)
+	var found tracedRecord
+	assert.NoError(t, tx.First(&found, 1).Error)
+	assert.Error(t, tx.Exec("SELECT * FROM missing_table").Error)
+	parent.End()
+
+	spans := exporter.GetSpans()
+	byName := map[string]tracetest.SpanStub{}
+	for _, s := range spans {
+		byName[s.Name] = s
+	}
+
+	for _, name := range []string{"gorm.Create", "gorm.Query", "gorm.Raw"} {
+		span, ok := byName[name]
+		assert.True(t, ok, "missing span %s", name)
+		assert.Equal(t, trace.SpanKindClient, span.SpanKind)
+		assert.Equal(t, parent.SpanContext().SpanID(), span.Parent.SpanID(), "%s should be a child of the request span", name)
+		assert.Equal(t, "sqlite", attributeMap(span.Attributes)["db.system"].AsString())
+	}
+
+	query := attributeMap(byName["gorm.Query"].Attributes)
+	assert.Contains(t, query["db.query.text"].AsString(), "SELECT * FROM `traced_records`")
+	assert.Equal(t, "traced_records", query["db.collection.name"].AsString())
+	assert.Equal(t, int64(1), query["db.rows_affected"].AsInt64())
+
+	assert.Equal(t, codes.Error, byName["gorm.Raw"].Status.Code)
+	assert.Equal(t, codes.Unset, byName["gorm.Query"].Status.Code)
+}
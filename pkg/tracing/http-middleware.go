@@ -0,0 +1,53 @@
+package tracing
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mg4603/go-bookstore-management-system/pkg/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware starts a server span per request, continuing any trace passed in
+// a traceparent header. Install it with Router.Use so the span is named after
+// the matched route template.
+func Middleware(tp trace.TracerProvider, propagator propagation.TextMapPropagator) func(http.Handler) http.Handler {
+	tracer := tp.Tracer(instrumentationName)
+
+	return func(n http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+			route := r.URL.Path
+			if current := mux.CurrentRoute(r); current != nil {
+				if tmpl, err := current.GetPathTemplate(); err == nil {
+					route = tmpl
+				}
+			}
+
+			ctx, span := tracer.Start(ctx, r.Method+" "+route,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					semconv.HTTPRequestMethodKey.String(r.Method),
+					semconv.HTTPRoute(route),
+					semconv.URLPath(r.URL.Path),
+					attribute.String("http.request_id", utils.RequestIDFromContext(r.Context())),
+				),
+			)
+			defer span.End()
+
+			rec := utils.NewResponseRecorder(w)
+			n.ServeHTTP(rec, r.WithContext(ctx))
+
+			status := rec.Status()
+			span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func attributeMap(attrs []attribute.KeyValue) map[string]attribute.Value {
+	m := map[string]attribute.Value{}
+	for _, kv := range attrs {
+		m[string(kv.Key)] = kv.Value
+	}
+	return m
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name           string
+		url            string
+		traceparent    string
+		expectedStatus int
+		expectedCode   codes.Code
+	}{
+		{
+			name:           "Continues incoming trace",
+			url:            "/books/1",
+			traceparent:    "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			expectedStatus: http.StatusOK,
+			expectedCode:   codes.Unset,
+		},
+		{
+			name:           "Starts a new trace",
+			url:            "/books/2",
+			expectedStatus: http.StatusOK,
+			expectedCode:   codes.Unset,
+		},
+		{
+			name:           "Server errors mark the span as failed",
+			url:            "/books/500",
+			expectedStatus: http.StatusInternalServerError,
+			expectedCode:   codes.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := tracetest.NewInMemoryExporter()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+			var handlerSpan trace.SpanContext
+			r := mux.NewRouter()
+			r.Use(Middleware(tp, propagation.TraceContext{}))
+			r.HandleFunc("/books/{id}", func(w http.ResponseWriter, r *http.Request) {
+				handlerSpan = trace.SpanContextFromContext(r.Context())
+				if mux.Vars(r)["id"] == "500" {
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			}).Methods("GET")
+
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.traceparent != "" {
+				req.Header.Set("traceparent", tt.traceparent)
+			}
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			spans := exporter.GetSpans()
+			assert.Len(t, spans, 1)
+			span := spans[0]
+
+			assert.Equal(t, "GET /books/{id}", span.Name)
+			assert.Equal(t, trace.SpanKindServer, span.SpanKind)
+			assert.Equal(t, span.SpanContext.SpanID(), handlerSpan.SpanID(), "handler context should carry the server span")
+			assert.Equal(t, tt.expectedCode, span.Status.Code)
+
+			attrs := attributeMap(span.Attributes)
+			assert.Equal(t, "/books/{id}", attrs["http.route"].AsString())
+			assert.Equal(t, tt.url, attrs["url.path"].AsString())
+			assert.Equal(t, int64(tt.expectedStatus), attrs["http.response.status_code"].AsInt64())
+
+			if tt.traceparent != "" {
+				assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext.TraceID().String())
+				assert.Equal(t, "00f067aa0ba902b7", span.Parent.SpanID().String())
+				assert.True(t, span.Parent.IsRemote())
+			} else {
+				assert.False(t, span.Parent.IsValid())
+			}
+		})
+	}
+}
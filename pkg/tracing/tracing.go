@@ -0,0 +1,110 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+const instrumentationName = "github.com/mg4603/go-bookstore-management-system/pkg/tracing"
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+	ExporterOTLP   = "otlp"
+)
+
+type Config struct {
+	// Exporter is one of none, stdout, file or otlp. The OTLP exporter reads
+	// its endpoint and headers from the standard OTEL_EXPORTER_OTLP_* variables.
+	Exporter    string
+	FilePath    string
+	ServiceName string
+}
+
+// ConfigFrom reads OTEL_TRACES_EXPORTER, OTEL_TRACES_FILE and
+// OTEL_SERVICE_NAME through lookup.
+func ConfigFrom(lookup func(key string) (string, bool)) Config {
+	get := func(key string) string { v, _ := lookup(key); return v }
+	cfg := Config{
+		Exporter:    get("OTEL_TRACES_EXPORTER"),
+		FilePath:    get("OTEL_TRACES_FILE"),
+		ServiceName: get("OTEL_SERVICE_NAME"),
+	}
+	if cfg.Exporter == "" {
+		cfg.Exporter = ExporterNone
+	}
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = "bookstore"
+	}
+	return cfg
+}
+
+// Setup installs a global tracer provider and W3C trace context propagator.
+// The returned function flushes pending spans and must be called on exit.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if cfg.Exporter == ExporterNone {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, closer, err := newExporter(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(cfg.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("error building trace resource: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(tp)
+
+	return func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if closer != nil {
+			if closeErr := closer.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	}, nil
+}
+
+func newExporter(ctx context.Context, cfg Config) (sdktrace.SpanExporter, io.Closer, error) {
+	switch cfg.Exporter {
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+		return exporter, nil, err
+	case ExporterFile:
+		if cfg.FilePath == "" {
+			return nil, nil, fmt.Errorf("file trace exporter requires OTEL_TRACES_FILE")
+		}
+		f, err := os.OpenFile(cfg.FilePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error opening trace file: %w", err)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return exporter, f, nil
+	case ExporterOTLP:
+		exporter, err := otlptracehttp.New(ctx)
+		return exporter, nil, err
+	}
+	return nil, nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+}
@@ -0,0 +1,66 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+)
+
+func TestConfigFrom(t *testing.T) {
+	env := map[string]string{}
+	lookup := func(key string) (string, bool) { v, ok := env[key]; return v, ok }
+
+	assert.Equal(t, Config{Exporter: ExporterNone, ServiceName: "bookstore"}, ConfigFrom(lookup))
+
+	env["OTEL_TRACES_EXPORTER"] = "file"
+	env["OTEL_TRACES_FILE"] = "/tmp/traces.json"
+	env["OTEL_SERVICE_NAME"] = "shop"
+
+	assert.Equal(t, Config{Exporter: ExporterFile, FilePath: "/tmp/traces.json", ServiceName: "shop"}, ConfigFrom(lookup))
+}
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         Config
+		expectError bool
+	}{
+		{name: "No exporter", cfg: Config{Exporter: ExporterNone}},
+		{name: "Unknown exporter", cfg: Config{Exporter: "zipkin"}, expectError: true},
+		{name: "File exporter without path", cfg: Config{Exporter: ExporterFile}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shutdown, err := Setup(context.Background(), tt.cfg)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.NoError(t, shutdown(context.Background()))
+		})
+	}
+}
+
+func TestSetupFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.json")
+	previous := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(previous)
+
+	shutdown, err := Setup(context.Background(), Config{Exporter: ExporterFile, FilePath: path, ServiceName: "bookstore-test"})
+	assert.NoError(t, err)
+
+	_, span := otel.Tracer("test").Start(context.Background(), "file-exported")
+	span.End()
+	assert.NoError(t, shutdown(context.Background()))
+
+	contents, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(contents), `"Name":"file-exported"`)
+	assert.Contains(t, string(contents), "bookstore-test")
+}
@@ -0,0 +1,371 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrNotAcceptable        = errors.New("no acceptable representation for response")
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+)
+
+const defaultMediaType = "application/json"
+
+type Format struct {
+	Name   string
+	Encode func(w io.Writer, v interface{}) error
+	Decode func(r io.Reader, v interface{}) error
+	// Stream, if set, lets StreamResponse encode a collection of elemType
+	// incrementally. NegotiateStream only offers formats that set it.
+	Stream func(w io.Writer, elemType reflect.Type) (StreamEncoder, error)
+	// CollectionsOnly formats cannot encode a single record, so NegotiateRecord
+	// does not offer them.
+	CollectionsOnly bool
+}
+
+var (
+	formats = map[string]Format{}
+	// offered lists registered media types in order of server preference,
+	// which is used to break ties and to resolve wildcards in Accept.
+	offered []string
+)
+
+func init() {
+	jsonFormat := Format{Name: "JSON", Encode: encodeJSON, Decode: decodeJSON, Stream: streamJSON}
+	xmlFormat := Format{Name: "XML", Encode: encodeXML, Decode: decodeXML, Stream: streamXML}
+	msgPackFormat := Format{Name: "MessagePack", Encode: encodeMsgPack, Decode: decodeMsgPack}
+
+	RegisterFormat("application/json", jsonFormat)
+	RegisterFormat("application/xml", xmlFormat)
+	RegisterFormat("text/xml", xmlFormat)
+	RegisterFormat("text/csv", Format{Name: "CSV", Encode: encodeCSV, Stream: streamCSV, CollectionsOnly: true})
+	RegisterFormat("application/msgpack", msgPackFormat)
+	RegisterFormat("application/x-msgpack", msgPackFormat)
+	RegisterFormat("application/x-ndjson", Format{Name: "NDJSON", Encode: encodeNDJSON, Stream: streamNDJSON})
+}
+
+func RegisterFormat(mediaType string, f Format) {
+	if _, exists := formats[mediaType]; !exists {
+		offered = append(offered, mediaType)
+	}
+	formats[mediaType] = f
+}
+
+// LookupFormat returns the format registered for mediaType.
+func LookupFormat(mediaType string) (Format, bool) {
+	f, ok := formats[mediaType]
+	return f, ok
+}
+
+// MediaTypes lists the registered media types in order of server preference.
+func MediaTypes() []string {
+	return append([]string(nil), offered...)
+}
+
+type mediaTypeKey struct{}
+
+func NegotiateContentType(n http.Handler) http.Handler {
+	return negotiateContentType(n, func(Format) bool { return true })
+}
+
+// NegotiateRecord is NegotiateContentType for routes that respond with a
+// single record. Formats that only encode collections are refused with 406
+// before n runs, so a write is never made whose response cannot be sent.
+func NegotiateRecord(n http.Handler) http.Handler {
+	return negotiateContentType(n, func(f Format) bool { return !f.CollectionsOnly })
+}
+
+// NegotiateStream is NegotiateContentType for routes that respond with
+// StreamResponse. Formats that cannot be streamed, such as MessagePack, whose
+// arrays start with their length, are refused with 406, as the whole
+// collection would have to be held in memory to write them.
+func NegotiateStream(n http.Handler) http.Handler {
+	return negotiateContentType(n, func(f Format) bool { return f.Stream != nil })
+}
+
+func negotiateContentType(n http.Handler, offer func(Format) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+
+		mediaType, ok := negotiate(r.Header.Get("Accept"), offer)
+		if !ok {
+			w.Header().Set("Content-Type", defaultMediaType)
+			HandleError(w, r, http.StatusNotAcceptable, fmt.Sprintf("cannot satisfy Accept header %q", r.Header.Get("Accept")))
+			return
+		}
+
+		w.Header().Set("Content-Type", mediaType)
+		n.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), mediaTypeKey{}, mediaType)))
+	})
+}
+
+// WriteResponse encodes v in the media type negotiated for r and writes it
+// with the given status. Nothing is written if encoding fails.
+func WriteResponse(w http.ResponseWriter, r *http.Request, statusCode int, v interface{}) error {
+	mediaType, ok := r.Context().Value(mediaTypeKey{}).(string)
+	if !ok {
+		mediaType = defaultMediaType
+	}
+
+	var buf bytes.Buffer
+	if err := formats[mediaType].Encode(&buf, v); err != nil {
+		return err
+	}
+
+	w.WriteHeader(statusCode)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func ResponseErrorStatus(err error) int {
+	if errors.Is(err, ErrNotAcceptable) {
+		return http.StatusNotAcceptable
+	}
+	return http.StatusInternalServerError
+}
+
+func requestFormat(r *http.Request) (Format, error) {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return formats[defaultMediaType], nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return Format{}, fmt.Errorf("%w: %s", ErrUnsupportedMediaType, contentType)
+	}
+
+	f, ok := formats[mediaType]
+	if !ok || f.Decode == nil {
+		return Format{}, fmt.Errorf("%w: %s", ErrUnsupportedMediaType, mediaType)
+	}
+	return f, nil
+}
+
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+func negotiate(accept string, offer func(Format) bool) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return defaultMediaType, true
+	}
+
+	ranges := parseAccept(accept)
+	best, bestQ := "", 0.0
+	for _, candidate := range offered {
+		if !offer(formats[candidate]) {
+			continue
+		}
+		if q := acceptQuality(ranges, candidate); q > bestQ {
+			best, bestQ = candidate, q
+		}
+	}
+	return best, best != ""
+}
+
+func parseAccept(accept string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if qs, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(qs, 64); err == nil {
+				q = parsed
+			}
+		}
+		ranges = append(ranges, acceptRange{mediaType: mediaType, q: q})
+	}
+	return ranges
+}
+
+// acceptQuality returns the q-value of the most specific range in ranges that
+// matches mediaType, or 0 when none does.
+func acceptQuality(ranges []acceptRange, mediaType string) float64 {
+	mainType, _, _ := strings.Cut(mediaType, "/")
+	q, specificity := 0.0, -1
+	for _, ar := range ranges {
+		s := -1
+		switch {
+		case ar.mediaType == mediaType:
+			s = 2
+		case ar.mediaType == mainType+"/*":
+			s = 1
+		case ar.mediaType == "*/*":
+			s = 0
+		}
+		if s > specificity {
+			q, specificity = ar.q, s
+		}
+	}
+	return q
+}
+
+func encodeJSON(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+// decodeJSON rejects fields that v does not declare and anything after the
+// first JSON value, so typos in a request surface as client errors.
+func decodeJSON(r io.Reader, v interface{}) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("unexpected data after top-level JSON value")
+	}
+	return nil
+}
+
+func encodeXML(w io.Writer, v interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if !rv.IsValid() {
+		return nil
+	}
+
+	enc := xml.NewEncoder(w)
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		elemName := xmlElementName(rv.Type().Elem())
+		root := xml.StartElement{Name: xml.Name{Local: elemName + "s"}}
+		if err := enc.EncodeToken(root); err != nil {
+			return err
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if err := enc.EncodeElement(rv.Index(i).Interface(), xml.StartElement{Name: xml.Name{Local: elemName}}); err != nil {
+				return err
+			}
+		}
+		if err := enc.EncodeToken(root.End()); err != nil {
+			return err
+		}
+		return enc.Flush()
+	}
+
+	return enc.Encode(xmlElement{name: xmlElementName(rv.Type()), value: v})
+}
+
+type xmlElement struct {
+	name  string
+	value interface{}
+}
+
+func (e xmlElement) MarshalXML(enc *xml.Encoder, _ xml.StartElement) error {
+	return enc.EncodeElement(e.value, xml.StartElement{Name: xml.Name{Local: e.name}})
+}
+
+func xmlElementName(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	name := strings.TrimSuffix(t.Name(), "Response")
+	if name == "" {
+		return "item"
+	}
+	return strings.ToLower(name)
+}
+
+func decodeXML(r io.Reader, v interface{}) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+func encodeMsgPack(w io.Writer, v interface{}) error {
+	data, err := MarshalMsgPack(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func decodeMsgPack(r io.Reader, v interface{}) error {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	intermediate, err := msgPackToJSON(body)
+	if err != nil {
+		return err
+	}
+	return decodeJSON(bytes.NewReader(intermediate), v)
+}
+
+// encodeCSV writes a collection of structs as CSV with a header row built from
+// the json field names. Anything other than a collection is not acceptable.
+func encodeCSV(w io.Writer, v interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if !rv.IsValid() || (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) {
+		return fmt.Errorf("%w: text/csv is only available for collections", ErrNotAcceptable)
+	}
+
+	elemType := rv.Type().Elem()
+	for elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return fmt.Errorf("%w: text/csv requires a collection of records", ErrNotAcceptable)
+	}
+
+	fields := exportedFields(elemType)
+	header := make([]string, len(fields))
+	for i, f := range fields {
+		header[i] = f.name
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for i := 0; i < rv.Len(); i++ {
+		elem := reflect.Indirect(rv.Index(i))
+		record := make([]string, len(fields))
+		for j, f := range fields {
+			if elem.IsValid() {
+				record[j] = csvValue(elem.FieldByIndex(f.index))
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func csvValue(v reflect.Value) string {
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		if text, err := m.MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// errorFormat picks the format for an error body from the Content-Type already
+// chosen for the response, falling back to JSON.
+func errorFormat(w http.ResponseWriter) (string, Format) {
+	if mediaType, _, err := mime.ParseMediaType(w.Header().Get("Content-Type")); err == nil {
+		if f, ok := formats[mediaType]; ok {
+			return mediaType, f
+		}
+	}
+	return defaultMediaType, formats[defaultMediaType]
+}
@@ -0,0 +1,306 @@
+package utils
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type negotiationRecord struct {
+	ID   uint   `json:"ID" xml:"id"`
+	Name string `json:"name" xml:"name"`
+	Note string `json:"-" xml:"-"`
+}
+
+func TestNegotiateContentType(t *testing.T) {
+	tests := []struct {
+		name                string
+		accept              string
+		payload             interface{}
+		expectedStatus      int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "No Accept header defaults to JSON",
+			accept:              "",
+			payload:             negotiationRecord{ID: 1, Name: "Book1"},
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/json",
+			expectedBody:        "{\"ID\":1,\"name\":\"Book1\"}\n",
+		},
+		{
+			name:                "Wildcard picks JSON",
+			accept:              "*/*",
+			payload:             negotiationRecord{ID: 1, Name: "Book1"},
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/json",
+			expectedBody:        "{\"ID\":1,\"name\":\"Book1\"}\n",
+		},
+		{
+			name:                "XML single record",
+			accept:              "application/xml",
+			payload:             &negotiationRecord{ID: 1, Name: "Book1"},
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/xml",
+			expectedBody:        "<negotiationrecord><id>1</id><name>Book1</name></negotiationrecord>",
+		},
+		{
+			name:                "XML collection is wrapped in a root element",
+			accept:              "application/xml",
+			payload:             []negotiationRecord{{ID: 1, Name: "Book1"}, {ID: 2, Name: "Book2"}},
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/xml",
+			expectedBody:        "<negotiationrecords><negotiationrecord><id>1</id><name>Book1</name></negotiationrecord><negotiationrecord><id>2</id><name>Book2</name></negotiationrecord></negotiationrecords>",
+		},
+		{
+			name:                "CSV collection",
+			accept:              "text/csv",
+			payload:             []negotiationRecord{{ID: 1, Name: "Book1"}, {ID: 2, Name: "Book, Two"}},
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/csv",
+			expectedBody:        "ID,name\n1,Book1\n2,\"Book, Two\"\n",
+		},
+		{
+			name:                "CSV single record is not acceptable",
+			accept:              "text/csv",
+			payload:             negotiationRecord{ID: 1, Name: "Book1"},
+			expectedStatus:      http.StatusNotAcceptable,
+			expectedContentType: "application/json",
+			expectedBody:        "{\"message\":\"An error occurred. Please try again later.\"}\n",
+		},
+		{
+			name:                "Quality values are honoured",
+			accept:              "application/json;q=0.5, application/xml;q=0.9",
+			payload:             negotiationRecord{ID: 1, Name: "Book1"},
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/xml",
+			expectedBody:        "<negotiationrecord><id>1</id><name>Book1</name></negotiationrecord>",
+		},
+		{
+			name:                "Excluded type with q=0 falls through to wildcard",
+			accept:              "application/json;q=0, */*;q=0.1",
+			payload:             negotiationRecord{ID: 1, Name: "Book1"},
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/xml",
+			expectedBody:        "<negotiationrecord><id>1</id><name>Book1</name></negotiationrecord>",
+		},
+		{
+			name:                "Unsupported type",
+			accept:              "text/html",
+			payload:             negotiationRecord{ID: 1, Name: "Book1"},
+			expectedStatus:      http.StatusNotAcceptable,
+			expectedContentType: "application/json",
+			expectedBody:        "{\"message\":\"An error occurred. Please try again later.\"}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NegotiateContentType(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := WriteResponse(w, r, http.StatusOK, tt.payload); err != nil {
+					HandleError(w, r, ResponseErrorStatus(err), err.Error())
+				}
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Equal(t, tt.expectedContentType, rec.Header().Get("Content-Type"))
+			assert.Equal(t, "Accept", rec.Header().Get("Vary"))
+			assert.Equal(t, tt.expectedBody, rec.Body.String())
+		})
+	}
+}
+
+func TestNegotiateRecord(t *testing.T) {
+	tests := []struct {
+		name                string
+		accept              string
+		expectedStatus      int
+		expectedContentType string
+		expectedCalled      bool
+	}{
+		{name: "JSON", accept: "application/json", expectedStatus: http.StatusOK, expectedContentType: "application/json", expectedCalled: true},
+		{name: "CSV is refused before the handler runs", accept: "text/csv", expectedStatus: http.StatusNotAcceptable, expectedContentType: "application/json"},
+		{name: "CSV preference falls back to an acceptable format", accept: "text/csv, application/xml;q=0.5", expectedStatus: http.StatusOK, expectedContentType: "application/xml", expectedCalled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			handler := NegotiateRecord(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				if err := WriteResponse(w, r, http.StatusOK, negotiationRecord{ID: 1, Name: "Book1"}); err != nil {
+					HandleError(w, r, ResponseErrorStatus(err), err.Error())
+				}
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.Header.Set("Accept", tt.accept)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Equal(t, tt.expectedContentType, rec.Header().Get("Content-Type"))
+			assert.Equal(t, tt.expectedCalled, called)
+		})
+	}
+}
+
+func TestNegotiateStream(t *testing.T) {
+	tests := []struct {
+		name                string
+		accept              string
+		expectedStatus      int
+		expectedContentType string
+	}{
+		{name: "CSV", accept: "text/csv", expectedStatus: http.StatusOK, expectedContentType: "text/csv"},
+		{name: "MessagePack is refused", accept: "application/msgpack", expectedStatus: http.StatusNotAcceptable, expectedContentType: "application/json"},
+		{name: "MessagePack preference falls back to a streamable format", accept: "application/msgpack, application/x-ndjson;q=0.5", expectedStatus: http.StatusOK, expectedContentType: "application/x-ndjson"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NegotiateStream(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := StreamResponse(w, r, http.StatusOK, negotiationRecord{}, func(func(interface{}) error) error { return nil }); err != nil {
+					HandleError(w, r, ResponseErrorStatus(err), err.Error())
+				}
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept", tt.accept)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Equal(t, tt.expectedContentType, rec.Header().Get("Content-Type"))
+		})
+	}
+}
+
+func TestParseBodyContentType(t *testing.T) {
+	msgPackBody, err := MarshalMsgPack(map[string]interface{}{"name": "John"})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		contentType   string
+		body          []byte
+		expectedName  string
+		expectedError error
+	}{
+		{
+			name:         "JSON with charset",
+			contentType:  "application/json; charset=utf-8",
+			body:         []byte(`{"name":"John"}`),
+			expectedName: "John",
+		},
+		{
+			name:         "XML",
+			contentType:  "application/xml",
+			body:         []byte(`<person><name>John</name></person>`),
+			expectedName: "John",
+		},
+		{
+			name:         "MessagePack",
+			contentType:  "application/msgpack",
+			body:         msgPackBody,
+			expectedName: "John",
+		},
+		{
+			name:          "CSV is not accepted as input",
+			contentType:   "text/csv",
+			body:          []byte("name\nJohn\n"),
+			expectedError: ErrUnsupportedMediaType,
+		},
+		{
+			name:          "Unknown content type",
+			contentType:   "text/plain",
+			body:          []byte("John"),
+			expectedError: ErrUnsupportedMediaType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+
+			var result struct {
+				Name string `json:"name" xml:"name"`
+			}
+			err := ParseBody(req, &result)
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedName, result.Name)
+			}
+		})
+	}
+}
+
+func TestHandleErrorFormat(t *testing.T) {
+	tests := []struct {
+		name                string
+		contentType         string
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "XML error body",
+			contentType:         "application/xml",
+			expectedContentType: "application/xml",
+			expectedBody:        "<error><message>An error occurred. Please try again later.</message></error>",
+		},
+		{
+			name:                "CSV falls back to JSON",
+			contentType:         "text/csv",
+			expectedContentType: "application/json",
+			expectedBody:        "{\"message\":\"An error occurred. Please try again later.\"}\n",
+		},
+		{
+			name:                "No content type yet",
+			expectedContentType: "application/json",
+			expectedBody:        "{\"message\":\"An error occurred. Please try again later.\"}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			if tt.contentType != "" {
+				rec.Header().Set("Content-Type", tt.contentType)
+			}
+
+			HandleError(rec, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusBadRequest, "bad input")
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Equal(t, tt.expectedContentType, rec.Header().Get("Content-Type"))
+			assert.Equal(t, tt.expectedBody, rec.Body.String())
+		})
+	}
+}
+
+func TestLookupFormat(t *testing.T) {
+	assert.Equal(t, []string{"application/json", "application/xml", "text/xml", "text/csv", "application/msgpack", "application/x-msgpack", "application/x-ndjson"}, MediaTypes())
+
+	for _, mediaType := range MediaTypes() {
+		f, ok := LookupFormat(mediaType)
+		assert.True(t, ok, "%s should be registered", mediaType)
+		assert.NotNil(t, f.Encode, "%s should encode", mediaType)
+	}
+
+	_, ok := LookupFormat("application/yaml")
+	assert.False(t, ok)
+}
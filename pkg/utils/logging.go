@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+type requestIDKey struct{}
+
+type loggerKey struct{}
+
+// RequestID reuses a well-formed X-Request-ID from the client or assigns a new
+// one, and echoes it on the response.
+func RequestID(n http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		n.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().UTC().Format("20060102T150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
+
+// WithLogger makes logger, tagged with the request ID, available to n through
+// LoggerFromContext.
+func WithLogger(logger *slog.Logger, n http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n.ServeHTTP(w, r.WithContext(ContextWithLogger(r.Context(), logger)))
+	})
+}
+
+func ContextWithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	if id := RequestIDFromContext(ctx); id != "" {
+		logger = logger.With("request_id", id)
+	}
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	if id := RequestIDFromContext(ctx); id != "" {
+		return slog.Default().With("request_id", id)
+	}
+	return slog.Default()
+}
+
+func AccessLog(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(n http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := NewResponseRecorder(w)
+
+			n.ServeHTTP(rec, r)
+
+			logger.LogAttrs(r.Context(), slog.LevelInfo, "request completed",
+				slog.String("request_id", RequestIDFromContext(r.Context())),
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", rec.Status()),
+				slog.Int("bytes", rec.Bytes()),
+				slog.Duration("latency", time.Since(start)),
+				slog.String("remote_addr", r.RemoteAddr),
+			)
+		})
+	}
+}
+
+// ResponseRecorder wraps a ResponseWriter to capture the status code and the
+// number of body bytes written, for middleware that reports on responses.
+type ResponseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func NewResponseRecorder(w http.ResponseWriter) *ResponseRecorder {
+	return &ResponseRecorder{ResponseWriter: w}
+}
+
+func (rec *ResponseRecorder) WriteHeader(statusCode int) {
+	if rec.status == 0 {
+		rec.status = statusCode
+	}
+	rec.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rec *ResponseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+func (rec *ResponseRecorder) Status() int {
+	if rec.status == 0 {
+		return http.StatusOK
+	}
+	return rec.status
+}
+
+func (rec *ResponseRecorder) Bytes() int {
+	return rec.bytes
+}
+
+func (rec *ResponseRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (rec *ResponseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name        string
+		incomingID  string
+		expectReuse bool
+	}{
+		{name: "Incoming ID is propagated", incomingID: "abc-123", expectReuse: true},
+		{name: "Missing ID is generated", incomingID: "", expectReuse: false},
+		{name: "Malformed ID is replaced", incomingID: "bad id\nwith newline", expectReuse: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = RequestIDFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incomingID != "" {
+				req.Header.Set(RequestIDHeader, tt.incomingID)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.NotEmpty(t, seen)
+			assert.Equal(t, seen, rec.Header().Get(RequestIDHeader))
+			if tt.expectReuse {
+				assert.Equal(t, tt.incomingID, seen)
+			} else {
+				assert.NotEqual(t, tt.incomingID, seen)
+				assert.Len(t, seen, 32)
+			}
+		})
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	handler := RequestID(AccessLog(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	})))
+
+	req := httptest.NewRequest(http.MethodPost, "/books/", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "request completed", entry["msg"])
+	assert.Equal(t, "req-1", entry["request_id"])
+	assert.Equal(t, "POST", entry["method"])
+	assert.Equal(t, "/books/", entry["path"])
+	assert.Equal(t, float64(http.StatusCreated), entry["status"])
+	assert.Equal(t, float64(5), entry["bytes"])
+	assert.Contains(t, entry, "latency")
+}
+
+func TestHandleErrorIncludesRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	handler := RequestID(WithLogger(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		HandleError(w, r, http.StatusInternalServerError, "database exploded")
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "req-42")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.JSONEq(t, `{"message":"An error occurred. Please try again later.","requestId":"req-42"}`, rec.Body.String())
+
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "ERROR", entry["level"])
+	assert.Equal(t, "req-42", entry["request_id"])
+	assert.Equal(t, "database exploded", entry["error"])
+}
@@ -0,0 +1,450 @@
+package utils
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// MarshalMsgPack encodes v as MessagePack. Struct fields are named after
+// their json tags so that every format exposes the same field names.
+func MarshalMsgPack(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encodeMsgPackValue(&buf, reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// maxMsgPackDepth bounds how deeply arrays and maps may nest in decoded
+// data, so a small body cannot build a huge tree of empty containers.
+const maxMsgPackDepth = 100
+
+var errMsgPackDepth = fmt.Errorf("msgpack: nesting deeper than %d levels", maxMsgPackDepth)
+
+// msgPackToJSON converts MessagePack data to JSON, which is then decoded
+// onto the target using its json tags.
+func msgPackToJSON(data []byte) ([]byte, error) {
+	r := bytes.NewReader(data)
+	generic, err := decodeMsgPackValue(r, 0)
+	if err != nil {
+		return nil, err
+	}
+	if r.Len() != 0 {
+		return nil, errors.New("msgpack: trailing data after top-level value")
+	}
+
+	intermediate, err := json.Marshal(generic)
+	if err != nil {
+		return nil, fmt.Errorf("msgpack: %w", err)
+	}
+	return intermediate, nil
+}
+
+func encodeMsgPackValue(buf *bytes.Buffer, v reflect.Value) error {
+	if !v.IsValid() {
+		buf.WriteByte(0xc0)
+		return nil
+	}
+
+	if v.Kind() != reflect.Ptr || !v.IsNil() {
+		if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+			text, err := m.MarshalText()
+			if err != nil {
+				return err
+			}
+			writeMsgPackString(buf, string(text))
+			return nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			buf.WriteByte(0xc0)
+			return nil
+		}
+		return encodeMsgPackValue(buf, v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writeMsgPackInt(buf, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		writeMsgPackUint(buf, v.Uint())
+	case reflect.Float32:
+		buf.WriteByte(0xca)
+		binary.Write(buf, binary.BigEndian, math.Float32bits(float32(v.Float())))
+	case reflect.Float64:
+		buf.WriteByte(0xcb)
+		binary.Write(buf, binary.BigEndian, math.Float64bits(v.Float()))
+	case reflect.String:
+		writeMsgPackString(buf, v.String())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			buf.WriteByte(0xc0)
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			writeMsgPackBinary(buf, v.Bytes())
+			return nil
+		}
+		writeMsgPackHeader(buf, v.Len(), 0x90, 0xdc, 0xdd)
+		for i := 0; i < v.Len(); i++ {
+			if err := encodeMsgPackValue(buf, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			buf.WriteByte(0xc0)
+			return nil
+		}
+		writeMsgPackHeader(buf, v.Len(), 0x80, 0xde, 0xdf)
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := encodeMsgPackValue(buf, iter.Key()); err != nil {
+				return err
+			}
+			if err := encodeMsgPackValue(buf, iter.Value()); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		var fields []structField
+		for _, f := range exportedFields(v.Type()) {
+			if !f.omitEmpty || !isEmptyValue(v.FieldByIndex(f.index)) {
+				fields = append(fields, f)
+			}
+		}
+		writeMsgPackHeader(buf, len(fields), 0x80, 0xde, 0xdf)
+		for _, f := range fields {
+			writeMsgPackString(buf, f.name)
+			if err := encodeMsgPackValue(buf, v.FieldByIndex(f.index)); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func writeMsgPackInt(buf *bytes.Buffer, n int64) {
+	switch {
+	case n >= 0:
+		writeMsgPackUint(buf, uint64(n))
+	case n >= -32:
+		buf.WriteByte(byte(n))
+	case n >= math.MinInt8:
+		buf.WriteByte(0xd0)
+		buf.WriteByte(byte(n))
+	case n >= math.MinInt16:
+		buf.WriteByte(0xd1)
+		binary.Write(buf, binary.BigEndian, int16(n))
+	case n >= math.MinInt32:
+		buf.WriteByte(0xd2)
+		binary.Write(buf, binary.BigEndian, int32(n))
+	default:
+		buf.WriteByte(0xd3)
+		binary.Write(buf, binary.BigEndian, n)
+	}
+}
+
+func writeMsgPackUint(buf *bytes.Buffer, n uint64) {
+	switch {
+	case n <= 0x7f:
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint8:
+		buf.WriteByte(0xcc)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(0xcd)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	case n <= math.MaxUint32:
+		buf.WriteByte(0xce)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	default:
+		buf.WriteByte(0xcf)
+		binary.Write(buf, binary.BigEndian, n)
+	}
+}
+
+func writeMsgPackString(buf *bytes.Buffer, s string) {
+	switch n := len(s); {
+	case n < 32:
+		buf.WriteByte(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		buf.WriteByte(0xd9)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(0xda)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(0xdb)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+	buf.WriteString(s)
+}
+
+func writeMsgPackBinary(buf *bytes.Buffer, b []byte) {
+	switch n := len(b); {
+	case n <= math.MaxUint8:
+		buf.WriteByte(0xc4)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(0xc5)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(0xc6)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+	buf.Write(b)
+}
+
+func writeMsgPackHeader(buf *bytes.Buffer, n int, fix, code16, code32 byte) {
+	switch {
+	case n < 16:
+		buf.WriteByte(fix | byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(code16)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(code32)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
+
+func decodeMsgPackValue(r *bytes.Reader, depth int) (interface{}, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("msgpack: %w", io.ErrUnexpectedEOF)
+	}
+
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xf0 == 0x80:
+		return decodeMsgPackMap(r, int(c&0x0f), depth+1)
+	case c&0xf0 == 0x90:
+		return decodeMsgPackArray(r, int(c&0x0f), depth+1)
+	case c&0xe0 == 0xa0:
+		return readMsgPackString(r, int(c&0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := readMsgPackLength(r, c-0xc4)
+		if err != nil {
+			return nil, err
+		}
+		return readMsgPackBytes(r, n)
+	case 0xca:
+		var bits uint32
+		if err := binary.Read(r, binary.BigEndian, &bits); err != nil {
+			return nil, fmt.Errorf("msgpack: %w", err)
+		}
+		return float64(math.Float32frombits(bits)), nil
+	case 0xcb:
+		var bits uint64
+		if err := binary.Read(r, binary.BigEndian, &bits); err != nil {
+			return nil, fmt.Errorf("msgpack: %w", err)
+		}
+		return math.Float64frombits(bits), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := readMsgPackUint(r, 1<<(c-0xcc))
+		if err != nil {
+			return nil, err
+		}
+		return n, nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		n, err := readMsgPackUint(r, 1<<(c-0xd0))
+		if err != nil {
+			return nil, err
+		}
+		switch c {
+		case 0xd0:
+			return int64(int8(n)), nil
+		case 0xd1:
+			return int64(int16(n)), nil
+		case 0xd2:
+			return int64(int32(n)), nil
+		}
+		return int64(n), nil
+	case 0xd9, 0xda, 0xdb:
+		n, err := readMsgPackLength(r, c-0xd9)
+		if err != nil {
+			return nil, err
+		}
+		return readMsgPackString(r, n)
+	case 0xdc, 0xdd:
+		n, err := readMsgPackLength(r, c-0xdc+1)
+		if err != nil {
+			return nil, err
+		}
+		return decodeMsgPackArray(r, n, depth+1)
+	case 0xde, 0xdf:
+		n, err := readMsgPackLength(r, c-0xde+1)
+		if err != nil {
+			return nil, err
+		}
+		return decodeMsgPackMap(r, n, depth+1)
+	}
+	return nil, fmt.Errorf("msgpack: unsupported type code 0x%02x", c)
+}
+
+func decodeMsgPackArray(r *bytes.Reader, n, depth int) (interface{}, error) {
+	if depth > maxMsgPackDepth {
+		return nil, errMsgPackDepth
+	}
+	if n > r.Len() {
+		return nil, fmt.Errorf("msgpack: %w", io.ErrUnexpectedEOF)
+	}
+	arr := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		v, err := decodeMsgPackValue(r, depth)
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, v)
+	}
+	return arr, nil
+}
+
+func decodeMsgPackMap(r *bytes.Reader, n, depth int) (interface{}, error) {
+	if depth > maxMsgPackDepth {
+		return nil, errMsgPackDepth
+	}
+	if n > r.Len() {
+		return nil, fmt.Errorf("msgpack: %w", io.ErrUnexpectedEOF)
+	}
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := decodeMsgPackValue(r, depth)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("msgpack: map key of type %T is not a string", k)
+		}
+		v, err := decodeMsgPackValue(r, depth)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+	return m, nil
+}
+
+// readMsgPackLength reads a big-endian length of 1, 2 or 4 bytes, selected by
+// size 0, 1 or 2 respectively.
+func readMsgPackLength(r *bytes.Reader, size byte) (int, error) {
+	n, err := readMsgPackUint(r, 1<<size)
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+func readMsgPackUint(r *bytes.Reader, width int) (uint64, error) {
+	b, err := readMsgPackBytes(r, width)
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n, nil
+}
+
+func readMsgPackString(r *bytes.Reader, n int) (string, error) {
+	b, err := readMsgPackBytes(r, n)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func readMsgPackBytes(r *bytes.Reader, n int) ([]byte, error) {
+	if n > r.Len() {
+		return nil, fmt.Errorf("msgpack: %w", io.ErrUnexpectedEOF)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, fmt.Errorf("msgpack: %w", err)
+	}
+	return b, nil
+}
+
+type structField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// exportedFields lists the fields of t that encoding/json would emit, using
+// the json tag name where one is present. Fields tagged omitempty are
+// included and marked.
+func exportedFields(t reflect.Type) []structField {
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		field := structField{name: f.Name, index: f.Index}
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			tagName, opts, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				field.name = tagName
+			}
+			for _, opt := range strings.Split(opts, ",") {
+				field.omitEmpty = field.omitEmpty || opt == "omitempty"
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// isEmptyValue reports whether encoding/json's omitempty would drop v.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
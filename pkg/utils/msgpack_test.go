@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type msgPackRecord struct {
+	ID      uint      `json:"ID"`
+	Name    string    `json:"name"`
+	Price   float64   `json:"price"`
+	Stock   int       `json:"stock"`
+	Active  bool      `json:"active"`
+	Tags    []string  `json:"tags"`
+	Created time.Time `json:"created"`
+	Hidden  string    `json:"-"`
+}
+
+func TestMarshalMsgPack(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected []byte
+	}{
+		{name: "nil", value: nil, expected: []byte{0xc0}},
+		{name: "true", value: true, expected: []byte{0xc3}},
+		{name: "positive fixint", value: 5, expected: []byte{0x05}},
+		{name: "negative fixint", value: -3, expected: []byte{0xfd}},
+		{name: "uint16", value: 300, expected: []byte{0xcd, 0x01, 0x2c}},
+		{name: "int8", value: -100, expected: []byte{0xd0, 0x9c}},
+		{name: "fixstr", value: "abc", expected: []byte{0xa3, 'a', 'b', 'c'}},
+		{name: "fixarray", value: []int{1, 2}, expected: []byte{0x92, 0x01, 0x02}},
+		{
+			name: "struct uses json names",
+			value: struct {
+				Name   string `json:"n"`
+				Hidden string `json:"-"`
+			}{Name: "x", Hidden: "y"},
+			expected: []byte{0x81, 0xa1, 'n', 0xa1, 'x'},
+		},
+		{
+			name: "struct omits empty omitempty fields",
+			value: struct {
+				Name  string `json:"n,omitempty"`
+				Error string `json:"e,omitempty"`
+			}{Name: "x"},
+			expected: []byte{0x81, 0xa1, 'n', 0xa1, 'x'},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := MarshalMsgPack(tt.value)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, data)
+		})
+	}
+}
+
+func TestMsgPackRoundTrip(t *testing.T) {
+	original := msgPackRecord{
+		ID:      42,
+		Name:    strings.Repeat("long name ", 10),
+		Price:   12.5,
+		Stock:   -7,
+		Active:  true,
+		Tags:    []string{"a", "b"},
+		Created: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Hidden:  "secret",
+	}
+
+	data, err := MarshalMsgPack(original)
+	assert.NoError(t, err)
+
+	var decoded msgPackRecord
+	assert.NoError(t, decodeMsgPack(bytes.NewReader(data), &decoded))
+
+	original.Hidden = ""
+	assert.Equal(t, original, decoded)
+}
+
+func TestDecodeMsgPackErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty input", data: []byte{}},
+		{name: "truncated string", data: []byte{0xa5, 'a'}},
+		{name: "trailing data", data: []byte{0x01, 0x02}},
+		{name: "non-string map key", data: []byte{0x81, 0x01, 0x01}},
+		{name: "unsupported type code", data: []byte{0xc1}},
+		{name: "nested too deeply", data: bytes.Repeat([]byte{0x91}, maxMsgPackDepth+1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v interface{}
+			assert.Error(t, decodeMsgPack(bytes.NewReader(tt.data), &v))
+		})
+	}
+}
+
+func TestDecodeMsgPackDepth(t *testing.T) {
+	nested := append(bytes.Repeat([]byte{0x91}, maxMsgPackDepth-1), 0x90)
+	var v interface{}
+	assert.NoError(t, decodeMsgPack(bytes.NewReader(nested), &v))
+
+	// A body of nested arrays fails at the limit, without decoding the rest.
+	deep := bytes.Repeat([]byte{0x91}, 1<<20)
+	assert.ErrorIs(t, decodeMsgPack(bytes.NewReader(deep), &v), errMsgPackDepth)
+}
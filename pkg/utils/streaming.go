@@ -0,0 +1,235 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+)
+
+// ErrStreamInterrupted wraps errors that occur after a streamed response has
+// started. The status line is already sent, so the handler cannot report the
+// error to the client and should abort the response instead.
+var ErrStreamInterrupted = errors.New("response stream interrupted")
+
+// StreamEncoder writes the elements of one collection as they are produced.
+// Close completes the collection.
+type StreamEncoder interface {
+	Encode(v interface{}) error
+	Close() error
+}
+
+// StreamResponse writes the collection produced by each in the negotiated
+// media type. each calls yield with one slice at a time; every batch is
+// encoded and flushed before the next is requested, so memory use does not
+// grow with the collection. elem is a value of the element type, used for CSV
+// headers and XML element names.
+//
+// Nothing is written until the first batch arrives, so an error before then
+// can still be reported normally. Formats that cannot be streamed are not
+// acceptable; route through NegotiateStream so they are never negotiated.
+func StreamResponse(w http.ResponseWriter, r *http.Request, statusCode int, elem interface{}, each func(yield func(batch interface{}) error) error) error {
+	mediaType, ok := r.Context().Value(mediaTypeKey{}).(string)
+	if !ok {
+		mediaType = defaultMediaType
+	}
+	elemType := reflect.TypeOf(elem)
+
+	format := formats[mediaType]
+	if format.Stream == nil {
+		return fmt.Errorf("%w: %s cannot be streamed", ErrNotAcceptable, format.Name)
+	}
+
+	buf := bufio.NewWriter(w)
+	var enc StreamEncoder
+	start := func() error {
+		if enc != nil {
+			return nil
+		}
+		var err error
+		if enc, err = format.Stream(buf, elemType); err != nil {
+			return err
+		}
+		w.WriteHeader(statusCode)
+		return nil
+	}
+	flush := func() error {
+		if err := buf.Flush(); err != nil {
+			return err
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		return nil
+	}
+
+	err := each(func(batch interface{}) error {
+		if err := start(); err != nil {
+			return err
+		}
+		items := reflect.ValueOf(batch)
+		for i := 0; i < items.Len(); i++ {
+			if err := enc.Encode(items.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		return flush()
+	})
+	if err == nil {
+		if err = start(); err == nil {
+			if err = enc.Close(); err == nil {
+				err = flush()
+			}
+		}
+	}
+	if err != nil && enc != nil {
+		return fmt.Errorf("%w: %w", ErrStreamInterrupted, err)
+	}
+	return err
+}
+
+// jsonArrayStream writes the same bytes as encodeJSON does for a slice.
+type jsonArrayStream struct {
+	w     io.Writer
+	count int
+}
+
+func streamJSON(w io.Writer, _ reflect.Type) (StreamEncoder, error) {
+	_, err := io.WriteString(w, "[")
+	return &jsonArrayStream{w: w}, err
+}
+
+func (s *jsonArrayStream) Encode(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if s.count > 0 {
+		if _, err := io.WriteString(s.w, ","); err != nil {
+			return err
+		}
+	}
+	s.count++
+	_, err = s.w.Write(data)
+	return err
+}
+
+func (s *jsonArrayStream) Close() error {
+	_, err := io.WriteString(s.w, "]\n")
+	return err
+}
+
+// ndjsonStream writes one JSON document per line.
+type ndjsonStream struct {
+	enc *json.Encoder
+}
+
+func streamNDJSON(w io.Writer, _ reflect.Type) (StreamEncoder, error) {
+	return ndjsonStream{enc: json.NewEncoder(w)}, nil
+}
+
+func (s ndjsonStream) Encode(v interface{}) error {
+	return s.enc.Encode(v)
+}
+
+func (s ndjsonStream) Close() error {
+	return nil
+}
+
+// encodeNDJSON writes each element of a collection on its own line, and
+// anything else as a single line.
+func encodeNDJSON(w io.Writer, v interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if !rv.IsValid() || (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) {
+		return json.NewEncoder(w).Encode(v)
+	}
+	enc := json.NewEncoder(w)
+	for i := 0; i < rv.Len(); i++ {
+		if err := enc.Encode(rv.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type xmlStream struct {
+	enc  *xml.Encoder
+	root xml.StartElement
+	elem xml.StartElement
+}
+
+func streamXML(w io.Writer, elemType reflect.Type) (StreamEncoder, error) {
+	name := xmlElementName(elemType)
+	s := &xmlStream{
+		enc:  xml.NewEncoder(w),
+		root: xml.StartElement{Name: xml.Name{Local: name + "s"}},
+		elem: xml.StartElement{Name: xml.Name{Local: name}},
+	}
+	if err := s.enc.EncodeToken(s.root); err != nil {
+		return nil, err
+	}
+	return s, s.enc.Flush()
+}
+
+func (s *xmlStream) Encode(v interface{}) error {
+	if err := s.enc.EncodeElement(v, s.elem); err != nil {
+		return err
+	}
+	return s.enc.Flush()
+}
+
+func (s *xmlStream) Close() error {
+	if err := s.enc.EncodeToken(s.root.End()); err != nil {
+		return err
+	}
+	return s.enc.Flush()
+}
+
+type csvStream struct {
+	cw     *csv.Writer
+	fields []structField
+}
+
+func streamCSV(w io.Writer, elemType reflect.Type) (StreamEncoder, error) {
+	for elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: text/csv requires a collection of records", ErrNotAcceptable)
+	}
+
+	s := &csvStream{cw: csv.NewWriter(w), fields: exportedFields(elemType)}
+	header := make([]string, len(s.fields))
+	for i, f := range s.fields {
+		header[i] = f.name
+	}
+	if err := s.cw.Write(header); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *csvStream) Encode(v interface{}) error {
+	elem := reflect.Indirect(reflect.ValueOf(v))
+	record := make([]string, len(s.fields))
+	for j, f := range s.fields {
+		if elem.IsValid() {
+			record[j] = csvValue(elem.FieldByIndex(f.index))
+		}
+	}
+	if err := s.cw.Write(record); err != nil {
+		return err
+	}
+	s.cw.Flush()
+	return s.cw.Error()
+}
+
+func (s *csvStream) Close() error {
+	s.cw.Flush()
+	return s.cw.Error()
+}
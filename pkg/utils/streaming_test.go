@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type streamedItem struct {
+	ID   int    `json:"id" xml:"id"`
+	Name string `json:"name" xml:"name"`
+}
+
+// flushRecorder records what had been written at each flush.
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushes []string
+}
+
+func (f *flushRecorder) Flush() {
+	f.flushes = append(f.flushes, f.Body.String())
+}
+
+func batches(items ...[]streamedItem) func(func(interface{}) error) error {
+	return func(yield func(interface{}) error) error {
+		for _, batch := range items {
+			if err := yield(batch); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func streamRequest(mediaType string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	return req.WithContext(context.WithValue(req.Context(), mediaTypeKey{}, mediaType))
+}
+
+func TestStreamResponse(t *testing.T) {
+	first := []streamedItem{{ID: 1, Name: "a<b"}, {ID: 2, Name: "c"}}
+	second := []streamedItem{{ID: 3, Name: "d,e"}}
+	all := append(append([]streamedItem{}, first...), second...)
+
+	for _, mediaType := range MediaTypes() {
+		if formats[mediaType].Stream == nil {
+			continue
+		}
+		t.Run(mediaType, func(t *testing.T) {
+			var expected bytes.Buffer
+			assert.NoError(t, formats[mediaType].Encode(&expected, all))
+
+			rec := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+			err := StreamResponse(rec, streamRequest(mediaType), http.StatusOK, streamedItem{}, batches(first, second))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, expected.String(), rec.Body.String(), "streaming writes the same bytes as Encode")
+			assert.Len(t, rec.flushes, 3, "one flush per batch and one at the end")
+			assert.NotEmpty(t, rec.flushes[0])
+			assert.Less(t, len(rec.flushes[0]), len(rec.flushes[1]))
+		})
+	}
+}
+
+func TestStreamResponseEmpty(t *testing.T) {
+	tests := []struct {
+		mediaType    string
+		expectedBody string
+	}{
+		{mediaType: "application/json", expectedBody: "[]\n"},
+		{mediaType: "application/x-ndjson", expectedBody: ""},
+		{mediaType: "application/xml", expectedBody: "<streameditems></streameditems>"},
+		{mediaType: "text/csv", expectedBody: "id,name\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mediaType, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			err := StreamResponse(rec, streamRequest(tt.mediaType), http.StatusOK, streamedItem{}, batches())
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, tt.expectedBody, rec.Body.String())
+		})
+	}
+}
+
+func TestStreamResponseErrors(t *testing.T) {
+	failure := errors.New("database went away")
+
+	rec := httptest.NewRecorder()
+	err := StreamResponse(rec, streamRequest("application/json"), http.StatusOK, streamedItem{}, func(func(interface{}) error) error {
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+	assert.NotErrorIs(t, err, ErrStreamInterrupted, "nothing was sent yet")
+	assert.Empty(t, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	err = StreamResponse(rec, streamRequest("application/json"), http.StatusOK, streamedItem{}, func(yield func(interface{}) error) error {
+		if err := yield([]streamedItem{{ID: 1}}); err != nil {
+			return err
+		}
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+	assert.ErrorIs(t, err, ErrStreamInterrupted)
+	assert.Equal(t, `[{"id":1,"name":""}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	err = StreamResponse(rec, streamRequest("text/csv"), http.StatusOK, "not a record", func(func(interface{}) error) error {
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrNotAcceptable)
+	assert.NotErrorIs(t, err, ErrStreamInterrupted)
+
+	rec = httptest.NewRecorder()
+	err = StreamResponse(rec, streamRequest("application/msgpack"), http.StatusOK, streamedItem{}, func(func(interface{}) error) error {
+		t.Fatal("formats that cannot be streamed must not read the collection")
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrNotAcceptable)
+	assert.Empty(t, rec.Body.String())
+}
@@ -1,13 +1,27 @@
 package utils
 
 import (
-	"encoding/json"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
+	"strings"
 )
 
+// SplitList splits a comma-separated setting, dropping blank entries.
+func SplitList(s string) []string {
+	var values []string
+	for _, value := range strings.Split(s, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 func SetJSONContentType(n http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -15,37 +29,127 @@ func SetJSONContentType(n http.Handler) http.Handler {
 	})
 }
 
+// CacheControl sets the Cache-Control header of successful responses; error
+// responses written by HandleError replace it with no-store.
+func CacheControl(directive string) func(http.Handler) http.Handler {
+	return func(n http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", directive)
+			n.ServeHTTP(w, r)
+		})
+	}
+}
+
+var (
+	// MaxBodyBytes caps the size of request bodies read by ParseBody.
+	MaxBodyBytes int64 = 1 << 20
+
+	ErrRequestTooLarge = errors.New("request body too large")
+)
+
 func ParseBody(r *http.Request, x interface{}) error {
-	body, err := io.ReadAll(r.Body)
 	defer r.Body.Close()
 
+	format, err := requestFormat(r)
 	if err != nil {
+		return err
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, MaxBodyBytes))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return fmt.Errorf("%w: limit is %d bytes", ErrRequestTooLarge, maxBytesErr.Limit)
+		}
 		return fmt.Errorf("failed to read request body: %w", err)
 	}
 
-	if err := json.Unmarshal([]byte(body), x); err != nil {
-		return fmt.Errorf("error parsing JSON: %w", err)
+	if err := format.Decode(bytes.NewReader(body), x); err != nil {
+		return fmt.Errorf("error parsing %s: %w", format.Name, err)
 	}
 	return nil
 }
 
+// StatusClientClosedRequest is the non-standard status, from nginx, recorded
+// for requests whose client went away before the response was written.
+const StatusClientClosedRequest = 499
+
+// QueryErrorStatus maps an error from the models layer to a status: 499 if
+// the client cancelled the request, 504 if a query ran past its deadline and
+// 500 otherwise.
+func QueryErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return StatusClientClosedRequest
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+func ParseErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrRequestTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrUnsupportedMediaType):
+		return http.StatusUnsupportedMediaType
+	default:
+		return http.StatusBadRequest
+	}
+}
+
 type ErrorResponse struct {
-	Message string `json:"message"`
+	Message    string      `json:"message" xml:"message"`
+	RequestID  string      `json:"requestId,omitempty" xml:"requestId,omitempty"`
+	Violations []Violation `json:"violations,omitempty" xml:"violation,omitempty"`
+}
+
+// Violation locates one invalid part of a request: In names the path, query
+// or body, and Pointer is a JSON pointer within it.
+type Violation struct {
+	In      string `json:"in" xml:"in"`
+	Pointer string `json:"pointer" xml:"pointer"`
+	Message string `json:"message" xml:"message"`
 }
 
-func HandleError(w http.ResponseWriter, statusCode int, message string) {
+func HandleError(w http.ResponseWriter, r *http.Request, statusCode int, message string) {
 	standardMessage := "An error occurred. Please try again later."
 
+	logger := LoggerFromContext(r.Context())
+	attrs := []slog.Attr{slog.Int("status", statusCode), slog.String("error", message)}
 	if statusCode >= 500 {
-		log.Printf("Internal Server Error: %s", message)
-	} else if statusCode == 404 {
-		log.Printf("404 not found: %s", message)
-	} else if statusCode == 403 {
-		log.Printf("403 forbidden: %s", message)
+		logger.LogAttrs(r.Context(), slog.LevelError, "internal server error", attrs...)
 	} else {
-		log.Printf("Status Code: %d;\nError message: %s", statusCode, message)
+		logger.LogAttrs(r.Context(), slog.LevelWarn, "client error", attrs...)
+	}
+
+	writeError(w, ErrorResponse{Message: standardMessage, RequestID: RequestIDFromContext(r.Context())}, statusCode)
+}
+
+// HandleValidationError responds 400 and, unlike HandleError, tells the client
+// exactly what was wrong with its request.
+func HandleValidationError(w http.ResponseWriter, r *http.Request, violations []Violation) {
+	LoggerFromContext(r.Context()).LogAttrs(r.Context(), slog.LevelWarn, "client error",
+		slog.Int("status", http.StatusBadRequest), slog.Any("violations", violations))
+
+	writeError(w, ErrorResponse{
+		Message:    "request validation failed",
+		RequestID:  RequestIDFromContext(r.Context()),
+		Violations: violations,
+	}, http.StatusBadRequest)
+}
+
+func writeError(w http.ResponseWriter, response ErrorResponse, statusCode int) {
+	var buf bytes.Buffer
+	mediaType, format := errorFormat(w)
+	if err := format.Encode(&buf, response); err != nil {
+		buf.Reset()
+		mediaType, format = defaultMediaType, formats[defaultMediaType]
+		format.Encode(&buf, response)
 	}
 
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("Cache-Control", "no-store")
 	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(ErrorResponse{Message: standardMessage})
+	w.Write(buf.Bytes())
 }
@@ -2,16 +2,36 @@ package utils
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
+func TestSplitList(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{name: "Empty", input: ""},
+		{name: "Trimmed", input: " a , b,c ", expected: []string{"a", "b", "c"}},
+		{name: "Blank entries dropped", input: "a,, ,b,", expected: []string{"a", "b"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, SplitList(tc.input))
+		})
+	}
+}
+
 func TestSetJSONContentType(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -107,6 +127,36 @@ func TestParseBody(t *testing.T) {
 			expectedErr:     true,
 			expectedMessage: "error parsing JSON",
 		},
+		{
+			name:            "Unknown field",
+			body:            bytes.NewBufferString(`{"nmae":"John"}`),
+			expectedErr:     true,
+			expectedMessage: `unknown field "nmae"`,
+		},
+		{
+			name:            "Trailing data after JSON value",
+			body:            bytes.NewBufferString(`{"name":"John"} garbage`),
+			expectedErr:     true,
+			expectedMessage: "unexpected data after top-level JSON value",
+		},
+		{
+			name:            "Second JSON value",
+			body:            bytes.NewBufferString(`{"name":"John"}{"name":"Jane"}`),
+			expectedErr:     true,
+			expectedMessage: "unexpected data after top-level JSON value",
+		},
+		{
+			name:            "Trailing whitespace is allowed",
+			body:            bytes.NewBufferString("{\"name\":\"John\"}\n"),
+			expectedErr:     false,
+			expectedMessage: "",
+		},
+		{
+			name:            "Body exceeds limit",
+			body:            bytes.NewBufferString(`{"name":"` + strings.Repeat("x", int(MaxBodyBytes)) + `"}`),
+			expectedErr:     true,
+			expectedMessage: "request body too large",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -130,6 +180,42 @@ func TestParseBody(t *testing.T) {
 	}
 }
 
+func TestParseErrorStatus(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+	}{
+		{name: "Too large", err: fmt.Errorf("%w: limit is 1 bytes", ErrRequestTooLarge), expectedStatus: http.StatusRequestEntityTooLarge},
+		{name: "Unsupported media type", err: fmt.Errorf("%w: text/plain", ErrUnsupportedMediaType), expectedStatus: http.StatusUnsupportedMediaType},
+		{name: "Malformed body", err: errors.New("error parsing JSON"), expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedStatus, ParseErrorStatus(tt.err))
+		})
+	}
+}
+
+func TestQueryErrorStatus(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+	}{
+		{name: "Client went away", err: fmt.Errorf("%w: interrupted", context.Canceled), expectedStatus: StatusClientClosedRequest},
+		{name: "Query deadline", err: fmt.Errorf("%w: interrupted", context.DeadlineExceeded), expectedStatus: http.StatusGatewayTimeout},
+		{name: "Other error", err: errors.New("connection refused"), expectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedStatus, QueryErrorStatus(tt.err))
+		})
+	}
+}
+
 func TestHandleError(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -164,14 +250,86 @@ func TestHandleError(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			recorder := httptest.NewRecorder()
-			HandleError(recorder, tc.statusCode, tc.errorMessage)
+			HandleError(recorder, httptest.NewRequest(http.MethodGet, "/", nil), tc.statusCode, tc.errorMessage)
 			assert.Equal(t, recorder.Code, tc.expectedStatus)
 
 			var response ErrorResponse
 			err := json.Unmarshal(recorder.Body.Bytes(), &response)
 			assert.NoError(t, err)
 			assert.Equal(t, tc.expectedBody, response.Message)
+			assert.Equal(t, "no-store", recorder.Header().Get("Cache-Control"))
 		})
 	}
 
 }
+
+func TestCacheControl(t *testing.T) {
+	tests := []struct {
+		name           string
+		n              http.HandlerFunc
+		expectedHeader string
+	}{
+		{
+			name: "Successful response",
+			n: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			},
+			expectedHeader: "public, max-age=30",
+		},
+		{
+			name: "Error response",
+			n: func(w http.ResponseWriter, r *http.Request) {
+				HandleError(w, r, http.StatusNotFound, "no such book")
+			},
+			expectedHeader: "no-store",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			CacheControl("public, max-age=30")(tc.n).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+			assert.Equal(t, tc.expectedHeader, recorder.Header().Get("Cache-Control"))
+		})
+	}
+}
+
+func TestHandleValidationError(t *testing.T) {
+	violations := []Violation{
+		{In: "path", Pointer: "/id", Message: `must be an integer, got "abc"`},
+		{In: "body", Pointer: "/name", Message: "is required"},
+	}
+
+	tests := []struct {
+		name                string
+		contentType         string
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "Defaults to JSON",
+			expectedContentType: "application/json",
+			expectedBody:        `{"message":"request validation failed","violations":[{"in":"path","pointer":"/id","message":"must be an integer, got \"abc\""},{"in":"body","pointer":"/name","message":"is required"}]}` + "\n",
+		},
+		{
+			name:                "Negotiated XML",
+			contentType:         "application/xml",
+			expectedContentType: "application/xml",
+			expectedBody:        `<error><message>request validation failed</message><violation><in>path</in><pointer>/id</pointer><message>must be an integer, got &#34;abc&#34;</message></violation><violation><in>body</in><pointer>/name</pointer><message>is required</message></violation></error>`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			if tc.contentType != "" {
+				recorder.Header().Set("Content-Type", tc.contentType)
+			}
+			HandleValidationError(recorder, httptest.NewRequest(http.MethodPost, "/", nil), violations)
+
+			assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			assert.Equal(t, tc.expectedContentType, recorder.Header().Get("Content-Type"))
+			assert.Equal(t, tc.expectedBody, recorder.Body.String())
+		})
+	}
+}
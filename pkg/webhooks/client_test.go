@@ -0,0 +1,67 @@
+package webhooks
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		addr     string
+		expected bool
+	}{
+		{addr: "93.184.216.34", expected: true},
+		{addr: "2606:2800:220:1:248:1893:25c8:1946", expected: true},
+		{addr: "127.0.0.1"},
+		{addr: "::1"},
+		{addr: "10.1.2.3"},
+		{addr: "172.16.0.1"},
+		{addr: "192.168.1.1"},
+		{addr: "169.254.169.254"},
+		{addr: "100.64.0.1"},
+		{addr: "0.0.0.0"},
+		{addr: "fd00::1"},
+		{addr: "fe80::1"},
+		{addr: "::ffff:127.0.0.1"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.addr, func(t *testing.T) {
+			assert.Equal(t, tc.expected, isPublic(netip.MustParseAddr(tc.addr)))
+		})
+	}
+}
+
+func TestClientRefusesNonPublicAddresses(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	t.Cleanup(srv.Close)
+
+	_, err := newClient(time.Second, isPublic).Get(srv.URL)
+	assert.ErrorIs(t, err, ErrForbiddenAddress)
+	assert.Equal(t, int32(0), hits.Load())
+}
+
+func TestClientDoesNotFollowRedirects(t *testing.T) {
+	var hits atomic.Int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	t.Cleanup(target.Close)
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusFound))
+	t.Cleanup(redirect.Close)
+
+	resp, err := newClient(time.Second, func(netip.Addr) bool { return true }).Get(redirect.URL)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusFound, resp.StatusCode)
+	}
+	assert.Equal(t, int32(0), hits.Load())
+}
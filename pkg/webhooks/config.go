@@ -0,0 +1,20 @@
+package webhooks
+
+import "time"
+
+type Config struct {
+	// PollInterval is how often the dispatcher checks the outbox; zero stops
+	// it delivering.
+	PollInterval time.Duration
+	// Timeout bounds each delivery request.
+	Timeout time.Duration
+	// MaxAttempts is how many times a delivery is tried before it fails.
+	MaxAttempts int
+	// Backoff is the wait after the first failed attempt, doubled after each
+	// further one up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Retention is how long finished deliveries stay in the log; zero keeps
+	// them.
+	Retention time.Duration
+}
@@ -0,0 +1,177 @@
+// Package webhooks delivers catalogue change events from the outbox to the
+// webhooks subscribed to them.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mg4603/go-bookstore-management-system/pkg/metrics"
+	"github.com/mg4603/go-bookstore-management-system/pkg/models"
+)
+
+// batchSize bounds the events fanned out, and the deliveries attempted, on
+// each tick.
+const batchSize = 50
+
+// pruneInterval is how often finished deliveries older than Retention are
+// deleted.
+const pruneInterval = time.Hour
+
+// Event is the body of each delivery. ID is the same on every attempt, so
+// receivers can drop duplicates.
+type Event struct {
+	ID         uint            `json:"id"`
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// Dispatcher moves events from the outbox to webhooks. Any number of
+// instances may run one against the same database.
+type Dispatcher struct {
+	outbox     models.WebhookOutbox
+	cfg        Config
+	client     *http.Client
+	logger     *slog.Logger
+	deliveries *metrics.CounterVec
+	now        func() time.Time
+	lastPrune  time.Time
+}
+
+func NewDispatcher(outbox models.WebhookOutbox, cfg Config, logger *slog.Logger, reg *metrics.Registry) *Dispatcher {
+	return &Dispatcher{
+		outbox:     outbox,
+		cfg:        cfg,
+		client:     newClient(cfg.Timeout, isPublic),
+		logger:     logger.With("component", "webhooks"),
+		deliveries: reg.NewCounterVec("bookstore_webhook_deliveries_total", "Webhook delivery attempts by result.", "result"),
+		now:        time.Now,
+	}
+}
+
+// Run processes the outbox every PollInterval until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	if d.cfg.PollInterval == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := d.Process(ctx); err != nil && ctx.Err() == nil {
+			d.logger.Error("failed to process webhook outbox", "error", err)
+		}
+	}
+}
+
+// Process fans out pending outbox events and attempts the deliveries that
+// are due. Failed attempts are retried on a later call.
+func (d *Dispatcher) Process(ctx context.Context) error {
+	if _, err := d.outbox.DispatchEvents(ctx, d.now(), batchSize); err != nil {
+		return fmt.Errorf("error dispatching events: %w", err)
+	}
+
+	// The lease outlasts the request, so a delivery is only claimed again
+	// if this instance died while sending it.
+	claimed, err := d.outbox.ClaimDeliveries(ctx, d.now(), 2*d.cfg.Timeout, batchSize)
+	if err != nil {
+		return fmt.Errorf("error claiming deliveries: %w", err)
+	}
+
+	results := make([]models.WebhookDelivery, len(claimed))
+	var wg sync.WaitGroup
+	for i := range claimed {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = d.attempt(ctx, claimed[i])
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range results {
+		if err := d.outbox.RecordDelivery(ctx, &results[i]); err != nil {
+			return fmt.Errorf("error recording delivery %d: %w", results[i].ID, err)
+		}
+	}
+
+	if d.cfg.Retention > 0 && d.now().Sub(d.lastPrune) >= pruneInterval {
+		d.lastPrune = d.now()
+		if _, err := d.outbox.PruneDeliveries(ctx, d.now().Add(-d.cfg.Retention)); err != nil {
+			return fmt.Errorf("error pruning deliveries: %w", err)
+		}
+	}
+	return nil
+}
+
+// attempt sends p once and returns its delivery updated with the outcome.
+func (d *Dispatcher) attempt(ctx context.Context, p models.PendingDelivery) models.WebhookDelivery {
+	delivery := p.Delivery
+	status, err := d.send(ctx, p)
+	delivery.ResponseStatus = status
+	switch {
+	case err == nil:
+		delivery.Status = models.DeliverySucceeded
+		delivery.LastError = ""
+		d.deliveries.Inc("succeeded")
+	case delivery.Attempts >= d.cfg.MaxAttempts:
+		delivery.Status = models.DeliveryFailed
+		delivery.LastError = err.Error()
+		d.deliveries.Inc("failed")
+		d.logger.Warn("webhook delivery failed", "delivery_id", delivery.ID, "webhook_id", delivery.WebhookID, "attempts", delivery.Attempts, "error", err)
+	default:
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = d.now().Add(d.backoff(delivery.Attempts))
+		d.deliveries.Inc("retry")
+	}
+	return delivery
+}
+
+func (d *Dispatcher) send(ctx context.Context, p models.PendingDelivery) (int, error) {
+	body, err := json.Marshal(Event{ID: p.Delivery.EventID, Type: p.Delivery.EventType, OccurredAt: p.OccurredAt.UTC(), Data: p.Payload})
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, p.Delivery.EventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatUint(uint64(p.Delivery.ID), 10))
+	req.Header.Set(SignatureHeader, Sign(p.Secret, d.now(), body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the wait after the given number of failed attempts.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.cfg.Backoff
+	for i := 1; i < attempts && wait < d.cfg.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, d.cfg.MaxBackoff)
+}
@@ -0,0 +1,171 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mg4603/go-bookstore-management-system/pkg/metrics"
+	"github.com/mg4603/go-bookstore-management-system/pkg/models"
+	"github.com/mg4603/go-bookstore-management-system/pkg/tests"
+	"github.com/stretchr/testify/assert"
+)
+
+type received struct {
+	event     Event
+	eventType string
+	err       error
+}
+
+// receiver is a webhook endpoint that answers with the next of statuses,
+// then 200, and checks each delivery's signature.
+type receiver struct {
+	mu       sync.Mutex
+	secret   string
+	now      func() time.Time
+	statuses []int
+	received []received
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	var event Event
+	json.Unmarshal(body, &event)
+	err := Verify(rc.secret, r.Header.Get(SignatureHeader), body, rc.now(), time.Minute)
+	rc.received = append(rc.received, received{event: event, eventType: r.Header.Get(EventHeader), err: err})
+
+	status := http.StatusOK
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func setup(t *testing.T, statuses ...int) (*Dispatcher, *models.DBModel, *receiver, *time.Time) {
+	mockDB, err := tests.Setup()
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		if sqlDB, _ := mockDB.DB(); sqlDB != nil {
+			sqlDB.Close()
+		}
+	})
+	db := &models.DBModel{DB: mockDB}
+
+	clock := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	rc := &receiver{now: func() time.Time { return clock }, statuses: statuses}
+	srv := httptest.NewServer(rc)
+	t.Cleanup(srv.Close)
+
+	webhook := &models.Webhook{URL: srv.URL, Events: []string{models.EventBookCreated}, Active: true}
+	assert.NoError(t, db.CreateWebhook(tests.Context(), webhook))
+	rc.secret = webhook.Secret
+
+	cfg := Config{Timeout: time.Second, MaxAttempts: 3, Backoff: 10 * time.Second, MaxBackoff: 15 * time.Second}
+	d := NewDispatcher(db, cfg, slog.New(slog.NewTextHandler(io.Discard, nil)), metrics.NewRegistry())
+	d.now = func() time.Time { return clock }
+	// The receiver listens on loopback, which deliveries may not reach.
+	d.client = newClient(cfg.Timeout, func(netip.Addr) bool { return true })
+	return d, db, rc, &clock
+}
+
+func deliveries(t *testing.T, db *models.DBModel) []models.WebhookDelivery {
+	deliveries, err := db.GetWebhookDeliveries(tests.Context(), 1, 0)
+	assert.NoError(t, err)
+	return deliveries
+}
+
+func TestDispatcherDeliversSignedEvents(t *testing.T) {
+	d, db, rc, _ := setup(t)
+	book := &models.Book{Name: "Book1", Author: "Author1", Publication: "Publication1"}
+	assert.NoError(t, db.CreateBook(tests.Context(), book))
+	assert.NoError(t, db.UpdateBook(tests.Context(), book), "the webhook is not subscribed to updates")
+
+	assert.NoError(t, d.Process(tests.Context()))
+	assert.NoError(t, d.Process(tests.Context()))
+
+	if assert.Len(t, rc.received, 1) {
+		assert.NoError(t, rc.received[0].err)
+		assert.Equal(t, models.EventBookCreated, rc.received[0].eventType)
+		assert.Equal(t, models.EventBookCreated, rc.received[0].event.Type)
+		assert.JSONEq(t, `{"ID":1,"name":"Book1","author":"Author1","publication":"Publication1"}`, string(rc.received[0].event.Data))
+	}
+	logged := deliveries(t, db)
+	if assert.Len(t, logged, 1) {
+		assert.Equal(t, models.DeliverySucceeded, logged[0].Status)
+		assert.Equal(t, http.StatusOK, logged[0].ResponseStatus)
+		assert.Equal(t, 1, logged[0].Attempts)
+	}
+}
+
+func TestDispatcherRetriesWithBackoff(t *testing.T) {
+	d, db, rc, clock := setup(t, http.StatusInternalServerError, http.StatusServiceUnavailable)
+	assert.NoError(t, db.CreateBook(tests.Context(), &models.Book{Name: "Book1", Author: "Author1", Publication: "Publication1"}))
+
+	assert.NoError(t, d.Process(tests.Context()))
+	logged := deliveries(t, db)[0]
+	assert.Equal(t, models.DeliveryPending, logged.Status)
+	assert.Equal(t, "unexpected status 500", logged.LastError)
+	assert.Equal(t, clock.Add(10*time.Second), logged.NextAttemptAt.UTC())
+
+	*clock = clock.Add(5 * time.Second)
+	assert.NoError(t, d.Process(tests.Context()))
+	assert.Len(t, rc.received, 1, "not retried before the backoff")
+
+	*clock = clock.Add(5 * time.Second)
+	assert.NoError(t, d.Process(tests.Context()))
+	logged = deliveries(t, db)[0]
+	assert.Equal(t, http.StatusServiceUnavailable, logged.ResponseStatus)
+	assert.Equal(t, clock.Add(15*time.Second), logged.NextAttemptAt.UTC(), "the backoff doubles up to the maximum")
+
+	*clock = clock.Add(15 * time.Second)
+	assert.NoError(t, d.Process(tests.Context()))
+	logged = deliveries(t, db)[0]
+	assert.Equal(t, models.DeliverySucceeded, logged.Status)
+	assert.Equal(t, 3, logged.Attempts)
+	assert.Len(t, rc.received, 3)
+	assert.Equal(t, rc.received[0].event.ID, rc.received[2].event.ID, "retries carry the same event ID")
+}
+
+func TestDispatcherGivesUpAfterMaxAttempts(t *testing.T) {
+	d, db, rc, clock := setup(t, 500, 500, 500, 500)
+	assert.NoError(t, db.CreateBook(tests.Context(), &models.Book{Name: "Book1", Author: "Author1", Publication: "Publication1"}))
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, d.Process(tests.Context()))
+		*clock = clock.Add(time.Minute)
+	}
+	assert.Len(t, rc.received, 3)
+	logged := deliveries(t, db)[0]
+	assert.Equal(t, models.DeliveryFailed, logged.Status)
+	assert.Equal(t, 3, logged.Attempts)
+}
+
+func TestDispatcherRefusesNonPublicAddresses(t *testing.T) {
+	d, db, rc, _ := setup(t)
+	d.client = newClient(time.Second, isPublic)
+	assert.NoError(t, db.CreateBook(tests.Context(), &models.Book{Name: "Book1", Author: "Author1", Publication: "Publication1"}))
+
+	assert.NoError(t, d.Process(tests.Context()))
+	assert.Empty(t, rc.received)
+	logged := deliveries(t, db)[0]
+	assert.Equal(t, models.DeliveryPending, logged.Status)
+	assert.Contains(t, logged.LastError, "webhook address is not public: 127.0.0.1")
+}
+
+func TestBackoff(t *testing.T) {
+	d := &Dispatcher{cfg: Config{Backoff: time.Second, MaxBackoff: 10 * time.Second}}
+	var waits []time.Duration
+	for attempts := 1; attempts <= 6; attempts++ {
+		waits = append(waits, d.backoff(attempts))
+	}
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}, waits)
+}
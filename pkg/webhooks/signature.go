@@ -0,0 +1,63 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers sent with each delivery.
+const (
+	SignatureHeader = "X-Bookstore-Signature"
+	EventHeader     = "X-Bookstore-Event"
+	DeliveryHeader  = "X-Bookstore-Delivery"
+)
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Sign returns the SignatureHeader value for body sent at t:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<unix seconds>.<body>">". The
+// timestamp is signed so receivers can reject replays.
+func Sign(secret string, t time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", timestamp, signature(secret, timestamp, body))
+}
+
+// Verify checks a SignatureHeader value against body, and that it was signed
+// within tolerance of now. Receivers written in Go can use it as is.
+func Verify(secret, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var timestamp, v1 string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			v1 = value
+		}
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || v1 == "" {
+		return fmt.Errorf("%w: malformed header", ErrInvalidSignature)
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidSignature)
+	}
+	if !hmac.Equal([]byte(v1), []byte(signature(secret, timestamp, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func signature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
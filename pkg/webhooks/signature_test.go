@@ -0,0 +1,40 @@
+package webhooks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	signedAt := time.Unix(1700000000, 0)
+	body := []byte(`{"id":1}`)
+	header := Sign("whsec_test", signedAt, body)
+	assert.Equal(t, "t=1700000000,v1=", header[:16])
+
+	tests := []struct {
+		name          string
+		secret        string
+		header        string
+		body          string
+		now           time.Time
+		expectedError bool
+	}{
+		{name: "Valid", secret: "whsec_test", header: header, body: `{"id":1}`, now: signedAt.Add(time.Minute)},
+		{name: "Wrong secret", secret: "whsec_other", header: header, body: `{"id":1}`, now: signedAt, expectedError: true},
+		{name: "Tampered body", secret: "whsec_test", header: header, body: `{"id":2}`, now: signedAt, expectedError: true},
+		{name: "Too old", secret: "whsec_test", header: header, body: `{"id":1}`, now: signedAt.Add(10 * time.Minute), expectedError: true},
+		{name: "Malformed", secret: "whsec_test", header: "v1=abc", body: `{"id":1}`, now: signedAt, expectedError: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Verify(tc.secret, tc.header, []byte(tc.body), tc.now, 5*time.Minute)
+			if tc.expectedError {
+				assert.ErrorIs(t, err, ErrInvalidSignature)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}